@@ -9,7 +9,6 @@ import (
 	"time"
 
 	metrics "code.cloudfoundry.org/go-metric-registry"
-	"code.cloudfoundry.org/tlsconfig"
 
 	"net/http"
 
@@ -46,6 +45,7 @@ type ForwarderAgent struct {
 type Metrics interface {
 	NewGauge(name, helpText string, opts ...metrics.MetricOption) metrics.Gauge
 	NewCounter(name, helpText string, opts ...metrics.MetricOption) metrics.Counter
+	RemoveGauge(g metrics.Gauge)
 	RegisterDebugMetrics()
 }
 
@@ -93,8 +93,18 @@ func (s *ForwarderAgent) Run() {
 		ingressDropped.Add(float64(missed))
 	}))
 
+	grpcCerts, err := plumbing.NewCertificateProvider(
+		s.grpc.CertFile,
+		s.grpc.KeyFile,
+		s.grpc.CAFile,
+		plumbing.WithExpiryMetrics(s.m, "grpc"),
+	)
+	if err != nil {
+		s.log.Fatalf("failed to load gRPC certificates: %s", err)
+	}
+
 	dests := downstreamDestinations(s.downstreamFilePattern, s.log)
-	writers := downstreamWriters(dests, s.grpc, s.m, s.log)
+	writers := downstreamWriters(dests, grpcCerts, s.m, s.log)
 	tagger := egress_v2.NewTagger(s.tags)
 	ew := egress_v2.NewEnvelopeWriter(
 		multiWriter{writers: writers},
@@ -112,12 +122,7 @@ func (s *ForwarderAgent) Run() {
 		opts = append(opts, plumbing.WithCipherSuites(s.grpc.CipherSuites))
 	}
 
-	serverCreds, err := plumbing.NewServerCredentials(
-		s.grpc.CertFile,
-		s.grpc.KeyFile,
-		s.grpc.CAFile,
-		opts...,
-	)
+	serverCreds, err := grpcCerts.ServerCredentials(opts...)
 	if err != nil {
 		s.log.Fatalf("failed to configure server TLS: %s", err)
 	}
@@ -207,29 +212,23 @@ func downstreamDestinations(pattern string, l *log.Logger) []destination {
 	return dests
 }
 
-func downstreamWriters(dests []destination, grpc GRPC, m Metrics, l *log.Logger) []Writer {
+func downstreamWriters(dests []destination, certs *plumbing.CertificateProvider, m Metrics, l *log.Logger) []Writer {
 	var writers []Writer
 	for _, d := range dests {
 		var w Writer
 		switch d.Protocol {
 		case "otelcol":
-			w = otelCollectorClient(d, grpc, m, l)
+			w = otelCollectorClient(d, certs, m, l)
 		default:
-			w = loggregatorClient(d, grpc, m, l)
+			w = loggregatorClient(d, certs, m, l)
 		}
 		writers = append(writers, w)
 	}
 	return writers
 }
 
-func otelCollectorClient(dest destination, grpc GRPC, m Metrics, l *log.Logger) Writer {
-	clientCreds, err := tlsconfig.Build(
-		tlsconfig.WithInternalServiceDefaults(),
-		tlsconfig.WithIdentityFromFile(grpc.CertFile, grpc.KeyFile),
-	).Client(
-		tlsconfig.WithAuthorityFromFile(grpc.CAFile),
-		tlsconfig.WithServerName("otel-collector"),
-	)
+func otelCollectorClient(dest destination, certs *plumbing.CertificateProvider, m Metrics, l *log.Logger) Writer {
+	clientCreds, err := certs.ClientConfig("otel-collector")
 	if err != nil {
 		l.Fatalf("failed to configure client TLS: %s", err)
 	}
@@ -257,12 +256,8 @@ func otelCollectorClient(dest destination, grpc GRPC, m Metrics, l *log.Logger)
 	return dw
 }
 
-func loggregatorClient(dest destination, grpc GRPC, m Metrics, l *log.Logger) Writer {
-	clientCreds, err := loggregator.NewIngressTLSConfig(
-		grpc.CAFile,
-		grpc.CertFile,
-		grpc.KeyFile,
-	)
+func loggregatorClient(dest destination, certs *plumbing.CertificateProvider, m Metrics, l *log.Logger) Writer {
+	clientCreds, err := certs.ClientConfig("metron")
 	if err != nil {
 		l.Fatalf("failed to configure client TLS: %s", err)
 	}
@@ -41,12 +41,32 @@ func NewAgent(
 }
 
 func (a *Agent) Start() {
-	clientCreds, err := plumbing.NewClientCredentials(
+	logger := log.New(os.Stderr, "", log.LstdFlags)
+	logger.Println("starting loggregator-agent")
+	defer logger.Println("stopping loggregator-agent")
+
+	metricClient := metrics.NewRegistry(
+		logger,
+		metrics.WithTLSServer(
+			int(a.config.MetricsServer.Port),
+			a.config.MetricsServer.CertFile,
+			a.config.MetricsServer.KeyFile,
+			a.config.MetricsServer.CAFile,
+		),
+	)
+	logger.Printf("metrics bound to: :%s", metricClient.Port())
+
+	grpcCerts, err := plumbing.NewCertificateProvider(
 		a.config.GRPC.CertFile,
 		a.config.GRPC.KeyFile,
 		a.config.GRPC.CAFile,
-		"doppler",
+		plumbing.WithExpiryMetrics(metricClient, "grpc"),
 	)
+	if err != nil {
+		log.Fatalf("Could not load GRPC certificates: %s", err)
+	}
+
+	clientCreds, err := grpcCerts.ClientCredentials("doppler")
 	if err != nil {
 		log.Fatalf("Could not use GRPC creds for client: %s", err)
 	}
@@ -56,31 +76,11 @@ func (a *Agent) Start() {
 		opts = append(opts, plumbing.WithCipherSuites(a.config.GRPC.CipherSuites))
 	}
 
-	serverCreds, err := plumbing.NewServerCredentials(
-		a.config.GRPC.CertFile,
-		a.config.GRPC.KeyFile,
-		a.config.GRPC.CAFile,
-		opts...,
-	)
+	serverCreds, err := grpcCerts.ServerCredentials(opts...)
 	if err != nil {
 		log.Fatalf("Could not use GRPC creds for server: %s", err)
 	}
 
-	logger := log.New(os.Stderr, "", log.LstdFlags)
-	logger.Println("starting loggregator-agent")
-	defer logger.Println("stopping loggregator-agent")
-
-	metricClient := metrics.NewRegistry(
-		logger,
-		metrics.WithTLSServer(
-			int(a.config.MetricsServer.Port),
-			a.config.MetricsServer.CertFile,
-			a.config.MetricsServer.KeyFile,
-			a.config.MetricsServer.CAFile,
-		),
-	)
-	logger.Printf("metrics bound to: :%s", metricClient.Port())
-
 	appV1 := NewV1App(a.config, clientCreds, metricClient)
 	go appV1.Start()
 
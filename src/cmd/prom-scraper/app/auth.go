@@ -0,0 +1,223 @@
+package app
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"code.cloudfoundry.org/tlsconfig"
+
+	"code.cloudfoundry.org/loggregator-agent-release/src/pkg/scraper"
+)
+
+// tokenExpiryDelta is how long before their expiry OAuth2 tokens are
+// refreshed.
+const tokenExpiryDelta = 10 * time.Second
+
+// authorizer sets the Authorization header of a scrape request.
+type authorizer func(req *http.Request) error
+
+func noAuth(*http.Request) error { return nil }
+
+// authorizer returns the authorizer for the bearer token file, basic auth or
+// OAuth2 settings of the scrape config. Secret files are read on each scrape
+// so that rotated secrets are picked up.
+func (p *PromScraper) authorizer(scrapeConfig scraper.PromScraperConfig) (authorizer, error) {
+	switch {
+	case scrapeConfig.BearerTokenFile != "":
+		return func(req *http.Request) error {
+			token, err := readSecret("", scrapeConfig.BearerTokenFile)
+			if err != nil {
+				return err
+			}
+			req.Header.Set("Authorization", "Bearer "+token)
+			return nil
+		}, nil
+	case hasBasicAuth(scrapeConfig.BasicAuth):
+		basicAuth := scrapeConfig.BasicAuth
+		return func(req *http.Request) error {
+			password, err := readSecret(basicAuth.Password, basicAuth.PasswordFile)
+			if err != nil {
+				return err
+			}
+			req.SetBasicAuth(basicAuth.Username, password)
+			return nil
+		}, nil
+	case hasOAuth2(scrapeConfig.OAuth2):
+		client, err := p.buildOAuth2Client(scrapeConfig)
+		if err != nil {
+			return nil, err
+		}
+		tokens := &oauth2TokenSource{
+			cfg:    scrapeConfig.OAuth2,
+			client: client,
+		}
+		return func(req *http.Request) error {
+			token, err := tokens.token()
+			if err != nil {
+				return err
+			}
+			req.Header.Set("Authorization", "Bearer "+token)
+			return nil
+		}, nil
+	default:
+		return noAuth, nil
+	}
+}
+
+// buildOAuth2Client returns the client for the token endpoint. Unlike the
+// scrape client it verifies the token endpoint against the system roots and
+// the CA of the oauth2 config and does not present the scrape client
+// certificate.
+func (p *PromScraper) buildOAuth2Client(scrapeConfig scraper.PromScraperConfig) (*http.Client, error) {
+	var clientOptions []tlsconfig.ClientOption
+	if scrapeConfig.OAuth2.CaPath != "" {
+		clientOptions = append(clientOptions, tlsconfig.WithAuthorityBuilder(
+			tlsconfig.FromSystemPool(tlsconfig.WithCertsFromFile(scrapeConfig.OAuth2.CaPath)),
+		))
+	}
+
+	tlsConfig, err := tlsconfig.Build(tlsconfig.WithExternalServiceDefaults()).Client(clientOptions...)
+	if err != nil {
+		return nil, err
+	}
+
+	return &http.Client{
+		Timeout: scrapeTimeout(scrapeConfig),
+		Transport: &http.Transport{
+			TLSClientConfig: tlsConfig,
+		},
+	}, nil
+}
+
+func validateAuth(scrapeConfig scraper.PromScraperConfig) error {
+	methods := 0
+	for _, set := range []bool{
+		scrapeConfig.BearerTokenFile != "",
+		hasBasicAuth(scrapeConfig.BasicAuth),
+		hasOAuth2(scrapeConfig.OAuth2),
+	} {
+		if set {
+			methods++
+		}
+	}
+	if methods > 1 {
+		return errors.New("at most one of bearer_token_file, basic_auth and oauth2 may be set")
+	}
+
+	if scrapeConfig.BasicAuth.Password != "" && scrapeConfig.BasicAuth.PasswordFile != "" {
+		return errors.New("at most one of basic_auth password and password_file may be set")
+	}
+
+	oauth2 := scrapeConfig.OAuth2
+	if hasOAuth2(oauth2) {
+		if oauth2.ClientID == "" || oauth2.TokenURL == "" {
+			return errors.New("oauth2 client_id and token_url are required")
+		}
+		if oauth2.ClientSecret != "" && oauth2.ClientSecretFile != "" {
+			return errors.New("at most one of oauth2 client_secret and client_secret_file may be set")
+		}
+	}
+	return nil
+}
+
+func hasBasicAuth(b scraper.BasicAuth) bool {
+	return b.Username != "" || b.Password != "" || b.PasswordFile != ""
+}
+
+func hasOAuth2(o scraper.OAuth2Config) bool {
+	return o.ClientID != "" || o.TokenURL != ""
+}
+
+// readSecret returns the contents of the file without surrounding
+// whitespace or the value if no file is given.
+func readSecret(value, file string) (string, error) {
+	if file == "" {
+		return value, nil
+	}
+
+	content, err := os.ReadFile(file)
+	if err != nil {
+		return "", fmt.Errorf("unable to read secret file: %s", err)
+	}
+	return strings.TrimSpace(string(content)), nil
+}
+
+// oauth2TokenSource fetches access tokens with the client credentials grant
+// and caches them until shortly before they expire.
+type oauth2TokenSource struct {
+	cfg    scraper.OAuth2Config
+	client *http.Client
+
+	mu          sync.Mutex
+	accessToken string
+	expiry      time.Time
+}
+
+type tokenResponse struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	ExpiresIn   int64  `json:"expires_in"`
+}
+
+func (s *oauth2TokenSource) token() (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.accessToken != "" && (s.expiry.IsZero() || time.Now().Before(s.expiry)) {
+		return s.accessToken, nil
+	}
+
+	secret, err := readSecret(s.cfg.ClientSecret, s.cfg.ClientSecretFile)
+	if err != nil {
+		return "", err
+	}
+
+	form := url.Values{"grant_type": {"client_credentials"}}
+	if len(s.cfg.Scopes) > 0 {
+		form.Set("scope", strings.Join(s.cfg.Scopes, " "))
+	}
+	for k, v := range s.cfg.EndpointParams {
+		form.Set(k, v)
+	}
+
+	req, err := http.NewRequest(http.MethodPost, s.cfg.TokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.SetBasicAuth(url.QueryEscape(s.cfg.ClientID), url.QueryEscape(secret))
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("unable to fetch oauth2 token: %s", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return "", fmt.Errorf("unable to fetch oauth2 token: unexpected status code %d: %s", resp.StatusCode, body)
+	}
+
+	var t tokenResponse
+	if err := json.NewDecoder(resp.Body).Decode(&t); err != nil {
+		return "", fmt.Errorf("unable to decode oauth2 token: %s", err)
+	}
+	if t.AccessToken == "" {
+		return "", errors.New("oauth2 token response has no access_token")
+	}
+
+	s.accessToken = t.AccessToken
+	s.expiry = time.Time{}
+	if t.ExpiresIn > 0 {
+		s.expiry = time.Now().Add(time.Duration(t.ExpiresIn)*time.Second - tokenExpiryDelta)
+	}
+	return s.accessToken, nil
+}
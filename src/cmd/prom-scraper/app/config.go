@@ -5,6 +5,7 @@ import (
 	"time"
 
 	"code.cloudfoundry.org/loggregator-agent-release/src/pkg/config"
+	"code.cloudfoundry.org/loggregator-agent-release/src/pkg/scraper"
 
 	"code.cloudfoundry.org/go-envstruct"
 )
@@ -25,7 +26,16 @@ type Config struct {
 	DefaultSourceID        string        `env:"DEFAULT_SOURCE_ID, report, required"`
 	ConfigGlobs            []string      `env:"CONFIG_GLOBS, report"`
 	DefaultScrapeInterval  time.Duration `env:"SCRAPE_INTERVAL, report"`
+	ConfigReloadInterval   time.Duration `env:"CONFIG_RELOAD_INTERVAL, report"`
 	SkipSSLValidation      bool          `env:"SKIP_SSL_VALIDATION, report"`
+	BatchHistograms        bool          `env:"BATCH_HISTOGRAMS, report"`
+	BatchGauges            bool          `env:"BATCH_GAUGES, report"`
+	HonorTimestamps        bool          `env:"HONOR_TIMESTAMPS, report"`
+	EmitScrapeSeries       bool          `env:"EMIT_SCRAPE_SERIES, report"`
+	MaxConcurrentScrapes   int           `env:"MAX_CONCURRENT_SCRAPES, report"`
+	ScrapeBodySizeLimit    int64         `env:"SCRAPE_BODY_SIZE_LIMIT, report"`
+	FloatCounterStrategy   string        `env:"FLOAT_COUNTER_STRATEGY, report"`
+	FloatCounterScale      float64       `env:"FLOAT_COUNTER_SCALE_FACTOR, report"`
 
 	MetricsServer config.MetricsServer
 }
@@ -33,12 +43,24 @@ type Config struct {
 func LoadConfig(log *log.Logger) Config {
 	cfg := Config{
 		DefaultScrapeInterval: 15 * time.Second,
+		ConfigReloadInterval:  time.Minute,
+		FloatCounterStrategy:  scraper.FloatCounterDiscard,
+		FloatCounterScale:     1000,
 	}
 
 	if err := envstruct.Load(&cfg); err != nil {
 		log.Fatal(err)
 	}
 
+	switch cfg.FloatCounterStrategy {
+	case scraper.FloatCounterDiscard, scraper.FloatCounterGauge, scraper.FloatCounterScale:
+	default:
+		log.Fatalf("invalid float counter strategy %q", cfg.FloatCounterStrategy)
+	}
+	if cfg.FloatCounterScale <= 0 {
+		log.Fatalf("invalid float counter scale factor %v", cfg.FloatCounterScale)
+	}
+
 	envstruct.WriteReport(&cfg) //nolint:errcheck
 
 	return cfg
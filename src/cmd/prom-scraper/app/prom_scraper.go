@@ -2,11 +2,17 @@ package app
 
 import (
 	"crypto/tls"
+	"errors"
 	"fmt"
+	"hash/fnv"
+	"io"
 	"log"
 	"net/http"
+	"os"
+	"os/signal"
 	"strings"
 	"sync"
+	"syscall"
 	"time"
 
 	metrics "code.cloudfoundry.org/go-metric-registry"
@@ -15,6 +21,7 @@ import (
 	_ "net/http/pprof" //nolint:gosec
 
 	"code.cloudfoundry.org/go-loggregator/v9"
+	"code.cloudfoundry.org/loggregator-agent-release/src/pkg/plumbing"
 	"code.cloudfoundry.org/loggregator-agent-release/src/pkg/scraper"
 )
 
@@ -26,27 +33,49 @@ type PromScraper struct {
 	stop                 chan struct{}
 	wg                   sync.WaitGroup
 	m                    promRegistry
-	scrapeTargetTotals   metrics.Counter
+	scrapeTargetTotals   metrics.Gauge
+	skippedConfigs       metrics.Counter
+
+	mu       sync.Mutex
+	stopped  bool
+	scrapers map[string]chan struct{}
+
+	// scrapeSlots limits the number of concurrent scrapes. It is nil if
+	// the number is not limited.
+	scrapeSlots chan struct{}
 }
 
 type ConfigProvider func() ([]scraper.PromScraperConfig, error)
 
 type promRegistry interface {
+	NewGauge(name, helpText string, opts ...metrics.MetricOption) metrics.Gauge
 	NewCounter(name, helpText string, opts ...metrics.MetricOption) metrics.Counter
+	RemoveGauge(g metrics.Gauge)
 	RegisterDebugMetrics()
 }
 
 func NewPromScraper(cfg Config, configProvider ConfigProvider, m promRegistry, log *log.Logger) *PromScraper {
+	var scrapeSlots chan struct{}
+	if cfg.MaxConcurrentScrapes > 0 {
+		scrapeSlots = make(chan struct{}, cfg.MaxConcurrentScrapes)
+	}
+
 	return &PromScraper{
 		scrapeConfigProvider: configProvider,
 		cfg:                  cfg,
 		log:                  log,
 		stop:                 make(chan struct{}),
+		scrapers:             make(map[string]chan struct{}),
+		scrapeSlots:          scrapeSlots,
 
 		m: m,
-		scrapeTargetTotals: m.NewCounter(
+		scrapeTargetTotals: m.NewGauge(
 			"scrape_targets_total",
-			"Total number of scrape targets identified from prom scraper config files.",
+			"Number of scrape targets identified from prom scraper config files.",
+		),
+		skippedConfigs: m.NewCounter(
+			"skipped_scrape_configs_total",
+			"Total number of invalid scrape configs skipped when loading the prom scraper config files.",
 		),
 	}
 }
@@ -70,33 +99,172 @@ func (p *PromScraper) Run() {
 
 	client := p.buildIngressClient()
 
-	p.startScrapers(promScraperConfigs, client)
-	p.scrapeTargetTotals.Add(float64(len(promScraperConfigs)))
-	p.wg.Wait()
+	p.updateScrapers(promScraperConfigs, client)
+
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+
+	var reload <-chan time.Time
+	if p.cfg.ConfigReloadInterval > 0 {
+		ticker := time.NewTicker(p.cfg.ConfigReloadInterval)
+		defer ticker.Stop()
+		reload = ticker.C
+	}
+
+	for {
+		select {
+		case <-reload:
+			p.reloadConfigs(client)
+		case <-hup:
+			p.log.Printf("reloading scrape configs on SIGHUP")
+			p.reloadConfigs(client)
+		case <-p.stop:
+			return
+		}
+	}
+}
+
+// reloadConfigs rescans the scrape configs. Scrapers are started for new
+// configs and stopped for removed ones. Changed configs restart their
+// scraper.
+func (p *PromScraper) reloadConfigs(client *loggregator.IngressClient) {
+	promScraperConfigs, err := p.scrapeConfigProvider()
+	if err != nil {
+		p.log.Printf("failed to reload scrape configs: %s", err)
+		return
+	}
+
+	var valid []scraper.PromScraperConfig
+	for _, scrapeConfig := range promScraperConfigs {
+		if err := p.validateConfig(scrapeConfig); err != nil {
+			p.skipConfig(err)
+			continue
+		}
+		valid = append(valid, scrapeConfig)
+	}
+
+	p.updateScrapers(valid, client)
+}
+
+func (p *PromScraper) updateScrapers(promScraperConfigs []scraper.PromScraperConfig, client *loggregator.IngressClient) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.stopped {
+		return
+	}
+
+	current := make(map[string]scraper.PromScraperConfig)
+	for _, scrapeConfig := range promScraperConfigs {
+		if scrapeConfig.ScrapeInterval > 0 {
+			current[scraperKey(scrapeConfig)] = scrapeConfig
+		}
+	}
+
+	for key, stop := range p.scrapers {
+		if _, ok := current[key]; !ok {
+			close(stop)
+			delete(p.scrapers, key)
+		}
+	}
+
+	skipped := 0
+	for key, scrapeConfig := range current {
+		if _, ok := p.scrapers[key]; ok {
+			continue
+		}
+		s, err := p.buildScraper(scrapeConfig, client)
+		if err != nil {
+			p.skipConfig(fmt.Errorf("failed to build scraper for scrape config (%s): %s", scrapeConfig.SourceID, err))
+			skipped++
+			continue
+		}
+		stop := make(chan struct{})
+		p.scrapers[key] = stop
+		p.wg.Add(1)
+		go p.startScraper(scrapeConfig, s, stop)
+	}
+
+	p.scrapeTargetTotals.Set(float64(len(promScraperConfigs) - skipped))
+}
+
+// skipConfig logs and counts a scrape config that is not scraped because
+// it is invalid. It is picked up again once it changes.
+func (p *PromScraper) skipConfig(err error) {
+	p.log.Printf("%s - skipping this config", err)
+	p.skippedConfigs.Add(1)
+}
+
+// scraperKey identifies a scrape config. A change to any of its fields
+// restarts its scraper.
+func scraperKey(scrapeConfig scraper.PromScraperConfig) string {
+	return fmt.Sprintf("%+v", scrapeConfig)
 }
 
 func (p *PromScraper) validateConfigs(scrapeConfigs []scraper.PromScraperConfig) {
 	for _, scrapeConfig := range scrapeConfigs {
-		if p.isMTLSTargetMissingServerName(scrapeConfig) {
-			p.log.Panicf("server_name is missing from mTLS scrape config (%s)", scrapeConfig.SourceID)
+		if err := p.validateConfig(scrapeConfig); err != nil {
+			p.log.Panic(err)
 		}
 	}
 }
 
+func (p *PromScraper) validateConfig(scrapeConfig scraper.PromScraperConfig) error {
+	if p.isMTLSTargetMissingServerName(scrapeConfig) {
+		return fmt.Errorf("server_name is missing from mTLS scrape config (%s)", scrapeConfig.SourceID)
+	}
+	if _, err := scraper.NewRelabeler(scrapeConfig.MetricRelabelConfigs); err != nil {
+		return fmt.Errorf("invalid metric_relabel_configs in scrape config (%s): %s", scrapeConfig.SourceID, err)
+	}
+	if err := validateAuth(scrapeConfig); err != nil {
+		return fmt.Errorf("invalid authentication in scrape config (%s): %s", scrapeConfig.SourceID, err)
+	}
+	if err := validateDNSSD(scrapeConfig.DNSSDConfigs); err != nil {
+		return fmt.Errorf("invalid dns_sd_configs in scrape config (%s): %s", scrapeConfig.SourceID, err)
+	}
+	if _, err := p.buildHttpClient(scrapeConfig); err != nil {
+		return fmt.Errorf("invalid TLS settings in scrape config (%s): %s", scrapeConfig.SourceID, err)
+	}
+	if hasOAuth2(scrapeConfig.OAuth2) {
+		if _, err := p.buildOAuth2Client(scrapeConfig); err != nil {
+			return fmt.Errorf("invalid oauth2 TLS settings in scrape config (%s): %s", scrapeConfig.SourceID, err)
+		}
+	}
+	return nil
+}
+
+func validateDNSSD(configs []scraper.DNSSDConfig) error {
+	for _, sd := range configs {
+		if len(sd.Names) == 0 && sd.RecordsFile == "" {
+			return errors.New("names or records_file is required")
+		}
+		if sd.RecordsFile != "" && (sd.Port <= 0 || sd.Port > 65535) {
+			return fmt.Errorf("invalid port %d for records_file", sd.Port)
+		}
+	}
+	return nil
+}
+
 func (p *PromScraper) isMTLSTargetMissingServerName(scraperConfig scraper.PromScraperConfig) bool {
 	return p.cfg.ScrapeCertPath != "" && scraperConfig.Scheme == "https" && scraperConfig.ServerName == ""
 }
 
 func (p *PromScraper) buildIngressClient() *loggregator.IngressClient {
-	creds, err := loggregator.NewIngressTLSConfig(
-		p.cfg.CACertPath,
+	certs, err := plumbing.NewCertificateProvider(
 		p.cfg.ClientCertPath,
 		p.cfg.ClientKeyPath,
+		p.cfg.CACertPath,
+		plumbing.WithExpiryMetrics(p.m, "ingress_client"),
 	)
 	if err != nil {
 		p.log.Fatal(err)
 	}
 
+	creds, err := certs.ClientConfig("metron")
+	if err != nil {
+		p.log.Fatal(err)
+	}
+
 	client, err := loggregator.NewIngressClient(
 		creds,
 		loggregator.WithAddr(p.cfg.LoggregatorIngressAddr),
@@ -109,19 +277,21 @@ func (p *PromScraper) buildIngressClient() *loggregator.IngressClient {
 	return client
 }
 
-func (p *PromScraper) startScrapers(promScraperConfigs []scraper.PromScraperConfig, ingressClient *loggregator.IngressClient) {
-	for _, scrapeConfig := range promScraperConfigs {
-		if scrapeConfig.ScrapeInterval > 0 {
-			p.wg.Add(1)
-			go p.startScraper(scrapeConfig, ingressClient)
-		}
-	}
-}
-
-func (p *PromScraper) startScraper(scrapeConfig scraper.PromScraperConfig, ingressClient *loggregator.IngressClient) {
+func (p *PromScraper) startScraper(scrapeConfig scraper.PromScraperConfig, s *scraper.Scraper, stop chan struct{}) {
 	defer p.wg.Done()
 
-	s := p.buildScraper(scrapeConfig, ingressClient)
+	// Spread the scrapes of the configs across the interval instead of
+	// scraping all targets at the same time.
+	offset := time.NewTimer(scrapeOffset(scrapeConfig))
+	defer offset.Stop()
+	select {
+	case <-offset.C:
+	case <-stop:
+		return
+	case <-p.stop:
+		return
+	}
+
 	ticker := time.NewTicker(scrapeConfig.ScrapeInterval)
 	defer ticker.Stop()
 
@@ -143,48 +313,132 @@ func (p *PromScraper) startScraper(scrapeConfig scraper.PromScraperConfig, ingre
 				hadError = false
 				p.log.Printf("%s has recovered", scrapeConfig.InstanceID)
 			}
+		case <-stop:
+			return
 		case <-p.stop:
 			return
 		}
 	}
 }
 
-func (p *PromScraper) buildScraper(scrapeConfig scraper.PromScraperConfig, client *loggregator.IngressClient) *scraper.Scraper {
+func (p *PromScraper) buildScraper(scrapeConfig scraper.PromScraperConfig, client *loggregator.IngressClient) (*scraper.Scraper, error) {
 	scrapeTarget := scraper.Target{
 		ID:          scrapeConfig.SourceID,
 		InstanceID:  scrapeConfig.InstanceID,
 		MetricURL:   fmt.Sprintf("%s://127.0.0.1:%s/%s", scrapeConfig.Scheme, scrapeConfig.Port, strings.TrimPrefix(scrapeConfig.Path, "/")),
 		Headers:     scrapeConfig.Headers,
 		DefaultTags: scrapeConfig.Labels,
+		SampleLimit: scrapeConfig.SampleLimit,
+	}
+
+	// The relabel configs are validated before the scraper is started.
+	relabeler, _ := scraper.NewRelabeler(scrapeConfig.MetricRelabelConfigs)
+	if len(scrapeConfig.MetricRelabelConfigs) > 0 {
+		scrapeTarget.Relabeler = relabeler
 	}
 
-	httpClient := p.buildHttpClient(scrapeConfig)
+	httpClient, err := p.buildHttpClient(scrapeConfig)
+	if err != nil {
+		return nil, err
+	}
+	authorize, err := p.authorizer(scrapeConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	targetProvider := func() []scraper.Target {
+		return []scraper.Target{scrapeTarget}
+	}
+	if len(scrapeConfig.FileSDConfigs) > 0 || len(scrapeConfig.DNSSDConfigs) > 0 {
+		targetProvider = p.sdTargetProvider(scrapeConfig, scrapeTarget)
+	}
 
 	return scraper.New(
-		func() []scraper.Target {
-			return []scraper.Target{scrapeTarget}
-		},
+		targetProvider,
 		client,
-		p.scrape(httpClient),
+		p.scrape(httpClient, authorize),
 		p.cfg.DefaultSourceID,
-	)
+		scraper.WithBatchedHistograms(p.cfg.BatchHistograms),
+		scraper.WithBatchedGauges(p.cfg.BatchGauges),
+		scraper.WithHonorTimestamps(p.cfg.HonorTimestamps),
+		scraper.WithScrapeSeries(p.cfg.EmitScrapeSeries),
+		scraper.WithDroppedSeriesMetrics(p.m),
+		scraper.WithFloatCounters(p.cfg.FloatCounterStrategy, p.cfg.FloatCounterScale),
+	), nil
+}
+
+// scrapeOffset returns a deterministic offset within the scrape interval of
+// the config.
+func scrapeOffset(scrapeConfig scraper.PromScraperConfig) time.Duration {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(scraperKey(scrapeConfig)))
+	return time.Duration(h.Sum64() % uint64(scrapeConfig.ScrapeInterval))
+}
+
+// scrapeTimeout returns the scrape timeout of the config. It defaults to and
+// is capped at the scrape interval.
+func scrapeTimeout(scrapeConfig scraper.PromScraperConfig) time.Duration {
+	if scrapeConfig.ScrapeTimeout <= 0 || scrapeConfig.ScrapeTimeout > scrapeConfig.ScrapeInterval {
+		return scrapeConfig.ScrapeInterval
+	}
+	return scrapeConfig.ScrapeTimeout
 }
 
-func (p *PromScraper) buildHttpClient(scrapeConfig scraper.PromScraperConfig) *http.Client {
+// sdTargetProvider returns the targets discovered by the file_sd and dns_sd
+// configs of the scrape config. The targets inherit the scheme, path,
+// headers, labels and relabeling of the scrape config.
+func (p *PromScraper) sdTargetProvider(scrapeConfig scraper.PromScraperConfig, defaults scraper.Target) scraper.TargetProvider {
+	opts := func(extra ...scraper.ProviderOption) []scraper.ProviderOption {
+		return append([]scraper.ProviderOption{
+			scraper.WithScheme(scrapeConfig.Scheme),
+			scraper.WithMetricsPath(scrapeConfig.Path),
+			scraper.WithProviderLogger(p.log),
+			scraper.WithTargetDefaults(defaults),
+		}, extra...)
+	}
+
+	var providers []scraper.TargetProvider
+	for _, sd := range scrapeConfig.FileSDConfigs {
+		var extra []scraper.ProviderOption
+		if sd.RefreshInterval > 0 {
+			extra = append(extra, scraper.WithRefreshInterval(sd.RefreshInterval))
+		}
+		providers = append(providers, scraper.NewFileSDTargetProvider(sd.Files, opts(extra...)...))
+	}
+	for _, sd := range scrapeConfig.DNSSDConfigs {
+		for _, name := range sd.Names {
+			providers = append(providers, scraper.NewSRVScrapeTargetProvider(scrapeConfig.SourceID, name, opts()...))
+		}
+		if sd.RecordsFile != "" {
+			providers = append(providers, scraper.NewDNSScrapeTargetProvider(scrapeConfig.SourceID, sd.RecordsFile, sd.Port, opts()...))
+		}
+	}
+
+	return func() []scraper.Target {
+		var targets []scraper.Target
+		for _, provider := range providers {
+			targets = append(targets, provider()...)
+		}
+		return targets
+	}
+}
+
+func (p *PromScraper) buildHttpClient(scrapeConfig scraper.PromScraperConfig) (*http.Client, error) {
 	tlsOptions := p.tlsOptions(scrapeConfig)
 	clientOptions := p.clientOptions(scrapeConfig)
 	tlsConfig, err := tlsconfig.Build(tlsOptions...).Client(clientOptions...)
 	if err != nil {
-		p.log.Fatal(err)
+		return nil, err
 	}
 
 	return &http.Client{
+		Timeout: scrapeTimeout(scrapeConfig),
 		Transport: &http.Transport{
 			TLSClientConfig: tlsConfig,
 			MaxIdleConns:    1,
 			IdleConnTimeout: scrapeConfig.ScrapeInterval,
 		},
-	}
+	}, nil
 }
 
 func (p *PromScraper) clientOptions(scrapeConfig scraper.PromScraperConfig) []tlsconfig.ClientOption {
@@ -220,14 +474,18 @@ func (p *PromScraper) tlsOptions(scrapeConfig scraper.PromScraperConfig) []tlsco
 
 // Stops cancel future scrapes and wait for any current scrapes to complete
 func (p *PromScraper) Stop() {
+	p.mu.Lock()
+	p.stopped = true
 	close(p.stop)
+	p.mu.Unlock()
+
 	p.wg.Wait()
 	if p.pprofServer != nil {
 		p.pprofServer.Close()
 	}
 }
 
-func (p *PromScraper) scrape(client *http.Client) scraper.MetricsGetter {
+func (p *PromScraper) scrape(client *http.Client, authorize authorizer) scraper.MetricsGetter {
 	return func(addr string, headers map[string]string) (*http.Response, error) {
 		req, err := http.NewRequest(http.MethodGet, addr, nil)
 		if err != nil {
@@ -239,9 +497,70 @@ func (p *PromScraper) scrape(client *http.Client) scraper.MetricsGetter {
 			requestHeader[k] = []string{v}
 		}
 		req.Header = requestHeader
+		if err := authorize(req); err != nil {
+			return nil, err
+		}
+
+		release, err := p.acquireScrapeSlot()
+		if err != nil {
+			return nil, err
+		}
+
+		resp, err := client.Do(req)
+		if err != nil {
+			release()
+			return nil, err
+		}
+
+		resp.Body = &scrapeBody{
+			ReadCloser: resp.Body,
+			limit:      p.cfg.ScrapeBodySizeLimit,
+			release:    release,
+		}
+		return resp, nil
+	}
+}
+
+// acquireScrapeSlot blocks until fewer than MaxConcurrentScrapes scrapes are
+// running. The returned func releases the slot.
+func (p *PromScraper) acquireScrapeSlot() (func(), error) {
+	if p.scrapeSlots == nil {
+		return func() {}, nil
+	}
 
-		return client.Do(req)
+	select {
+	case p.scrapeSlots <- struct{}{}:
+	case <-p.stop:
+		return nil, errors.New("prom scraper is stopped")
 	}
+
+	var once sync.Once
+	return func() {
+		once.Do(func() { <-p.scrapeSlots })
+	}, nil
+}
+
+// scrapeBody fails reads once more than limit bytes are read and releases
+// the scrape slot when it is closed. A limit of zero means no limit.
+type scrapeBody struct {
+	io.ReadCloser
+	limit   int64
+	read    int64
+	release func()
+}
+
+func (b *scrapeBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.read += int64(n)
+	if b.limit > 0 && b.read > b.limit {
+		return 0, fmt.Errorf("body size limit of %d bytes exceeded", b.limit)
+	}
+	return n, err
+}
+
+func (b *scrapeBody) Close() error {
+	defer b.release()
+	return b.ReadCloser.Close()
 }
 
 func withSkipSSLValidation(skipSSLValidation bool) tlsconfig.ClientOption {
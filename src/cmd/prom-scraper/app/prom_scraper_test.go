@@ -1,12 +1,15 @@
 package app_test
 
 import (
+	"crypto/tls"
 	"fmt"
 	"log"
 	"net"
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"path/filepath"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -268,6 +271,78 @@ var _ = Describe("PromScraper", func() {
 				Eventually(promServer.requestPaths).Should(Receive(Equal("/other/metrics/endpoint")))
 			})
 		})
+
+		It("scrapes targets discovered by file_sd_configs", func() {
+			sdFile := filepath.Join(metricConfigDir, "targets.json")
+			Expect(os.WriteFile(sdFile, []byte(fmt.Sprintf(
+				`[{"targets": ["127.0.0.1:%s"], "labels": {"instance_id": "discovered-instance", "az": "z1"}}]`,
+				promServer.port,
+			)), 0600)).To(Succeed())
+
+			spyConfigProvider.scrapeConfigs = []scraper.PromScraperConfig{{
+				SourceID: "some-id",
+				Scheme:   "http",
+				Path:     "/metrics",
+				FileSDConfigs: []scraper.FileSDConfig{{
+					Files: []string{filepath.Join(metricConfigDir, "*.json")},
+				}},
+			}}
+			promServer.resp = promOutput
+
+			ps = app.NewPromScraper(cfg, spyConfigProvider.Configs, metricClient, testLogger)
+			go ps.Run()
+
+			Eventually(func() int {
+				return len(spyAgent.Envelopes())
+			}).Should(BeNumerically(">=", 5))
+
+			for _, env := range spyAgent.Envelopes() {
+				Expect(env.SourceId).To(Equal("some-id"))
+				Expect(env.InstanceId).To(Equal("discovered-instance"))
+				Expect(env.Tags).To(HaveKeyWithValue("az", "z1"))
+			}
+			Expect(promServer.requestPaths).To(Receive(Equal("/metrics")))
+		})
+
+		It("scrapes targets discovered by dns_sd_configs", func() {
+			recordsFile := filepath.Join(metricConfigDir, "records.json")
+			Expect(os.WriteFile(recordsFile, []byte(`{"records": [["127.0.0.1", "q-0.web.bosh"]]}`), 0600)).To(Succeed())
+			port, err := strconv.Atoi(promServer.port)
+			Expect(err).ToNot(HaveOccurred())
+
+			spyConfigProvider.scrapeConfigs = []scraper.PromScraperConfig{{
+				SourceID: "some-id",
+				Scheme:   "http",
+				Path:     "/metrics",
+				DNSSDConfigs: []scraper.DNSSDConfig{{
+					RecordsFile: recordsFile,
+					Port:        port,
+				}},
+			}}
+			promServer.resp = promOutput
+
+			ps = app.NewPromScraper(cfg, spyConfigProvider.Configs, metricClient, testLogger)
+			go ps.Run()
+
+			Eventually(func() int {
+				return len(spyAgent.Envelopes())
+			}).Should(BeNumerically(">=", 5))
+
+			for _, env := range spyAgent.Envelopes() {
+				Expect(env.SourceId).To(Equal("some-id"))
+				Expect(env.InstanceId).To(Equal("127.0.0.1:" + promServer.port))
+			}
+		})
+
+		It("does not scrape if a dns_sd_config has neither names nor a records file", func() {
+			spyConfigProvider.scrapeConfigs = []scraper.PromScraperConfig{{
+				SourceID:     "some-id",
+				DNSSDConfigs: []scraper.DNSSDConfig{{Port: 9100}},
+			}}
+
+			ps = app.NewPromScraper(cfg, spyConfigProvider.Configs, metricClient, testLogger)
+			Expect(ps.Run).To(Panic())
+		})
 	})
 
 	Context("https", func() {
@@ -394,8 +469,401 @@ var _ = Describe("PromScraper", func() {
 		})
 	})
 
+	Context("scrape limits", func() {
+		var buf *spyBuffer
+
+		BeforeEach(func() {
+			buf = &spyBuffer{}
+			testLogger.SetOutput(buf)
+			promServer = newStubPromServer()
+			promServer.resp = promOutput
+		})
+
+		AfterEach(func() {
+			testLogger.SetOutput(GinkgoWriter)
+		})
+
+		It("times out scrapes after the scrape timeout", func() {
+			promServer.delay = time.Second
+			spyConfigProvider.scrapeConfigs = []scraper.PromScraperConfig{{
+				Port:          promServer.port,
+				SourceID:      "some-id",
+				InstanceID:    "some-instance-id",
+				ScrapeTimeout: 20 * time.Millisecond,
+			}}
+
+			ps = app.NewPromScraper(cfg, spyConfigProvider.Configs, metricClient, testLogger)
+			go ps.Run()
+
+			Eventually(buf.Read).Should(ContainSubstring("Client.Timeout exceeded"))
+			Expect(spyAgent.Envelopes()).ToNot(
+				ContainElement(buildCounter("test_counter_prometheus_1", "some-id", "some-instance-id", 1)),
+			)
+		})
+
+		It("fails scrapes with bodies larger than the body size limit", func() {
+			cfg.ScrapeBodySizeLimit = 10
+			spyConfigProvider.scrapeConfigs = []scraper.PromScraperConfig{{
+				Port:       promServer.port,
+				SourceID:   "some-id",
+				InstanceID: "some-instance-id",
+			}}
+
+			ps = app.NewPromScraper(cfg, spyConfigProvider.Configs, metricClient, testLogger)
+			go ps.Run()
+
+			Eventually(buf.Read).Should(ContainSubstring("body size limit of 10 bytes exceeded"))
+			Expect(spyAgent.Envelopes()).ToNot(
+				ContainElement(buildCounter("test_counter_prometheus_1", "some-id", "some-instance-id", 1)),
+			)
+		})
+
+		It("limits the number of concurrent scrapes", func() {
+			cfg.MaxConcurrentScrapes = 1
+			promServer.delay = 150 * time.Millisecond
+			spyConfigProvider.scrapeConfigs = []scraper.PromScraperConfig{
+				{
+					Port:           promServer.port,
+					SourceID:       "some-id",
+					InstanceID:     "some-instance-id",
+					ScrapeInterval: 200 * time.Millisecond,
+				},
+				{
+					Port:           promServer.port,
+					SourceID:       "other-id",
+					InstanceID:     "some-instance-id",
+					ScrapeInterval: 200 * time.Millisecond,
+				},
+			}
+
+			ps = app.NewPromScraper(cfg, spyConfigProvider.Configs, metricClient, testLogger)
+			go ps.Run()
+
+			Eventually(spyAgent.Envelopes, 2).Should(And(
+				ContainElement(buildCounter("test_counter_prometheus_1", "some-id", "some-instance-id", 1)),
+				ContainElement(buildCounter("test_counter_prometheus_1", "other-id", "some-instance-id", 1)),
+			))
+			Consistently(promServer.maxConcurrentRequests, 500*time.Millisecond).Should(Equal(1))
+		})
+	})
+
+	Context("authentication", func() {
+		BeforeEach(func() {
+			promServer = newStubPromServer()
+			promServer.resp = promOutput
+		})
+
+		writeSecret := func(name, content string) string {
+			path := filepath.Join(metricConfigDir, name)
+			Expect(os.WriteFile(path, []byte(content), 0600)).To(Succeed())
+			return path
+		}
+
+		It("rereads the bearer token file on each scrape", func() {
+			tokenFile := writeSecret("token", "token-1\n")
+			spyConfigProvider.scrapeConfigs = []scraper.PromScraperConfig{{
+				Port:            promServer.port,
+				SourceID:        "some-id",
+				InstanceID:      "some-instance-id",
+				BearerTokenFile: tokenFile,
+			}}
+
+			ps = app.NewPromScraper(cfg, spyConfigProvider.Configs, metricClient, testLogger)
+			go ps.Run()
+
+			Eventually(promServer.requestHeaders).Should(Receive(
+				HaveKeyWithValue("Authorization", []string{"Bearer token-1"}),
+			))
+
+			writeSecret("token", "token-2")
+			Eventually(promServer.requestHeaders).Should(Receive(
+				HaveKeyWithValue("Authorization", []string{"Bearer token-2"}),
+			))
+		})
+
+		It("scrapes with basic auth", func() {
+			spyConfigProvider.scrapeConfigs = []scraper.PromScraperConfig{{
+				Port:       promServer.port,
+				SourceID:   "some-id",
+				InstanceID: "some-instance-id",
+				BasicAuth: scraper.BasicAuth{
+					Username:     "user",
+					PasswordFile: writeSecret("password", "secret\n"),
+				},
+			}}
+
+			ps = app.NewPromScraper(cfg, spyConfigProvider.Configs, metricClient, testLogger)
+			go ps.Run()
+
+			Eventually(promServer.requestHeaders).Should(Receive(
+				HaveKeyWithValue("Authorization", []string{"Basic dXNlcjpzZWNyZXQ="}),
+			))
+		})
+
+		It("scrapes with an oauth2 access token", func() {
+			var (
+				mu            sync.Mutex
+				tokenRequests []*http.Request
+			)
+			tokenServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+				Expect(req.ParseForm()).To(Succeed())
+				mu.Lock()
+				tokenRequests = append(tokenRequests, req)
+				mu.Unlock()
+
+				w.Header().Set("Content-Type", "application/json")
+				_, _ = w.Write([]byte(`{"access_token": "some-token", "token_type": "Bearer", "expires_in": 3600}`))
+			}))
+			defer tokenServer.Close()
+
+			spyConfigProvider.scrapeConfigs = []scraper.PromScraperConfig{{
+				Port:       promServer.port,
+				SourceID:   "some-id",
+				InstanceID: "some-instance-id",
+				OAuth2: scraper.OAuth2Config{
+					ClientID:         "client",
+					ClientSecretFile: writeSecret("client-secret", "secret"),
+					TokenURL:         tokenServer.URL,
+					Scopes:           []string{"metrics.read", "metrics.admin"},
+					EndpointParams:   map[string]string{"audience": "exporter"},
+				},
+			}}
+
+			ps = app.NewPromScraper(cfg, spyConfigProvider.Configs, metricClient, testLogger)
+			go ps.Run()
+
+			Eventually(promServer.requestHeaders).Should(Receive(
+				HaveKeyWithValue("Authorization", []string{"Bearer some-token"}),
+			))
+			Eventually(promServer.requestHeaders).Should(Receive(
+				HaveKeyWithValue("Authorization", []string{"Bearer some-token"}),
+			))
+
+			mu.Lock()
+			defer mu.Unlock()
+			Expect(tokenRequests).To(HaveLen(1))
+			user, password, ok := tokenRequests[0].BasicAuth()
+			Expect(ok).To(BeTrue())
+			Expect(user).To(Equal("client"))
+			Expect(password).To(Equal("secret"))
+			Expect(tokenRequests[0].PostForm.Get("grant_type")).To(Equal("client_credentials"))
+			Expect(tokenRequests[0].PostForm.Get("scope")).To(Equal("metrics.read metrics.admin"))
+			Expect(tokenRequests[0].PostForm.Get("audience")).To(Equal("exporter"))
+		})
+
+		It("fetches oauth2 tokens from https token endpoints on other hosts", func() {
+			promServer = newStubHttpsPromServer(testLogger, scrapeCerts, true)
+			promServer.resp = promOutput
+			cfg.SkipSSLValidation = false
+			cfg.ScrapeCertPath = scrapeCerts.Cert("client")
+			cfg.ScrapeKeyPath = scrapeCerts.Key("client")
+			cfg.ScrapeCACertPath = scrapeCerts.CA()
+
+			tokenCerts := testhelper.GenerateCerts("tokenCA")
+			clientCerts := make(chan int, 100)
+			tokenServer := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+				clientCerts <- len(req.TLS.PeerCertificates)
+				w.Header().Set("Content-Type", "application/json")
+				_, _ = w.Write([]byte(`{"access_token": "some-token", "token_type": "Bearer", "expires_in": 3600}`))
+			}))
+			serverConf, err := tlsconfig.Build(
+				tlsconfig.WithIdentityFromFile(tokenCerts.Cert("localhost"), tokenCerts.Key("localhost")),
+			).Server()
+			Expect(err).ToNot(HaveOccurred())
+			serverConf.ClientAuth = tls.RequestClientCert
+			tokenServer.TLS = serverConf
+			tokenServer.Config.ErrorLog = testLogger
+			tokenServer.StartTLS()
+			defer tokenServer.Close()
+
+			_, tokenPort, err := net.SplitHostPort(tokenServer.Listener.Addr().String())
+			Expect(err).ToNot(HaveOccurred())
+
+			spyConfigProvider.scrapeConfigs = []scraper.PromScraperConfig{{
+				Port:       promServer.port,
+				SourceID:   "some-id",
+				InstanceID: "some-instance-id",
+				Scheme:     "https",
+				ServerName: "server",
+				OAuth2: scraper.OAuth2Config{
+					ClientID:     "client",
+					ClientSecret: "secret",
+					TokenURL:     fmt.Sprintf("https://localhost:%s/oauth/token", tokenPort),
+					CaPath:       tokenCerts.CA(),
+				},
+			}}
+
+			ps = app.NewPromScraper(cfg, spyConfigProvider.Configs, metricClient, testLogger)
+			go ps.Run()
+
+			Eventually(promServer.requestHeaders).Should(Receive(
+				HaveKeyWithValue("Authorization", []string{"Bearer some-token"}),
+			))
+			Expect(clientCerts).To(Receive(Equal(0)))
+		})
+
+		It("does not scrape if more than one authentication method is given", func() {
+			spyConfigProvider.scrapeConfigs = []scraper.PromScraperConfig{{
+				Port:            promServer.port,
+				SourceID:        "some-id",
+				InstanceID:      "some-instance-id",
+				BearerTokenFile: writeSecret("token", "token"),
+				BasicAuth:       scraper.BasicAuth{Username: "user", Password: "secret"},
+			}}
+
+			ps = app.NewPromScraper(cfg, spyConfigProvider.Configs, metricClient, testLogger)
+			Expect(ps.Run).To(Panic())
+		})
+	})
+
+	Context("reloading configs", func() {
+		var promServer2 *stubPromServer
+
+		BeforeEach(func() {
+			promServer = newStubPromServer()
+			promServer.resp = promOutput
+			promServer2 = newStubPromServer()
+			promServer2.resp = promOutput2
+
+			spyConfigProvider.setScrapeConfigs([]scraper.PromScraperConfig{{
+				Port:       promServer.port,
+				SourceID:   "some-id",
+				InstanceID: "some-instance-id",
+			}})
+			cfg.ConfigReloadInterval = 50 * time.Millisecond
+		})
+
+		It("starts scrapers for added configs and stops removed ones", func() {
+			ps = app.NewPromScraper(cfg, spyConfigProvider.Configs, metricClient, testLogger)
+			go ps.Run()
+
+			Eventually(spyAgent.Envelopes).Should(
+				ContainElement(buildCounter("test_counter_prometheus_1", "some-id", "some-instance-id", 1)),
+			)
+			Eventually(func() float64 {
+				return metricClient.GetMetricValue("scrape_targets_total", map[string]string{})
+			}).Should(Equal(1.0))
+
+			spyConfigProvider.setScrapeConfigs([]scraper.PromScraperConfig{{
+				Port:       promServer2.port,
+				SourceID:   "some-id",
+				InstanceID: "some-instance-id",
+			}})
+
+			Eventually(spyAgent.Envelopes).Should(
+				ContainElement(buildCounter("test_counter_prometheus_2", "some-id", "some-instance-id", 3)),
+			)
+
+			// Let any in-flight scrape of the removed target finish.
+			time.Sleep(200 * time.Millisecond)
+			Eventually(promServer.requestPaths).ShouldNot(Receive())
+			Consistently(promServer.requestPaths, 500*time.Millisecond).ShouldNot(Receive())
+		})
+
+		It("restarts scrapers for changed configs", func() {
+			ps = app.NewPromScraper(cfg, spyConfigProvider.Configs, metricClient, testLogger)
+			go ps.Run()
+
+			Eventually(promServer.requestPaths).Should(Receive(Equal("/metrics")))
+
+			spyConfigProvider.setScrapeConfigs([]scraper.PromScraperConfig{{
+				Port:       promServer.port,
+				SourceID:   "some-id",
+				InstanceID: "some-instance-id",
+				Path:       "other/metrics",
+			}})
+
+			Eventually(promServer.requestPaths).Should(Receive(Equal("/other/metrics")))
+		})
+
+		It("skips invalid configs", func() {
+			cfg.ScrapeCertPath = scrapeCerts.Cert("client")
+			ps = app.NewPromScraper(cfg, spyConfigProvider.Configs, metricClient, testLogger)
+			go ps.Run()
+
+			Eventually(promServer.requestPaths).Should(Receive())
+
+			spyConfigProvider.setScrapeConfigs([]scraper.PromScraperConfig{
+				{
+					Port:       promServer.port,
+					SourceID:   "some-id",
+					InstanceID: "some-instance-id",
+				},
+				{
+					Port:       promServer2.port,
+					SourceID:   "some-id",
+					InstanceID: "some-instance-id",
+					Scheme:     "https",
+				},
+			})
+
+			Consistently(promServer2.requestPaths, 500*time.Millisecond).ShouldNot(Receive())
+			Expect(promServer.requestPaths).To(Receive())
+		})
+
+		It("skips configs with TLS files that cannot be loaded", func() {
+			ps = app.NewPromScraper(cfg, spyConfigProvider.Configs, metricClient, testLogger)
+			go ps.Run()
+
+			Eventually(promServer.requestPaths).Should(Receive())
+
+			spyConfigProvider.setScrapeConfigs([]scraper.PromScraperConfig{
+				{
+					Port:       promServer.port,
+					SourceID:   "some-id",
+					InstanceID: "some-instance-id",
+				},
+				{
+					Port:       promServer2.port,
+					SourceID:   "some-id",
+					InstanceID: "some-instance-id",
+					Scheme:     "https",
+					ServerName: "server",
+					CaPath:     "/does/not/exist",
+				},
+			})
+
+			Eventually(func() float64 {
+				return metricClient.GetMetricValue("skipped_scrape_configs_total", nil)
+			}).Should(BeNumerically(">=", 1))
+			Consistently(promServer2.requestPaths, 500*time.Millisecond).ShouldNot(Receive())
+			Expect(promServer.requestPaths).To(Receive())
+		})
+
+		It("skips configs with an oauth2 CA that cannot be loaded", func() {
+			ps = app.NewPromScraper(cfg, spyConfigProvider.Configs, metricClient, testLogger)
+			go ps.Run()
+
+			Eventually(promServer.requestPaths).Should(Receive())
+
+			spyConfigProvider.setScrapeConfigs([]scraper.PromScraperConfig{
+				{
+					Port:       promServer.port,
+					SourceID:   "some-id",
+					InstanceID: "some-instance-id",
+				},
+				{
+					Port:       promServer2.port,
+					SourceID:   "some-id",
+					InstanceID: "some-instance-id",
+					OAuth2: scraper.OAuth2Config{
+						ClientID: "client",
+						TokenURL: "https://uaa.example.com/oauth/token",
+						CaPath:   "/does/not/exist",
+					},
+				},
+			})
+
+			Eventually(func() float64 {
+				return metricClient.GetMetricValue("skipped_scrape_configs_total", nil)
+			}).Should(BeNumerically(">=", 1))
+			Consistently(promServer2.requestPaths, 500*time.Millisecond).ShouldNot(Receive())
+			Expect(promServer.requestPaths).To(Receive())
+		})
+	})
+
 	Context("metrics", func() {
-		It("has scrape targets counter", func() {
+		It("has scrape targets gauge", func() {
 			promServer = newStubPromServer()
 			promServer2 := newStubPromServer()
 			spyConfigProvider.scrapeConfigs = []scraper.PromScraperConfig{
@@ -491,8 +959,11 @@ type stubPromServer struct {
 	resp       string
 	port       string
 	statusCode int
+	delay      time.Duration
 
-	mu sync.Mutex
+	mu          sync.Mutex
+	inFlight    int
+	maxInFlight int
 
 	requestHeaders chan http.Header
 	requestPaths   chan string
@@ -546,6 +1017,24 @@ func (s *stubPromServer) ServeHTTP(w http.ResponseWriter, req *http.Request) {
 	s.requestHeaders <- req.Header
 	s.requestPaths <- req.URL.Path
 
+	s.mu.Lock()
+	s.inFlight++
+	if s.inFlight > s.maxInFlight {
+		s.maxInFlight = s.inFlight
+	}
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		s.inFlight--
+		s.mu.Unlock()
+	}()
+
+	select {
+	case <-time.After(s.delay):
+	case <-req.Context().Done():
+		return
+	}
+
 	s.mu.Lock()
 	w.WriteHeader(s.statusCode)
 	s.mu.Unlock()
@@ -553,6 +1042,12 @@ func (s *stubPromServer) ServeHTTP(w http.ResponseWriter, req *http.Request) {
 	Expect(err).ToNot(HaveOccurred())
 }
 
+func (s *stubPromServer) maxConcurrentRequests() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.maxInFlight
+}
+
 func (s *stubPromServer) setStatusCode(sc int) {
 	s.mu.Lock()
 	s.statusCode = sc
@@ -679,14 +1174,24 @@ func (s *spyAgent) Envelopes() []*loggregator_v2.Envelope {
 }
 
 type spyConfigProvider struct {
+	mu            sync.Mutex
 	scrapeConfigs []scraper.PromScraperConfig
 }
 
+func (p *spyConfigProvider) setScrapeConfigs(scrapeConfigs []scraper.PromScraperConfig) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.scrapeConfigs = scrapeConfigs
+}
+
 func newSpyConfigProvider() *spyConfigProvider {
 	return &spyConfigProvider{}
 }
 
 func (p *spyConfigProvider) Configs() ([]scraper.PromScraperConfig, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
 	var configsWithDefaults []scraper.PromScraperConfig
 	for _, cfg := range p.scrapeConfigs {
 		if cfg.Scheme == "" {
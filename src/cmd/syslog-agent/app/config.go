@@ -5,6 +5,7 @@ import (
 	"strings"
 	"time"
 
+	"code.cloudfoundry.org/loggregator-agent-release/src/pkg/binding"
 	"code.cloudfoundry.org/loggregator-agent-release/src/pkg/config"
 	"code.cloudfoundry.org/loggregator-agent-release/src/pkg/ingress/bindings"
 
@@ -23,14 +24,27 @@ type GRPC struct {
 
 type Cache struct {
 	URL             string                   `env:"CACHE_URL,                 report"`
+	FailoverURLs    []string                 `env:"CACHE_FAILOVER_URLS,       report"`
 	CAFile          string                   `env:"CACHE_CA_FILE_PATH,        report"`
 	CertFile        string                   `env:"CACHE_CERT_FILE_PATH,      report"`
 	KeyFile         string                   `env:"CACHE_KEY_FILE_PATH,       report"`
 	CommonName      string                   `env:"CACHE_COMMON_NAME,         report"`
 	PollingInterval time.Duration            `env:"CACHE_POLLING_INTERVAL,    report"`
+	StreamBindings  bool                     `env:"CACHE_STREAM_BINDINGS,     report"`
+	FilterBySource  bool                     `env:"CACHE_FILTER_BY_SOURCE,    report"`
+	Compatibility   binding.Compatibility    `env:"CACHE_BINDING_API_COMPATIBILITY, report"`
 	Blacklist       bindings.BlacklistRanges `env:"BLACKLISTED_SYSLOG_RANGES, report"`
 }
 
+// Admin stores the configuration for the localhost admin server. The server
+// is disabled if Port is zero and requires mTLS if the cert files are set.
+type Admin struct {
+	Port     uint16 `env:"ADMIN_PORT,           report"`
+	CAFile   string `env:"ADMIN_CA_FILE_PATH,   report"`
+	CertFile string `env:"ADMIN_CERT_FILE_PATH, report"`
+	KeyFile  string `env:"ADMIN_KEY_FILE_PATH,  report"`
+}
+
 // Config holds the configuration for the syslog agent
 type Config struct {
 	UseRFC3339           bool          `env:"USE_RFC3339"`
@@ -42,8 +56,16 @@ type Config struct {
 	IdleDrainTimeout     time.Duration `env:"IDLE_DRAIN_TIMEOUT, report"`
 	WarnOnInvalidDrains  bool          `env:"WARN_ON_INVALID_DRAINS,    report"`
 
+	DrainHealthReportInterval       time.Duration `env:"DRAIN_HEALTH_REPORT_INTERVAL,        report"`
+	DrainCertExpiryWarningThreshold time.Duration `env:"DRAIN_CERT_EXPIRY_WARNING_THRESHOLD, report"`
+
+	DrainMaxChurnPercent  int           `env:"DRAIN_MAX_CHURN_PERCENT,  report"`
+	DrainChurnGracePeriod time.Duration `env:"DRAIN_CHURN_GRACE_PERIOD, report"`
+	DrainRemovalHoldDown  time.Duration `env:"DRAIN_REMOVAL_HOLD_DOWN,  report"`
+
 	GRPC          GRPC
 	Cache         Cache
+	Admin         Admin
 	MetricsServer config.MetricsServer
 
 	AggregateConnectionRefreshInterval time.Duration `env:"AGGREGATE_CONNECTION_REFRESH_INTERVAL, report"`
@@ -60,12 +82,16 @@ func LoadConfig() Config {
 
 		Cache: Cache{
 			PollingInterval: 1 * time.Minute,
+			StreamBindings:  true,
 		},
 		GRPC: GRPC{
 			Port: 3458,
 		},
 		AggregateConnectionRefreshInterval: 1 * time.Minute,
 		DefaultDrainMetadata:               true,
+		DrainCertExpiryWarningThreshold:    30 * 24 * time.Hour,
+		DrainMaxChurnPercent:               50,
+		DrainChurnGracePeriod:              10 * time.Minute,
 	}
 	if err := envstruct.Load(&cfg); err != nil {
 		panic(fmt.Sprintf("Failed to load config from environment: %s", err))
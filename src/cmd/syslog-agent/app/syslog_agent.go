@@ -1,6 +1,7 @@
 package app
 
 import (
+	"context"
 	"crypto/tls"
 	"crypto/x509"
 	"fmt"
@@ -32,9 +33,17 @@ type SyslogAgent struct {
 	metrics             Metrics
 	pprofPort           uint16
 	pprofServer         *http.Server
+	admin               Admin
+	adminServer         *http.Server
 	debugMetrics        bool
 	bindingManager      BindingManager
+	cacheStream         bindingStreamer
+	cachePolling        time.Duration
+	cancel              context.CancelFunc
+	drainHealth         *syslog.DrainHealth
+	certMonitor         *syslog.CertificateMonitor
 	grpc                GRPC
+	grpcCerts           *plumbing.CertificateProvider
 	v2Srv               *v2.Server
 	log                 *log.Logger
 	bindingsPerAppLimit int
@@ -43,12 +52,22 @@ type SyslogAgent struct {
 type Metrics interface {
 	NewGauge(name, helpText string, options ...metrics.MetricOption) metrics.Gauge
 	NewCounter(name, helpText string, options ...metrics.MetricOption) metrics.Counter
+	RemoveGauge(g metrics.Gauge)
+	RemoveCounter(c metrics.Counter)
 	RegisterDebugMetrics()
 }
 
 type BindingManager interface {
 	Run()
 	GetDrains(string) []egress.Writer
+	SourceActive(string) bool
+	DrainStates() []binding.DrainState
+	Refresh()
+	RefreshBindings()
+}
+
+type bindingStreamer interface {
+	Stream(ctx context.Context, retryInterval time.Duration, onChange func())
 }
 
 // NewSyslogAgent initializes and returns a new syslog agent.
@@ -57,7 +76,8 @@ func NewSyslogAgent(
 	m Metrics,
 	l *log.Logger,
 ) *SyslogAgent {
-	internalTlsConfig, externalTlsConfig := drainTLSConfig(cfg)
+	drainCAs := drainCertProvider(cfg, m)
+	internalTlsConfig, externalTlsConfig := drainTLSConfig(cfg, drainCAs.CertPool())
 	writerFactory := syslog.NewWriterFactory(
 		internalTlsConfig,
 		externalTlsConfig,
@@ -67,17 +87,24 @@ func NewSyslogAgent(
 			WriteTimeout: 10 * time.Second,
 		},
 		m,
+		syslog.WithCertPoolProvider(drainCAs),
 	)
 
-	ingressTLSConfig, err := loggregator.NewIngressTLSConfig(
-		cfg.GRPC.CAFile,
+	grpcCerts, err := plumbing.NewCertificateProvider(
 		cfg.GRPC.CertFile,
 		cfg.GRPC.KeyFile,
+		cfg.GRPC.CAFile,
+		plumbing.WithExpiryMetrics(m, "grpc"),
 	)
 	if err != nil {
 		l.Panicf("failed to configure client TLS: %q", err)
 	}
 
+	ingressTLSConfig, err := grpcCerts.ClientConfig("metron")
+	if err != nil {
+		l.Panicf("failed to configure client TLS: %q", err)
+	}
+
 	logClient, err := loggregator.NewIngressClient(
 		ingressTLSConfig,
 		loggregator.WithLogger(log.New(os.Stderr, "", log.LstdFlags)),
@@ -86,38 +113,82 @@ func NewSyslogAgent(
 		l.Panicf("failed to create log client for syslog connector: %q", err)
 	}
 
+	drainHealth := syslog.NewDrainHealth(
+		m,
+		cfg.DrainHealthReportInterval,
+		syslog.WithHealthLogClient(logClient),
+	)
+
+	certMonitor := syslog.NewCertificateMonitor(
+		m,
+		cfg.DrainCertExpiryWarningThreshold,
+		syslog.WithCertificateLogClient(logClient, "syslog_agent"),
+	)
+
 	connector := syslog.NewSyslogConnector(
 		cfg.DrainSkipCertVerify,
 		timeoutwaitgroup.New(time.Minute),
 		writerFactory,
 		m,
 		syslog.WithLogClient(logClient, "syslog_agent"),
+		syslog.WithDrainHealth(drainHealth),
+		syslog.WithCertificateMonitor(certMonitor),
 	)
 
+	var bindingManager *binding.Manager
+	managerOpts := []binding.ManagerOption{
+		binding.WithMaxChurn(cfg.DrainMaxChurnPercent, cfg.DrainChurnGracePeriod),
+		binding.WithRemovalHoldDown(cfg.DrainRemovalHoldDown),
+	}
 	var cacheClient *cache.CacheClient
 	var cupsFetcher binding.Fetcher = nil
 	if cfg.Cache.CAFile != "" {
-		tlsClient := plumbing.NewTLSHTTPClient(
+		cacheCerts, err := plumbing.NewCertificateProvider(
 			cfg.Cache.CertFile,
 			cfg.Cache.KeyFile,
 			cfg.Cache.CAFile,
-			cfg.Cache.CommonName,
-			false,
+			plumbing.WithExpiryMetrics(m, "binding_cache_client"),
 		)
+		if err != nil {
+			l.Panicf("failed to load binding cache client certificates: %q", err)
+		}
+		tlsClient := plumbing.NewTLSHTTPClientFromProvider(cacheCerts, cfg.Cache.CommonName, false)
+
+		clientOpts := []cache.ClientOption{
+			cache.WithFailoverAddrs(cfg.Cache.FailoverURLs...),
+			cache.WithClientLogger(l),
+		}
+		if cfg.Cache.FilterBySource {
+			clientOpts = append(clientOpts, cache.WithSourceIDs(func() []string {
+				return bindingManager.ActiveSources()
+			}))
+			managerOpts = append(managerOpts, binding.WithRefreshOnNewSources())
+		}
 
-		cacheClient = cache.NewClient(cfg.Cache.URL, tlsClient)
+		cacheClient = cache.NewClient(cfg.Cache.URL, tlsClient, clientOpts...)
 		cupsFetcher = bindings.NewFilteredBindingFetcher(
 			&cfg.Cache.Blacklist,
-			bindings.NewBindingFetcher(cfg.BindingsPerAppLimit, cacheClient, m, l),
+			bindings.NewBindingFetcher(
+				cfg.BindingsPerAppLimit,
+				cacheClient,
+				m,
+				l,
+				bindings.WithCompatibility(cfg.Cache.Compatibility),
+			),
 			m,
 			cfg.WarnOnInvalidDrains,
 			l,
+			bindings.WithRejectionReporter(drainHealth),
 		)
 		cupsFetcher = bindings.NewDrainParamParser(cupsFetcher, cfg.DefaultDrainMetadata)
 	}
 
-	aggregateFetcher := bindings.NewAggregateDrainFetcher(cfg.AggregateDrainURLs, cacheClient)
-	bindingManager := binding.NewManager(
+	aggregateFetcher := bindings.NewAggregateDrainFetcher(
+		cfg.AggregateDrainURLs,
+		cacheClient,
+		bindings.WithAggregateCompatibility(cfg.Cache.Compatibility, m),
+	)
+	bindingManager = binding.NewManager(
 		cupsFetcher,
 		bindings.NewDrainParamParser(aggregateFetcher, cfg.DefaultDrainMetadata),
 		connector,
@@ -126,21 +197,32 @@ func NewSyslogAgent(
 		cfg.IdleDrainTimeout,
 		cfg.AggregateConnectionRefreshInterval,
 		l,
+		managerOpts...,
 	)
 
+	var cacheStream bindingStreamer
+	if cacheClient != nil && cfg.Cache.StreamBindings {
+		cacheStream = cacheClient
+	}
+
 	return &SyslogAgent{
 		grpc:                cfg.GRPC,
+		grpcCerts:           grpcCerts,
+		admin:               cfg.Admin,
 		debugMetrics:        cfg.MetricsServer.DebugMetrics,
 		pprofPort:           cfg.MetricsServer.PprofPort,
 		metrics:             m,
 		log:                 l,
 		bindingsPerAppLimit: cfg.BindingsPerAppLimit,
 		bindingManager:      bindingManager,
+		cacheStream:         cacheStream,
+		cachePolling:        cfg.Cache.PollingInterval,
+		drainHealth:         drainHealth,
+		certMonitor:         certMonitor,
 	}
 }
 
-func drainTLSConfig(cfg Config) (*tls.Config, *tls.Config) {
-	certPool := trustedCertPool(cfg.DrainTrustedCAFile)
+func drainTLSConfig(cfg Config, certPool *x509.CertPool) (*tls.Config, *tls.Config) {
 	internalTlsConfig, err := tlsconfig.Build(
 		tlsconfig.WithInternalServiceDefaults(),
 	).Client(
@@ -186,26 +268,21 @@ func drainTLSConfig(cfg Config) (*tls.Config, *tls.Config) {
 	return internalTlsConfig, externalTlsConfig
 }
 
-func trustedCertPool(trustedCAFile string) *x509.CertPool {
-	cp, err := x509.SystemCertPool()
+// drainCertProvider returns a provider of the CAs trusted for drains. It
+// uses the system cert pool until the custom CA can be loaded.
+func drainCertProvider(cfg Config, m Metrics) *plumbing.CertificateProvider {
+	p, err := plumbing.NewCertificateProvider(
+		"",
+		"",
+		cfg.DrainTrustedCAFile,
+		plumbing.WithSystemCertPool(),
+		plumbing.WithOptionalCA(),
+		plumbing.WithExpiryMetrics(m, "drain"),
+	)
 	if err != nil {
-		cp = x509.NewCertPool()
+		log.Panicf("failed to load drain CAs: %s", err)
 	}
-
-	if trustedCAFile != "" {
-		cert, err := os.ReadFile(trustedCAFile)
-		if err != nil {
-			log.Printf("unable to read provided custom CA: %s", err)
-			return cp
-		}
-
-		ok := cp.AppendCertsFromPEM(cert)
-		if !ok {
-			log.Println("unable to add provided custom CA")
-		}
-	}
-
-	return cp
+	return p
 }
 
 func (s *SyslogAgent) Run() {
@@ -227,6 +304,16 @@ func (s *SyslogAgent) Run() {
 		ingressDropped.Add(float64(missed))
 	}))
 	go s.bindingManager.Run()
+	if s.cacheStream != nil {
+		var ctx context.Context
+		ctx, s.cancel = context.WithCancel(context.Background())
+		go s.cacheStream.Stream(ctx, s.cachePolling, s.bindingManager.RefreshBindings)
+	}
+	go s.drainHealth.Run(s.bindingManager.SourceActive)
+	go s.certMonitor.Run()
+	if s.admin.Port != 0 {
+		s.startAdminServer()
+	}
 
 	drainIngress := s.metrics.NewCounter(
 		"ingress",
@@ -241,12 +328,7 @@ func (s *SyslogAgent) Run() {
 		opts = append(opts, plumbing.WithCipherSuites(s.grpc.CipherSuites))
 	}
 
-	serverCreds, err := plumbing.NewServerCredentials(
-		s.grpc.CertFile,
-		s.grpc.KeyFile,
-		s.grpc.CAFile,
-		opts...,
-	)
+	serverCreds, err := s.grpcCerts.ServerCredentials(opts...)
 	if err != nil {
 		s.log.Fatalf("failed to configure server TLS: %s", err)
 	}
@@ -271,9 +353,44 @@ func (s *SyslogAgent) Run() {
 	s.v2Srv.Start()
 }
 
+func (s *SyslogAgent) startAdminServer() {
+	s.adminServer = &http.Server{
+		Addr:              fmt.Sprintf("127.0.0.1:%d", s.admin.Port),
+		Handler:           binding.AdminHandler(s.bindingManager, s.drainHealth),
+		ReadHeaderTimeout: 2 * time.Second,
+	}
+
+	if s.admin.CertFile == "" {
+		go func() { log.Println("ADMIN SERVER STOPPED " + s.adminServer.ListenAndServe().Error()) }()
+		return
+	}
+
+	adminCerts, err := plumbing.NewCertificateProvider(
+		s.admin.CertFile,
+		s.admin.KeyFile,
+		s.admin.CAFile,
+		plumbing.WithExpiryMetrics(s.metrics, "admin_server"),
+	)
+	if err != nil {
+		s.log.Panicf("failed to load admin server certificates: %s", err)
+	}
+	tlsConfig, err := adminCerts.ServerConfig()
+	if err != nil {
+		s.log.Panicf("failed to load admin server TLS config: %s", err)
+	}
+	s.adminServer.TLSConfig = tlsConfig
+	go func() { log.Println("ADMIN SERVER STOPPED " + s.adminServer.ListenAndServeTLS("", "").Error()) }()
+}
+
 func (s *SyslogAgent) Stop() {
 	if s.pprofServer != nil {
 		s.pprofServer.Close()
 	}
+	if s.adminServer != nil {
+		s.adminServer.Close()
+	}
+	if s.cancel != nil {
+		s.cancel()
+	}
 	s.v2Srv.Stop()
 }
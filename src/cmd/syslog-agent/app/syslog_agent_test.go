@@ -121,6 +121,8 @@ var _ = Describe("SyslogAgent", func() {
 			agentCfg.Cache.KeyFile = cacheCerts.Key("binding-cache")
 			agentCfg.Cache.CommonName = "binding-cache"
 			agentCfg.Cache.PollingInterval = 10 * time.Millisecond
+			// The fake binding cache only serves the legacy binding API.
+			agentCfg.Cache.Compatibility = binding.CompatibilityLegacyFallback
 		}
 
 		agent = app.NewSyslogAgent(agentCfg, agentMetrics, agentLogr)
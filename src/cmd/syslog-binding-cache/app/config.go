@@ -4,6 +4,7 @@ import (
 	"log"
 	"time"
 
+	"code.cloudfoundry.org/loggregator-agent-release/src/pkg/binding"
 	"code.cloudfoundry.org/loggregator-agent-release/src/pkg/config"
 
 	envstruct "code.cloudfoundry.org/go-envstruct"
@@ -20,8 +21,23 @@ type Config struct {
 	APIPollingInterval   time.Duration `env:"API_POLLING_INTERVAL, report"`
 	APIBatchSize         int           `env:"API_BATCH_SIZE, report"`
 	APIDisableKeepAlives bool          `env:"API_DISABLE_KEEP_ALIVES, report"`
+	APIMaxBackoff        time.Duration `env:"API_MAX_BACKOFF, report"`
+	APIPageInterval      time.Duration `env:"API_PAGE_INTERVAL, report"`
 	CipherSuites         []string      `env:"CIPHER_SUITES, report"`
 	AggregateDrainsFile  string        `env:"AGGREGATE_DRAINS_FILE, report"`
+	SnapshotFile         string        `env:"BINDING_SNAPSHOT_FILE, report"`
+
+	// APICompatibility selects the binding API versions used to poll the
+	// Cloud Controller.
+	APICompatibility binding.Compatibility `env:"API_BINDING_API_COMPATIBILITY, report"`
+
+	// AggregateDrainsRuntimeFile holds aggregate drains in the format of
+	// AggregateDrainsFile that can be changed without a redeploy.
+	AggregateDrainsRuntimeFile string `env:"AGGREGATE_DRAINS_RUNTIME_FILE, report"`
+
+	// AggregateDrainsReloadInterval is how often the aggregate drains files
+	// are checked for changes. Zero disables reloading.
+	AggregateDrainsReloadInterval time.Duration `env:"AGGREGATE_DRAINS_RELOAD_INTERVAL, report"`
 
 	CacheCAFile     string `env:"CACHE_CA_FILE_PATH,     required, report"`
 	CacheCertFile   string `env:"CACHE_CERT_FILE_PATH,   required, report"`
@@ -30,6 +46,12 @@ type Config struct {
 
 	CachePort int `env:"CACHE_PORT, required, report"`
 
+	// PeerURLs holds the URLs of all binding cache instances ordered by
+	// index. If there is more than one, the instances elect a leader that
+	// polls the API and the others replicate its bindings.
+	PeerURLs      []string `env:"PEER_URLS,      report"`
+	InstanceIndex int      `env:"INSTANCE_INDEX, report"`
+
 	MetricsServer config.MetricsServer
 }
 
@@ -38,7 +60,9 @@ type Config struct {
 // panic.
 func LoadConfig() Config {
 	cfg := Config{
-		APIPollingInterval: 15 * time.Second,
+		APIPollingInterval:            15 * time.Second,
+		APIMaxBackoff:                 5 * time.Minute,
+		AggregateDrainsReloadInterval: 10 * time.Second,
 	}
 	if err := envstruct.Load(&cfg); err != nil {
 		log.Panicf("Failed to load config from environment: %s", err)
@@ -10,7 +10,6 @@ import (
 	"time"
 
 	metrics "code.cloudfoundry.org/go-metric-registry"
-	"code.cloudfoundry.org/tlsconfig"
 
 	"code.cloudfoundry.org/loggregator-agent-release/src/pkg/binding"
 	"code.cloudfoundry.org/loggregator-agent-release/src/pkg/cache"
@@ -19,18 +18,23 @@ import (
 	"github.com/go-chi/chi/v5"
 )
 
+// streamKeepaliveInterval is how often idle binding streams are kept alive.
+const streamKeepaliveInterval = 30 * time.Second
+
 type SyslogBindingCache struct {
-	config      Config
-	pprofServer *http.Server
-	server      *http.Server
-	log         *log.Logger
-	metrics     Metrics
-	mu          sync.Mutex
+	config         Config
+	pprofServer    *http.Server
+	server         *http.Server
+	aggregateStore *binding.AggregateStore
+	log            *log.Logger
+	metrics        Metrics
+	mu             sync.Mutex
 }
 
 type Metrics interface {
 	NewCounter(name, helpText string, options ...metrics.MetricOption) metrics.Counter
 	NewGauge(name, helpText string, o ...metrics.MetricOption) metrics.Gauge
+	RemoveGauge(g metrics.Gauge)
 	RegisterDebugMetrics()
 }
 
@@ -54,18 +58,58 @@ func (sbc *SyslogBindingCache) Run() {
 	}
 	store := binding.NewStore(sbc.metrics)
 	legacyStore := binding.NewLegacyStore()
-	aggregateStore := binding.NewAggregateStore(sbc.config.AggregateDrainsFile)
-	poller := binding.NewPoller(sbc.apiClient(), sbc.config.APIPollingInterval, store, legacyStore, sbc.metrics, sbc.log)
+	aggregateStore := binding.NewAggregateStore(
+		sbc.config.AggregateDrainsFile,
+		binding.WithAggregateReloadInterval(sbc.config.AggregateDrainsReloadInterval),
+		binding.WithAggregateRuntimeFile(sbc.config.AggregateDrainsRuntimeFile),
+		binding.WithAggregateLogger(sbc.log),
+		binding.WithAggregateMetrics(sbc.metrics),
+	)
+	sbc.mu.Lock()
+	sbc.aggregateStore = aggregateStore
+	sbc.mu.Unlock()
+	cacheCerts := sbc.cacheCerts()
+	router := chi.NewRouter()
+
+	pollerOpts := []binding.PollerOption{
+		binding.WithPollBackoff(sbc.config.APIMaxBackoff),
+		binding.WithPageInterval(sbc.config.APIPageInterval),
+		binding.WithCompatibility(sbc.config.APICompatibility),
+	}
+	if sbc.config.SnapshotFile != "" {
+		pollerOpts = append(pollerOpts, binding.WithSnapshotFile(sbc.config.SnapshotFile))
+	}
+	if len(sbc.config.PeerURLs) > 1 {
+		if sbc.config.InstanceIndex < 0 || sbc.config.InstanceIndex >= len(sbc.config.PeerURLs) {
+			sbc.log.Panicf("instance index %d is not in the peer URLs", sbc.config.InstanceIndex)
+		}
+		peerClient := plumbing.NewTLSHTTPClientFromProvider(cacheCerts, sbc.config.CacheCommonName, false)
+		elector := cache.NewPeerElector(sbc.config.PeerURLs, sbc.config.InstanceIndex, peerClient, sbc.metrics)
+		go elector.Run()
+
+		router.Get("/v2/lease", cache.LeaseHandler(elector))
+		pollerOpts = append(pollerOpts, binding.WithLeaderElection(elector, cache.NewLeaderClient(elector, peerClient)))
+	}
+	poller := binding.NewPoller(
+		sbc.apiClient(),
+		sbc.config.APIPollingInterval,
+		store,
+		legacyStore,
+		sbc.metrics,
+		sbc.log,
+		pollerOpts...,
+	)
 
 	go poller.Poll()
 
-	router := chi.NewRouter()
 	router.Get("/bindings", cache.LegacyHandler(legacyStore))
 	router.Get("/v2/bindings", cache.Handler(store))
+	router.Get("/v2/bindings/stream", cache.StreamHandler(store, streamKeepaliveInterval))
 	router.Get("/aggregate", cache.LegacyAggregateHandler(aggregateStore))
 	router.Get("/v2/aggregate", cache.AggregateHandler(aggregateStore))
+	router.Mount("/v2/query", cache.QueryHandler(store, poller))
 
-	sbc.startServer(router)
+	sbc.startServer(router, cacheCerts)
 }
 
 func (sbc *SyslogBindingCache) Stop() {
@@ -77,12 +121,22 @@ func (sbc *SyslogBindingCache) Stop() {
 	if sbc.server != nil {
 		sbc.server.Close()
 	}
+	if sbc.aggregateStore != nil {
+		sbc.aggregateStore.Stop()
+	}
 }
 func (sbc *SyslogBindingCache) apiClient() api.Client {
-	httpClient := plumbing.NewTLSHTTPClient(
+	apiCerts, err := plumbing.NewCertificateProvider(
 		sbc.config.APICertFile,
 		sbc.config.APIKeyFile,
 		sbc.config.APICAFile,
+		plumbing.WithExpiryMetrics(sbc.metrics, "cloud_controller_client"),
+	)
+	if err != nil {
+		sbc.log.Panicf("failed to load API client certificates: %s", err)
+	}
+	httpClient := plumbing.NewTLSHTTPClientFromProvider(
+		apiCerts,
 		sbc.config.APICommonName,
 		sbc.config.APIDisableKeepAlives,
 	)
@@ -94,13 +148,13 @@ func (sbc *SyslogBindingCache) apiClient() api.Client {
 	}
 }
 
-func (sbc *SyslogBindingCache) startServer(router chi.Router) {
+func (sbc *SyslogBindingCache) startServer(router chi.Router, cacheCerts *plumbing.CertificateProvider) {
 	listenAddr := fmt.Sprintf(":%d", sbc.config.CachePort)
 	sbc.mu.Lock()
 	sbc.server = &http.Server{
 		Addr:              listenAddr,
 		Handler:           router,
-		TLSConfig:         sbc.tlsConfig(),
+		TLSConfig:         sbc.tlsConfig(cacheCerts),
 		ReadHeaderTimeout: 2 * time.Second,
 	}
 	sbc.mu.Unlock()
@@ -110,20 +164,30 @@ func (sbc *SyslogBindingCache) startServer(router chi.Router) {
 	}
 }
 
-func (sbc *SyslogBindingCache) tlsConfig() *tls.Config {
-	tlsConfig, err := tlsconfig.Build(
-		tlsconfig.WithInternalServiceDefaults(),
-		tlsconfig.WithIdentityFromFile(sbc.config.CacheCertFile, sbc.config.CacheKeyFile),
-	).Server(
-		tlsconfig.WithClientAuthenticationFromFile(sbc.config.CacheCAFile),
+// cacheCerts returns the certificates of the binding cache. They are used
+// to serve the bindings and to connect to the other binding caches.
+func (sbc *SyslogBindingCache) cacheCerts() *plumbing.CertificateProvider {
+	cacheCerts, err := plumbing.NewCertificateProvider(
+		sbc.config.CacheCertFile,
+		sbc.config.CacheKeyFile,
+		sbc.config.CacheCAFile,
+		plumbing.WithExpiryMetrics(sbc.metrics, "binding_cache_server"),
 	)
 	if err != nil {
 		sbc.log.Panicf("failed to load server TLS config: %s", err)
 	}
+	return cacheCerts
+}
 
+func (sbc *SyslogBindingCache) tlsConfig(cacheCerts *plumbing.CertificateProvider) *tls.Config {
+	var opts []plumbing.ConfigOption
 	if len(sbc.config.CipherSuites) > 0 {
-		opt := plumbing.WithCipherSuites(sbc.config.CipherSuites)
-		opt(tlsConfig)
+		opts = append(opts, plumbing.WithCipherSuites(sbc.config.CipherSuites))
+	}
+
+	tlsConfig, err := cacheCerts.ServerConfig(opts...)
+	if err != nil {
+		sbc.log.Panicf("failed to load server TLS config: %s", err)
 	}
 
 	return tlsConfig
@@ -268,6 +268,30 @@ var _ = Describe("App", func() {
 		Expect(result[0].Drains).To(ConsistOf("syslog://test-hostname:1000", "syslog://test2:1000"))
 	})
 
+	It("has an HTTP endpoint that returns the drains of an app", func() {
+		addr := fmt.Sprintf("https://localhost:%d/v2/query/apps/app-id-1/drains", sbcPort)
+
+		var resp *http.Response
+		Eventually(func() error {
+			var err error
+			resp, err = client.Get(addr)
+			return err
+		}).Should(Succeed())
+		defer resp.Body.Close()
+
+		Expect(resp.StatusCode).To(Equal(http.StatusOK))
+
+		body, err := io.ReadAll(resp.Body)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(body).To(MatchJSON(`{
+			"results": [
+				{"url": "syslog://drain-a", "hostname": "org.space.app-name-1"},
+				{"url": "syslog://drain-b", "hostname": "org.space.app-name-1"}
+			],
+			"next_id": 0
+		}`))
+	})
+
 	It("has an HTTP endpoint that returns legacy bindings", func() {
 		addr := fmt.Sprintf("https://localhost:%d/bindings", sbcPort)
 
@@ -14,12 +14,14 @@ import (
 	"code.cloudfoundry.org/go-loggregator/v9"
 	"code.cloudfoundry.org/go-loggregator/v9/conversion"
 	ingress "code.cloudfoundry.org/loggregator-agent-release/src/pkg/ingress/v1"
+	"code.cloudfoundry.org/loggregator-agent-release/src/pkg/plumbing"
 	"github.com/cloudfoundry/sonde-go/events"
 )
 
 type Metrics interface {
 	NewGauge(name, helpText string, opts ...metrics.MetricOption) metrics.Gauge
 	NewCounter(name, helpText string, opts ...metrics.MetricOption) metrics.Counter
+	RemoveGauge(g metrics.Gauge)
 	RegisterDebugMetrics()
 }
 
@@ -68,11 +70,17 @@ func (u *UDPForwarder) Run() {
 		u.mu.Unlock()
 		go func() { u.log.Println("PPROF SERVER STOPPED " + u.pprofServer.ListenAndServe().Error()) }()
 	}
-	tlsConfig, err := loggregator.NewIngressTLSConfig(
-		u.grpc.CAFile,
+	certs, err := plumbing.NewCertificateProvider(
 		u.grpc.CertFile,
 		u.grpc.KeyFile,
+		u.grpc.CAFile,
+		plumbing.WithExpiryMetrics(u.metrics, "ingress_client"),
 	)
+	if err != nil {
+		u.log.Fatalf("Failed to load loggregator agent certificates: %s", err)
+	}
+
+	tlsConfig, err := certs.ClientConfig("metron")
 	if err != nil {
 		u.log.Fatalf("Failed to create loggregator agent credentials: %s", err)
 	}
@@ -0,0 +1,144 @@
+package binding
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"code.cloudfoundry.org/loggregator-agent-release/src/pkg/egress/syslog"
+)
+
+// DrainStateGetter exposes the drains held by a Manager.
+type DrainStateGetter interface {
+	DrainStates() []DrainState
+	Refresh()
+}
+
+// DrainReporter exposes the delivery health of drains.
+type DrainReporter interface {
+	Reports() []syslog.DrainReport
+}
+
+type adminResponse struct {
+	Sources         []adminSource `json:"sources"`
+	AggregateDrains []adminDrain  `json:"aggregate_drains"`
+}
+
+type adminSource struct {
+	SourceID   string       `json:"source_id"`
+	LastAccess *time.Time   `json:"last_access,omitempty"`
+	Drains     []adminDrain `json:"drains"`
+}
+
+type adminDrain struct {
+	URL    string              `json:"url"`
+	Active bool                `json:"active"`
+	Health *syslog.DrainReport `json:"health,omitempty"`
+}
+
+type adminKey struct {
+	sourceID string
+	url      string
+}
+
+// AdminHandler returns a handler that lists the drains held by the syslog
+// agent and allows to force a binding refresh. Drain URLs are anonymized.
+//
+//	GET  /drains[?source_id=<source_id>]
+//	POST /refresh
+func AdminHandler(g DrainStateGetter, r DrainReporter) http.Handler {
+	router := chi.NewRouter()
+	router.Get("/drains", func(w http.ResponseWriter, req *http.Request) {
+		resp := buildAdminResponse(g.DrainStates(), r.Reports(), req.URL.Query().Get("source_id"))
+
+		w.Header().Set("Content-Type", "application/json")
+		err := json.NewEncoder(w).Encode(resp)
+		if err != nil {
+			log.Printf("failed to encode response body: %s", err)
+			return
+		}
+	})
+	router.Post("/refresh", func(w http.ResponseWriter, _ *http.Request) {
+		g.Refresh()
+		w.WriteHeader(http.StatusAccepted)
+	})
+
+	return router
+}
+
+func buildAdminResponse(states []DrainState, reports []syslog.DrainReport, sourceID string) adminResponse {
+	health := make(map[adminKey]syslog.DrainReport, len(reports))
+	for _, r := range reports {
+		health[adminKey{sourceID: r.AppID, url: r.URL}] = r
+	}
+
+	sources := make(map[string]*adminSource)
+	resp := adminResponse{
+		Sources:         []adminSource{},
+		AggregateDrains: []adminDrain{},
+	}
+
+	add := func(id string, d adminDrain, lastAccess time.Time) {
+		if id == "" {
+			resp.AggregateDrains = append(resp.AggregateDrains, d)
+			return
+		}
+
+		s, ok := sources[id]
+		if !ok {
+			s = &adminSource{SourceID: id}
+			sources[id] = s
+		}
+		if !lastAccess.IsZero() {
+			t := lastAccess
+			s.LastAccess = &t
+		}
+		s.Drains = append(s.Drains, d)
+	}
+
+	for _, st := range states {
+		id := st.Binding.AppId
+		if sourceID != "" && id != sourceID {
+			continue
+		}
+
+		k := adminKey{sourceID: id, url: syslog.AnonymizeDrainURL(st.Binding.Drain.Url)}
+		d := adminDrain{URL: k.url, Active: st.Active}
+		if r, ok := health[k]; ok {
+			d.Health = &r
+			delete(health, k)
+		}
+		add(id, d, st.LastAccess)
+	}
+
+	// Bindings that are not held by the manager, such as rejected ones.
+	for k, r := range health {
+		if sourceID != "" && k.sourceID != sourceID {
+			continue
+		}
+
+		r := r
+		add(k.sourceID, adminDrain{URL: k.url, Health: &r}, time.Time{})
+	}
+
+	for _, s := range sources {
+		sortAdminDrains(s.Drains)
+		resp.Sources = append(resp.Sources, *s)
+	}
+	sort.Slice(resp.Sources, func(i, j int) bool {
+		return resp.Sources[i].SourceID < resp.Sources[j].SourceID
+	})
+	sortAdminDrains(resp.AggregateDrains)
+
+	return resp
+}
+
+func sortAdminDrains(drains []adminDrain) {
+	sort.Slice(drains, func(i, j int) bool {
+		return drains[i].URL < drains[j].URL
+	})
+}
@@ -0,0 +1,329 @@
+package binding
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	metrics "code.cloudfoundry.org/go-metric-registry"
+	"gopkg.in/yaml.v2"
+)
+
+// defaultAggregateReloadInterval is how often the aggregate drain file is
+// checked for changes unless WithAggregateReloadInterval is given.
+const defaultAggregateReloadInterval = 10 * time.Second
+
+// AggBinding is an entry of the aggregate drain file. Besides the URL and
+// credentials of the drain it may set the drain options that are otherwise
+// given as query parameters of the URL.
+type AggBinding struct {
+	Url  string `yaml:"url"`
+	Cert string `yaml:"cert"`
+	Key  string `yaml:"key"`
+	CA   string `yaml:"ca"`
+
+	// DrainData filters the envelopes sent to the drain. It is one of
+	// logs, metrics, traces or all.
+	DrainData       string `yaml:"drain_data"`
+	DisableMetadata *bool  `yaml:"disable_metadata"`
+	CombineMetrics  bool   `yaml:"combine_metrics"`
+}
+
+// AggregateStore holds the aggregate drains loaded from a file and an
+// optional runtime file. It watches the files and reloads them when they
+// change so that aggregate drains can be added or removed without restarting
+// the binding cache. Invalid entries are logged and skipped instead of
+// failing the whole file.
+type AggregateStore struct {
+	interval time.Duration
+	log      *log.Logger
+	invalid  metrics.Gauge
+
+	mu     sync.RWMutex
+	files  []*aggregateFile
+	drains []Binding
+
+	done     chan struct{}
+	stopOnce sync.Once
+}
+
+// aggregateFile is a file holding aggregate drains. A missing optional file
+// holds no drains.
+type aggregateFile struct {
+	name     string
+	optional bool
+
+	exists  bool
+	modTime time.Time
+	size    int64
+	drains  []Binding
+	invalid int
+}
+
+// AggregateStoreOption allows an AggregateStore to be customized.
+type AggregateStoreOption func(*AggregateStore)
+
+// WithAggregateReloadInterval sets how often the aggregate drain file is
+// checked for changes. A zero interval disables reloading.
+func WithAggregateReloadInterval(d time.Duration) AggregateStoreOption {
+	return func(s *AggregateStore) {
+		s.interval = d
+	}
+}
+
+// WithAggregateLogger sets the logger used to report invalid aggregate
+// drains and failed reloads.
+func WithAggregateLogger(l *log.Logger) AggregateStoreOption {
+	return func(s *AggregateStore) {
+		s.log = l
+	}
+}
+
+// WithAggregateMetrics exports the number of invalid entries of the
+// aggregate drain files as the invalid_aggregate_drains gauge.
+func WithAggregateMetrics(m Metrics) AggregateStoreOption {
+	return func(s *AggregateStore) {
+		s.invalid = m.NewGauge(
+			"invalid_aggregate_drains",
+			"Number of entries of the aggregate drain files that were skipped because they are invalid.",
+		)
+	}
+}
+
+// WithAggregateRuntimeFile adds the drains of a second file in the same
+// format. Unlike the BOSH rendered file it is meant to be written at
+// runtime, so it may not exist and its drains are removed when it is
+// deleted.
+func WithAggregateRuntimeFile(fileName string) AggregateStoreOption {
+	return func(s *AggregateStore) {
+		if fileName != "" {
+			s.files = append(s.files, &aggregateFile{name: fileName, optional: true})
+		}
+	}
+}
+
+// NewAggregateStore loads the aggregate drains from the given file and
+// starts watching it for changes. If the file cannot be loaded the store
+// is empty until a later reload succeeds.
+func NewAggregateStore(drainFileName string, opts ...AggregateStoreOption) *AggregateStore {
+	s := &AggregateStore{
+		interval: defaultAggregateReloadInterval,
+		log:      log.Default(),
+		done:     make(chan struct{}),
+	}
+	if drainFileName != "" {
+		s.files = append(s.files, &aggregateFile{name: drainFileName})
+	}
+	for _, o := range opts {
+		o(s)
+	}
+
+	if len(s.files) == 0 {
+		return s
+	}
+
+	for _, f := range s.files {
+		if err := s.load(f); err != nil {
+			s.log.Printf("failed to load aggregate drains from %s: %s", f.name, err)
+		}
+	}
+
+	if s.interval > 0 {
+		go s.watch()
+	}
+
+	return s
+}
+
+// Stop stops watching the aggregate drain file for changes.
+func (s *AggregateStore) Stop() {
+	s.stopOnce.Do(func() {
+		close(s.done)
+	})
+}
+
+func (s *AggregateStore) Get() []Binding {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return append([]Binding(nil), s.drains...)
+}
+
+func (s *AggregateStore) LegacyGet() []LegacyBinding {
+	var drains []string
+	for _, binding := range s.Get() {
+		drains = append(drains, binding.Url)
+	}
+	return []LegacyBinding{
+		{
+			AppID:       "",
+			Drains:      drains,
+			V2Available: true,
+		},
+	}
+}
+
+func (s *AggregateStore) watch() {
+	t := time.NewTicker(s.interval)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-s.done:
+			return
+		case <-t.C:
+			for _, f := range s.files {
+				if !s.changed(f) {
+					continue
+				}
+				if err := s.load(f); err != nil {
+					s.log.Printf("failed to reload aggregate drains from %s, keeping the previous ones: %s", f.name, err)
+				}
+			}
+		}
+	}
+}
+
+func (s *AggregateStore) changed(f *aggregateFile) bool {
+	fi, err := os.Stat(f.name)
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if err != nil {
+		return f.optional && f.exists && errors.Is(err, os.ErrNotExist)
+	}
+	return !f.exists || !fi.ModTime().Equal(f.modTime) || fi.Size() != f.size
+}
+
+func (s *AggregateStore) load(f *aggregateFile) error {
+	fi, err := os.Stat(f.name)
+	if f.optional && errors.Is(err, os.ErrNotExist) {
+		s.update(f, nil, nil, 0)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	contents, err := os.ReadFile(f.name)
+	if err != nil {
+		return err
+	}
+
+	var aggBindings []AggBinding
+	if err := yaml.Unmarshal(contents, &aggBindings); err != nil {
+		// Remember the file so that it is only reported again when it
+		// changes.
+		s.mu.Lock()
+		f.setStat(fi)
+		s.mu.Unlock()
+		return err
+	}
+
+	var bindings []Binding
+	var invalid int
+	for i, ab := range aggBindings {
+		b, err := ab.binding()
+		if err != nil {
+			invalid++
+			s.log.Printf("skipping invalid aggregate drain %d of %s: %s", i, f.name, err)
+			continue
+		}
+		bindings = append(bindings, b)
+	}
+
+	s.update(f, fi, bindings, invalid)
+	return nil
+}
+
+// update sets the drains of the file and recomputes the drains of the
+// store. A nil FileInfo marks the file as missing.
+func (s *AggregateStore) update(f *aggregateFile, fi os.FileInfo, bindings []Binding, invalid int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	f.setStat(fi)
+	f.drains = bindings
+	f.invalid = invalid
+
+	var drains []Binding
+	var totalInvalid int
+	for _, f := range s.files {
+		drains = append(drains, f.drains...)
+		totalInvalid += f.invalid
+	}
+	s.drains = drains
+	if s.invalid != nil {
+		s.invalid.Set(float64(totalInvalid))
+	}
+}
+
+func (f *aggregateFile) setStat(fi os.FileInfo) {
+	if fi == nil {
+		f.exists = false
+		f.modTime = time.Time{}
+		f.size = 0
+		return
+	}
+	f.exists = true
+	f.modTime = fi.ModTime()
+	f.size = fi.Size()
+}
+
+// binding validates the aggregate drain and returns it as a Binding with
+// its options encoded as query parameters of the URL.
+func (ab AggBinding) binding() (Binding, error) {
+	if ab.Url == "" {
+		return Binding{}, errors.New("url is empty")
+	}
+	u, err := url.Parse(ab.Url)
+	if err != nil {
+		return Binding{}, errors.New("url cannot be parsed")
+	}
+	switch u.Scheme {
+	case "syslog", "syslog-tls", "https":
+	default:
+		return Binding{}, fmt.Errorf("%s: unsupported protocol %q", u.Redacted(), u.Scheme)
+	}
+	if u.Host == "" {
+		return Binding{}, fmt.Errorf("%s: host is empty", u.Redacted())
+	}
+	if (ab.Cert == "") != (ab.Key == "") {
+		return Binding{}, fmt.Errorf("%s: cert and key must be given together", u.Redacted())
+	}
+
+	q := u.Query()
+	switch ab.DrainData {
+	case "":
+	case "logs", "metrics", "traces", "all":
+		q.Set("drain-data", ab.DrainData)
+	default:
+		return Binding{}, fmt.Errorf("%s: unknown drain_data %q", u.Redacted(), ab.DrainData)
+	}
+	if ab.DisableMetadata != nil {
+		q.Set("disable-metadata", strconv.FormatBool(*ab.DisableMetadata))
+	}
+	if ab.CombineMetrics {
+		q.Set("combine-metrics", "true")
+	}
+	drainURL := ab.Url
+	if ab.DrainData != "" || ab.DisableMetadata != nil || ab.CombineMetrics {
+		u.RawQuery = q.Encode()
+		drainURL = u.String()
+	}
+
+	return Binding{
+		Url: drainURL,
+		Credentials: []Credentials{
+			{
+				Cert: ab.Cert,
+				Key:  ab.Key,
+				CA:   ab.CA,
+			},
+		},
+	}, nil
+}
@@ -0,0 +1,190 @@
+package binding_test
+
+import (
+	"log"
+	"os"
+	"time"
+
+	metricsHelpers "code.cloudfoundry.org/go-metric-registry/testhelpers"
+	"code.cloudfoundry.org/loggregator-agent-release/src/pkg/binding"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("AggregateStore", func() {
+	var (
+		metrics *metricsHelpers.SpyMetricsRegistry
+		logger  *log.Logger
+	)
+
+	BeforeEach(func() {
+		metrics = metricsHelpers.NewMetricsRegistry()
+		logger = log.New(GinkgoWriter, "", 0)
+	})
+
+	urls := func(bindings []binding.Binding) []string {
+		var urls []string
+		for _, b := range bindings {
+			urls = append(urls, b.Url)
+		}
+		return urls
+	}
+
+	It("encodes the drain options as URL parameters", func() {
+		aggDrainFile := makeAggDrainFile(`---
+- url: "syslog-tls://test-hostname:1000"
+  drain_data: metrics
+  disable_metadata: false
+  combine_metrics: true
+- url: "https://test2:1000/path?drain-data=logs"
+  drain_data: all
+- url: "syslog://test3:1000"
+`)
+		aggStore := binding.NewAggregateStore(aggDrainFile, binding.WithAggregateLogger(logger))
+		defer aggStore.Stop()
+
+		Expect(urls(aggStore.Get())).To(Equal([]string{
+			"syslog-tls://test-hostname:1000?combine-metrics=true&disable-metadata=false&drain-data=metrics",
+			"https://test2:1000/path?drain-data=all",
+			"syslog://test3:1000",
+		}))
+	})
+
+	It("skips invalid drains and counts them", func() {
+		aggDrainFile := makeAggDrainFile(`---
+- url: ""
+- url: "ftp://test-hostname:1000"
+- url: "syslog://"
+- url: "syslog://test-hostname:1000"
+  cert: cert
+- url: "syslog://test-hostname:1000"
+  drain_data: everything
+- url: "syslog://valid:1000"
+`)
+		aggStore := binding.NewAggregateStore(
+			aggDrainFile,
+			binding.WithAggregateLogger(logger),
+			binding.WithAggregateMetrics(metrics),
+		)
+		defer aggStore.Stop()
+
+		Expect(urls(aggStore.Get())).To(Equal([]string{"syslog://valid:1000"}))
+		Expect(metrics.GetMetricValue("invalid_aggregate_drains", nil)).To(Equal(5.0))
+	})
+
+	It("does not panic if the file cannot be read", func() {
+		aggStore := binding.NewAggregateStore("/does/not/exist", binding.WithAggregateLogger(logger))
+		defer aggStore.Stop()
+
+		Expect(aggStore.Get()).To(BeEmpty())
+	})
+
+	Context("with a runtime file", func() {
+		var (
+			aggDrainFile string
+			runtimeFile  string
+			aggStore     *binding.AggregateStore
+		)
+
+		BeforeEach(func() {
+			aggDrainFile = makeAggDrainFile(`---
+- url: "syslog://test-hostname:1000"
+`)
+			runtimeFile = aggDrainFile + ".runtime"
+			aggStore = binding.NewAggregateStore(
+				aggDrainFile,
+				binding.WithAggregateRuntimeFile(runtimeFile),
+				binding.WithAggregateReloadInterval(10*time.Millisecond),
+				binding.WithAggregateLogger(logger),
+				binding.WithAggregateMetrics(metrics),
+			)
+		})
+
+		AfterEach(func() {
+			aggStore.Stop()
+			os.Remove(runtimeFile)
+		})
+
+		It("adds the drains of the runtime file once it is written", func() {
+			Expect(urls(aggStore.Get())).To(Equal([]string{"syslog://test-hostname:1000"}))
+
+			writeAggDrainFile(runtimeFile, `---
+- url: "syslog-tls://siem:6514"
+- url: "ftp://invalid:21"
+`)
+
+			Eventually(func() []string {
+				return urls(aggStore.Get())
+			}).Should(Equal([]string{"syslog://test-hostname:1000", "syslog-tls://siem:6514"}))
+			Expect(metrics.GetMetricValue("invalid_aggregate_drains", nil)).To(Equal(1.0))
+		})
+
+		It("removes the drains of the runtime file once it is deleted", func() {
+			writeAggDrainFile(runtimeFile, `---
+- url: "syslog-tls://siem:6514"
+`)
+			Eventually(func() []string {
+				return urls(aggStore.Get())
+			}).Should(HaveLen(2))
+
+			Expect(os.Remove(runtimeFile)).To(Succeed())
+
+			Eventually(func() []string {
+				return urls(aggStore.Get())
+			}).Should(Equal([]string{"syslog://test-hostname:1000"}))
+		})
+	})
+
+	Context("when the file changes", func() {
+		var (
+			aggDrainFile string
+			aggStore     *binding.AggregateStore
+		)
+
+		BeforeEach(func() {
+			aggDrainFile = makeAggDrainFile(`---
+- url: "syslog://test-hostname:1000"
+`)
+			aggStore = binding.NewAggregateStore(
+				aggDrainFile,
+				binding.WithAggregateReloadInterval(10*time.Millisecond),
+				binding.WithAggregateLogger(logger),
+			)
+		})
+
+		AfterEach(func() {
+			aggStore.Stop()
+		})
+
+		It("reloads the drains", func() {
+			Expect(urls(aggStore.Get())).To(Equal([]string{"syslog://test-hostname:1000"}))
+
+			writeAggDrainFile(aggDrainFile, `---
+- url: "syslog://test-hostname:1000"
+- url: "syslog-tls://siem:6514"
+`)
+
+			Eventually(func() []string {
+				return urls(aggStore.Get())
+			}).Should(Equal([]string{"syslog://test-hostname:1000", "syslog-tls://siem:6514"}))
+		})
+
+		It("keeps the previous drains if the file is invalid", func() {
+			writeAggDrainFile(aggDrainFile, "not: [valid")
+
+			Consistently(func() []string {
+				return urls(aggStore.Get())
+			}, 100*time.Millisecond).Should(Equal([]string{"syslog://test-hostname:1000"}))
+		})
+	})
+})
+
+func writeAggDrainFile(name, contents string) {
+	err := os.WriteFile(name, []byte(contents), 0600)
+	Expect(err).ToNot(HaveOccurred())
+
+	// Make sure the change is detected even if the file system has a
+	// coarse modification time.
+	later := time.Now().Add(time.Second)
+	Expect(os.Chtimes(name, later, later)).To(Succeed())
+}
@@ -0,0 +1,51 @@
+package binding
+
+import "fmt"
+
+// APIVersion is a version of the binding API served by the Cloud Controller
+// and the binding cache.
+type APIVersion string
+
+const (
+	// APIVersionV2 is the current binding API. It is served by the Cloud
+	// Controller at /internal/v5/syslog_drain_urls and by the binding cache
+	// at /v2/bindings and /v2/aggregate.
+	APIVersionV2 APIVersion = "v2"
+	// APIVersionLegacy is the legacy binding API. It is served by the Cloud
+	// Controller at /internal/v4/syslog_drain_urls and by the binding cache
+	// at /bindings and /aggregate.
+	APIVersionLegacy APIVersion = "legacy"
+)
+
+// Compatibility selects the binding API versions a client uses. The zero
+// value only uses the current version.
+type Compatibility string
+
+const (
+	// CompatibilityNone only uses the current binding API.
+	CompatibilityNone Compatibility = "none"
+	// CompatibilityLegacyFallback uses the legacy binding API if the
+	// current one fails.
+	CompatibilityLegacyFallback Compatibility = "legacy-fallback"
+)
+
+// Versions returns the binding API versions in the order they are tried.
+func (c Compatibility) Versions() []APIVersion {
+	if c == CompatibilityLegacyFallback {
+		return []APIVersion{APIVersionV2, APIVersionLegacy}
+	}
+	return []APIVersion{APIVersionV2}
+}
+
+// UnmarshalEnv implements envstruct.Unmarshaller.
+func (c *Compatibility) UnmarshalEnv(v string) error {
+	switch Compatibility(v) {
+	case "":
+		*c = CompatibilityNone
+	case CompatibilityNone, CompatibilityLegacyFallback:
+		*c = Compatibility(v)
+	default:
+		return fmt.Errorf("invalid binding API compatibility %q: must be %q or %q", v, CompatibilityNone, CompatibilityLegacyFallback)
+	}
+	return nil
+}
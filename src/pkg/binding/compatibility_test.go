@@ -0,0 +1,30 @@
+package binding_test
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"code.cloudfoundry.org/loggregator-agent-release/src/pkg/binding"
+)
+
+var _ = Describe("Compatibility", func() {
+	It("only uses the current binding API by default", func() {
+		var c binding.Compatibility
+		Expect(c.UnmarshalEnv("")).To(Succeed())
+
+		Expect(c).To(Equal(binding.CompatibilityNone))
+		Expect(c.Versions()).To(Equal([]binding.APIVersion{binding.APIVersionV2}))
+	})
+
+	It("falls back to the legacy binding API", func() {
+		var c binding.Compatibility
+		Expect(c.UnmarshalEnv("legacy-fallback")).To(Succeed())
+
+		Expect(c.Versions()).To(Equal([]binding.APIVersion{binding.APIVersionV2, binding.APIVersionLegacy}))
+	})
+
+	It("rejects unknown modes", func() {
+		var c binding.Compatibility
+		Expect(c.UnmarshalEnv("v4")).ToNot(Succeed())
+	})
+})
@@ -13,6 +13,11 @@ import (
 	"code.cloudfoundry.org/loggregator-agent-release/src/pkg/egress/syslog"
 )
 
+// minChurnRemovals is the number of bindings a refresh must remove before the
+// churn protection applies, so that single unbinds of small deployments are
+// not held back.
+const minChurnRemovals = 3
+
 type Fetcher interface {
 	FetchBindings() ([]syslog.Binding, error)
 	DrainLimit() int
@@ -40,15 +45,60 @@ type Manager struct {
 	drainCountMetric          metrics.Gauge
 	aggregateDrainCountMetric metrics.Gauge
 	activeDrainCountMetric    metrics.Gauge
+	rejectedRefreshMetric     metrics.Counter
 	activeDrainCount          int64
 
+	maxChurnPercent  int
+	churnGracePeriod time.Duration
+	suspiciousSince  time.Time
+	removalHoldDown  time.Duration
+	missingSince     map[syslog.Binding]time.Time
+
 	sourceDrainMap    map[string]map[syslog.Binding]drainHolder
 	sourceAccessTimes map[string]time.Time
 
+	refresh             chan struct{}
+	refreshBindings     chan struct{}
+	refreshOnNewSources bool
+
 	log *log.Logger
 	mu  sync.Mutex
 }
 
+// ManagerOption allows a Manager to be customized.
+type ManagerOption func(*Manager)
+
+// WithMaxChurn returns a ManagerOption that holds back the removals of
+// binding refreshes that would remove more than the given percentage of the
+// bindings, e.g. because the binding provider returned a truncated result.
+// The additions of such refreshes are still applied, and the removals are
+// applied once they have been returned for longer than the grace period.
+// Refreshes removing fewer than three bindings are always accepted.
+func WithMaxChurn(percent int, gracePeriod time.Duration) ManagerOption {
+	return func(m *Manager) {
+		m.maxChurnPercent = percent
+		m.churnGracePeriod = gracePeriod
+	}
+}
+
+// WithRemovalHoldDown returns a ManagerOption that only removes a binding
+// once it has been missing from the refreshed bindings for the given
+// duration.
+func WithRemovalHoldDown(d time.Duration) ManagerOption {
+	return func(m *Manager) {
+		m.removalHoldDown = d
+	}
+}
+
+// WithRefreshOnNewSources returns a ManagerOption that refreshes the
+// bindings when drains are requested for a source ID for the first time. It
+// is meant to be used when only the bindings of active sources are fetched.
+func WithRefreshOnNewSources() ManagerOption {
+	return func(m *Manager) {
+		m.refreshOnNewSources = true
+	}
+}
+
 func NewManager(
 	bf Fetcher,
 	af Fetcher,
@@ -58,6 +108,7 @@ func NewManager(
 	idleTimeout time.Duration,
 	aggregateConnectionRefreshInterval time.Duration,
 	log *log.Logger,
+	opts ...ManagerOption,
 ) *Manager {
 	tagOpt := metrics.WithMetricLabels(map[string]string{"unit": "count"})
 	drainCount := m.NewGauge(
@@ -75,6 +126,10 @@ func NewManager(
 		"Current number of active syslog drains including app and aggregate drains.",
 		tagOpt,
 	)
+	rejectedRefreshes := m.NewCounter(
+		"rejected_binding_refreshes",
+		"Total number of binding refreshes whose removals were held back for removing too many bindings.",
+	)
 
 	manager := &Manager{
 		bf:                                 bf,
@@ -86,10 +141,17 @@ func NewManager(
 		drainCountMetric:                   drainCount,
 		aggregateDrainCountMetric:          aggregateDrainCount,
 		activeDrainCountMetric:             activeDrains,
+		rejectedRefreshMetric:              rejectedRefreshes,
+		missingSince:                       make(map[syslog.Binding]time.Time),
 		sourceDrainMap:                     make(map[string]map[syslog.Binding]drainHolder),
 		sourceAccessTimes:                  make(map[string]time.Time),
+		refresh:                            make(chan struct{}, 1),
+		refreshBindings:                    make(chan struct{}, 1),
 		log:                                log,
 	}
+	for _, o := range opts {
+		o(manager)
+	}
 
 	go manager.idleCleanupLoop()
 
@@ -101,7 +163,6 @@ func (m *Manager) Run() {
 	if m.bf != nil {
 		bindings, _ = m.bf.FetchBindings()
 	}
-	m.drainCountMetric.Set(float64(len(bindings)))
 	m.updateAppDrains(bindings)
 	m.refreshAggregateConnections()
 
@@ -117,24 +178,88 @@ func (m *Manager) Run() {
 		case <-connectionTicker.C:
 			m.refreshAggregateConnections()
 		case <-bindingTicker.C:
-			if m.bf != nil {
-				bindings, err := m.bf.FetchBindings()
-				if err != nil {
-					m.log.Printf("failed to fetch bindings: %s", err)
-					continue
-				}
+			m.fetchAppDrains()
+		case <-m.refresh:
+			m.fetchAppDrains()
+			m.refreshAggregateConnections()
+		case <-m.refreshBindings:
+			m.fetchAppDrains()
+		}
+	}
+}
 
-				m.drainCountMetric.Set(float64(len(bindings)))
-				m.updateAppDrains(bindings)
-			}
+// Refresh requests the bindings and aggregate drains to be fetched again
+// without waiting for the next polling interval.
+func (m *Manager) Refresh() {
+	select {
+	case m.refresh <- struct{}{}:
+	default:
+	}
+}
+
+// RefreshBindings requests the bindings to be fetched again without waiting
+// for the next polling interval. Aggregate drains are not refreshed.
+func (m *Manager) RefreshBindings() {
+	select {
+	case m.refreshBindings <- struct{}{}:
+	default:
+	}
+}
+
+// DrainState describes a binding held by the Manager.
+type DrainState struct {
+	Binding    syslog.Binding
+	Active     bool
+	LastAccess time.Time
+}
+
+// DrainStates returns the state of all app and aggregate drains.
+func (m *Manager) DrainStates() []DrainState {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var states []DrainState
+	for sourceID, bindingWriterMap := range m.sourceDrainMap {
+		for b, dh := range bindingWriterMap {
+			states = append(states, DrainState{
+				Binding:    b,
+				Active:     dh.drainWriter != nil,
+				LastAccess: m.sourceAccessTimes[sourceID],
+			})
 		}
 	}
+
+	for _, dh := range m.aggregateDrains {
+		states = append(states, DrainState{
+			Binding: dh.binding,
+			Active:  dh.drainWriter != nil,
+		})
+	}
+
+	return states
+}
+
+func (m *Manager) fetchAppDrains() {
+	if m.bf == nil {
+		return
+	}
+
+	bindings, err := m.bf.FetchBindings()
+	if err != nil {
+		m.log.Printf("failed to fetch bindings: %s", err)
+		return
+	}
+
+	m.updateAppDrains(bindings)
 }
 
 func (m *Manager) GetDrains(sourceID string) []egress.Writer {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
+	if _, ok := m.sourceAccessTimes[sourceID]; !ok && m.refreshOnNewSources {
+		m.RefreshBindings()
+	}
 	m.sourceAccessTimes[sourceID] = time.Now()
 	var drains []egress.Writer
 	for binding, drainHolder := range m.sourceDrainMap[sourceID] {
@@ -162,14 +287,44 @@ func (m *Manager) GetDrains(sourceID string) []egress.Writer {
 	return drains
 }
 
+// SourceActive reports whether drains for the given source ID have been
+// requested within the idle timeout.
+func (m *Manager) SourceActive(sourceID string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	_, ok := m.sourceAccessTimes[sourceID]
+	return ok
+}
+
+// ActiveSources returns the source IDs whose drains have been requested
+// within the idle timeout.
+func (m *Manager) ActiveSources() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	sources := make([]string, 0, len(m.sourceAccessTimes))
+	for sourceID := range m.sourceAccessTimes {
+		sources = append(sources, sourceID)
+	}
+	return sources
+}
+
+// updateAppDrains replaces the app drains with the given bindings. The
+// removals are held back if the refresh removes too many bindings.
 func (m *Manager) updateAppDrains(bindings []syslog.Binding) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
 	newBindings := make(map[syslog.Binding]bool)
-
 	for _, b := range bindings {
 		newBindings[b] = true
+	}
+
+	holdRemovals := m.rejectRefresh(newBindings)
+
+	for _, b := range bindings {
+		delete(m.missingSince, b)
 
 		_, ok := m.sourceDrainMap[b.AppId][b]
 		if ok {
@@ -184,17 +339,84 @@ func (m *Manager) updateAppDrains(bindings []syslog.Binding) {
 		m.sourceDrainMap[b.AppId][b] = newDrainHolder()
 	}
 
+	if holdRemovals {
+		m.setDrainCount()
+		return
+	}
+
 	// Delete all bindings that are not in updated list of bindings.
 	// TODO: this is not optimal, consider lazily storing bindings
+	now := time.Now()
 	for _, bindingWriterMap := range m.sourceDrainMap {
 		for b := range bindingWriterMap {
 			if newBindings[b] {
 				continue
 			}
 
+			if m.removalHoldDown > 0 {
+				since, ok := m.missingSince[b]
+				if !ok {
+					m.missingSince[b] = now
+					continue
+				}
+				if now.Sub(since) < m.removalHoldDown {
+					continue
+				}
+			}
+
+			delete(m.missingSince, b)
 			m.removeDrain(bindingWriterMap, b)
 		}
 	}
+
+	m.setDrainCount()
+}
+
+// setDrainCount reports the number of drains including the drains whose
+// removal is held back.
+func (m *Manager) setDrainCount() {
+	var count int
+	for _, bindingWriterMap := range m.sourceDrainMap {
+		count += len(bindingWriterMap)
+	}
+	m.drainCountMetric.Set(float64(count))
+}
+
+// rejectRefresh reports whether the refresh removes at least
+// minChurnRemovals bindings and more than the max churn percentage of the
+// bindings, and has not been returned for longer than the grace period.
+func (m *Manager) rejectRefresh(newBindings map[syslog.Binding]bool) bool {
+	if m.maxChurnPercent <= 0 {
+		return false
+	}
+
+	var current, removed int
+	for _, bindingWriterMap := range m.sourceDrainMap {
+		for b := range bindingWriterMap {
+			current++
+			if !newBindings[b] {
+				removed++
+			}
+		}
+	}
+
+	if removed < minChurnRemovals || removed*100 <= m.maxChurnPercent*current {
+		m.suspiciousSince = time.Time{}
+		return false
+	}
+
+	if m.suspiciousSince.IsZero() {
+		m.suspiciousSince = time.Now()
+	}
+	if time.Since(m.suspiciousSince) >= m.churnGracePeriod {
+		m.log.Printf("accepting binding refresh removing %d of %d bindings after %s", removed, current, m.churnGracePeriod)
+		m.suspiciousSince = time.Time{}
+		return false
+	}
+
+	m.rejectedRefreshMetric.Add(1)
+	m.log.Printf("holding back binding refresh removing %d of %d bindings", removed, current)
+	return true
 }
 
 func (m *Manager) resetAggregateDrains() {
@@ -207,6 +429,7 @@ func (m *Manager) resetAggregateDrains() {
 
 	for _, b := range bindings {
 		aggregateDrainHolder := newDrainHolder()
+		aggregateDrainHolder.binding = b
 
 		writer, err := m.connector.Connect(aggregateDrainHolder.ctx, b)
 		if err != nil {
@@ -300,6 +523,7 @@ func closeDrains(drainsToBeClosed []drainHolder) {
 }
 
 type drainHolder struct {
+	binding     syslog.Binding
 	ctx         context.Context
 	cancel      func()
 	drainWriter egress.Writer
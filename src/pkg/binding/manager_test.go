@@ -3,7 +3,9 @@ package binding_test
 import (
 	"context"
 	"errors"
+	"fmt"
 	"log"
+	"net/url"
 	"strings"
 	"sync"
 	"sync/atomic"
@@ -460,6 +462,310 @@ var _ = Describe("Manager", func() {
 		}).Should(Equal(2.0))
 	})
 
+	It("reports sources as active until they are idle", func() {
+		m := binding.NewManager(
+			stubAppBindingFetcher,
+			stubAggregateBindingFetcher,
+			spyConnector,
+			spyMetricClient,
+			10*time.Second,
+			100*time.Millisecond,
+			10*time.Minute,
+			log.New(GinkgoWriter, "", 0),
+		)
+
+		Expect(m.SourceActive("app-1")).To(BeFalse())
+
+		m.GetDrains("app-1")
+		Expect(m.SourceActive("app-1")).To(BeTrue())
+
+		Eventually(func() bool {
+			return m.SourceActive("app-1")
+		}).Should(BeFalse())
+	})
+
+	It("refreshes bindings on request", func() {
+		stubAppBindingFetcher.bindings <- []syslog.Binding{binding1}
+		stubAggregateBindingFetcher.bindings <- []syslog.Binding{}
+
+		m := binding.NewManager(
+			stubAppBindingFetcher,
+			stubAggregateBindingFetcher,
+			spyConnector,
+			spyMetricClient,
+			10*time.Minute,
+			10*time.Minute,
+			10*time.Minute,
+			log.New(GinkgoWriter, "", 0),
+		)
+		go m.Run()
+
+		Eventually(func() float64 {
+			return spyMetricClient.GetMetric("drains", map[string]string{"unit": "count"}).Value()
+		}).Should(BeNumerically("==", 1))
+
+		stubAppBindingFetcher.bindings <- []syslog.Binding{binding1, binding2}
+		stubAggregateBindingFetcher.bindings <- []syslog.Binding{aggregateBinding1}
+		m.Refresh()
+
+		Eventually(func() float64 {
+			return spyMetricClient.GetMetric("drains", map[string]string{"unit": "count"}).Value()
+		}).Should(BeNumerically("==", 2))
+		Eventually(func() float64 {
+			return spyMetricClient.GetMetric("aggregate_drains", map[string]string{"unit": "count"}).Value()
+		}).Should(BeNumerically("==", 1))
+	})
+
+	It("refreshes only the app bindings on request", func() {
+		stubAppBindingFetcher.bindings <- []syslog.Binding{binding1}
+		stubAggregateBindingFetcher.bindings <- []syslog.Binding{}
+
+		m := binding.NewManager(
+			stubAppBindingFetcher,
+			stubAggregateBindingFetcher,
+			spyConnector,
+			spyMetricClient,
+			10*time.Minute,
+			10*time.Minute,
+			10*time.Minute,
+			log.New(GinkgoWriter, "", 0),
+		)
+		go m.Run()
+
+		Eventually(func() float64 {
+			return spyMetricClient.GetMetric("drains", map[string]string{"unit": "count"}).Value()
+		}).Should(BeNumerically("==", 1))
+
+		stubAppBindingFetcher.bindings <- []syslog.Binding{binding1, binding2}
+		stubAggregateBindingFetcher.bindings <- []syslog.Binding{aggregateBinding1}
+		m.RefreshBindings()
+
+		Eventually(func() float64 {
+			return spyMetricClient.GetMetric("drains", map[string]string{"unit": "count"}).Value()
+		}).Should(BeNumerically("==", 2))
+		Consistently(func() float64 {
+			return spyMetricClient.GetMetric("aggregate_drains", map[string]string{"unit": "count"}).Value()
+		}, 50*time.Millisecond).Should(BeNumerically("==", 0))
+	})
+
+	It("refreshes the bindings when drains are requested for new sources", func() {
+		stubAppBindingFetcher.bindings <- []syslog.Binding{}
+		stubAggregateBindingFetcher.bindings <- []syslog.Binding{}
+
+		m := binding.NewManager(
+			stubAppBindingFetcher,
+			stubAggregateBindingFetcher,
+			spyConnector,
+			spyMetricClient,
+			10*time.Minute,
+			10*time.Minute,
+			10*time.Minute,
+			log.New(GinkgoWriter, "", 0),
+			binding.WithRefreshOnNewSources(),
+		)
+		go m.Run()
+
+		Eventually(stubAppBindingFetcher.bindings).Should(BeEmpty())
+		Expect(m.ActiveSources()).To(BeEmpty())
+
+		stubAppBindingFetcher.bindings <- []syslog.Binding{binding1}
+		m.GetDrains("app-1")
+		Expect(m.ActiveSources()).To(ConsistOf("app-1"))
+
+		Eventually(func() int {
+			return len(m.GetDrains("app-1"))
+		}).Should(Equal(1))
+	})
+
+	It("does not deadlock when drain health reports while drains connect", func() {
+		health := syslog.NewDrainHealth(spyMetricClient, time.Millisecond)
+		connector := &healthConnector{spyConnector: spyConnector, health: health}
+
+		var bindings []syslog.Binding
+		for i := 0; i < 100; i++ {
+			bindings = append(bindings, syslog.Binding{
+				AppId:    fmt.Sprintf("app-%d", i),
+				Hostname: "host",
+				Drain:    syslog.Drain{Url: "syslog://drain.url.com"},
+			})
+		}
+		stubAppBindingFetcher.bindings <- bindings
+
+		m := binding.NewManager(
+			stubAppBindingFetcher,
+			stubAggregateBindingFetcher,
+			connector,
+			spyMetricClient,
+			10*time.Minute,
+			10*time.Minute,
+			10*time.Minute,
+			log.New(GinkgoWriter, "", 0),
+		)
+		go m.Run()
+		go health.Run(m.SourceActive)
+
+		done := make(chan struct{})
+		go func() {
+			defer GinkgoRecover()
+			defer close(done)
+			for _, b := range bindings {
+				Eventually(func() int {
+					return len(m.GetDrains(b.AppId))
+				}).Should(Equal(1))
+			}
+		}()
+
+		Eventually(done, 10).Should(BeClosed())
+	})
+
+	Context("with churn protection", func() {
+		var m *binding.Manager
+
+		drainCount := func() float64 {
+			return spyMetricClient.GetMetric("drains", map[string]string{"unit": "count"}).Value()
+		}
+
+		refresh := func(bindings ...syslog.Binding) {
+			stubAppBindingFetcher.bindings <- bindings
+			m.RefreshBindings()
+			Eventually(stubAppBindingFetcher.bindings).Should(BeEmpty())
+		}
+
+		newManager := func(opts ...binding.ManagerOption) {
+			stubAppBindingFetcher.bindings <- []syslog.Binding{binding1, binding2, binding3}
+			stubAggregateBindingFetcher.bindings <- []syslog.Binding{}
+
+			m = binding.NewManager(
+				stubAppBindingFetcher,
+				stubAggregateBindingFetcher,
+				spyConnector,
+				spyMetricClient,
+				10*time.Minute,
+				10*time.Minute,
+				10*time.Minute,
+				log.New(GinkgoWriter, "", 0),
+				opts...,
+			)
+			go m.Run()
+
+			Eventually(drainCount).Should(Equal(3.0))
+		}
+
+		It("rejects refreshes removing too many bindings", func() {
+			newManager(binding.WithMaxChurn(50, time.Hour))
+
+			refresh()
+
+			Eventually(func() float64 {
+				return spyMetricClient.GetMetric("rejected_binding_refreshes", nil).Value()
+			}).Should(Equal(1.0))
+			Expect(drainCount()).To(Equal(3.0))
+			Expect(m.GetDrains("app-1")).To(HaveLen(1))
+
+			refresh(binding1, binding2)
+
+			Eventually(drainCount).Should(Equal(2.0))
+			Expect(m.GetDrains("app-3")).To(BeEmpty())
+		})
+
+		It("applies the additions of refreshes removing too many bindings", func() {
+			newManager(binding.WithMaxChurn(50, time.Hour))
+
+			binding4 := syslog.Binding{AppId: "app-4", Hostname: "host-4",
+				Drain: syslog.Drain{
+					Url: "syslog://drain.url.com",
+				},
+			}
+			refresh(binding4)
+
+			Eventually(func() []egress.Writer {
+				return m.GetDrains("app-4")
+			}).Should(HaveLen(1))
+			Expect(m.GetDrains("app-1")).To(HaveLen(1))
+			Expect(drainCount()).To(Equal(4.0))
+		})
+
+		It("accepts refreshes removing fewer than three bindings", func() {
+			newManager(binding.WithMaxChurn(50, time.Hour))
+
+			refresh(binding1)
+
+			Eventually(drainCount).Should(Equal(1.0))
+			Expect(m.GetDrains("app-2")).To(BeEmpty())
+			Expect(m.GetDrains("app-3")).To(BeEmpty())
+			Expect(spyMetricClient.GetMetric("rejected_binding_refreshes", nil).Value()).To(Equal(0.0))
+		})
+
+		It("accepts refreshes removing too many bindings after the grace period", func() {
+			newManager(binding.WithMaxChurn(50, 50*time.Millisecond))
+
+			refresh()
+			Expect(drainCount()).To(Equal(3.0))
+
+			time.Sleep(50 * time.Millisecond)
+			refresh()
+
+			Eventually(drainCount).Should(Equal(0.0))
+			Expect(m.GetDrains("app-1")).To(BeEmpty())
+		})
+
+		It("only removes bindings after the hold-down", func() {
+			newManager(binding.WithRemovalHoldDown(50 * time.Millisecond))
+
+			refresh(binding1, binding2)
+			Expect(m.GetDrains("app-3")).To(HaveLen(1))
+			Expect(drainCount()).To(Equal(3.0))
+
+			refresh(binding1, binding2, binding3)
+			time.Sleep(50 * time.Millisecond)
+			refresh(binding1, binding2)
+			Expect(m.GetDrains("app-3")).To(HaveLen(1))
+
+			time.Sleep(50 * time.Millisecond)
+			refresh(binding1, binding2)
+			Eventually(func() []egress.Writer {
+				return m.GetDrains("app-3")
+			}).Should(BeEmpty())
+			Expect(drainCount()).To(Equal(2.0))
+		})
+	})
+
+	It("returns the state of all drains", func() {
+		stubAppBindingFetcher.bindings <- []syslog.Binding{binding1, binding2}
+		stubAggregateBindingFetcher.bindings <- []syslog.Binding{aggregateBinding1}
+
+		m := binding.NewManager(
+			stubAppBindingFetcher,
+			stubAggregateBindingFetcher,
+			spyConnector,
+			spyMetricClient,
+			10*time.Minute,
+			10*time.Minute,
+			10*time.Minute,
+			log.New(GinkgoWriter, "", 0),
+		)
+		go m.Run()
+
+		Eventually(m.DrainStates).Should(HaveLen(3))
+		m.GetDrains("app-1")
+
+		states := m.DrainStates()
+		Expect(states).To(ContainElement(SatisfyAll(
+			HaveField("Binding", binding1),
+			HaveField("Active", BeTrue()),
+			HaveField("LastAccess", Not(BeZero())),
+		)))
+		Expect(states).To(ContainElement(SatisfyAll(
+			HaveField("Binding", binding2),
+			HaveField("Active", BeFalse()),
+			HaveField("LastAccess", BeZero()),
+		)))
+		Expect(states).To(ContainElement(SatisfyAll(
+			HaveField("Binding", aggregateBinding1),
+			HaveField("Active", BeTrue()),
+		)))
+	})
+
 	It("bad drains don't report on active drains", func() {
 		stubAppBindingFetcher.bindings <- []syslog.Binding{
 			{AppId: "app-1", Hostname: "host-1",
@@ -604,6 +910,25 @@ func (c *spyConnector) Connect(ctx context.Context, b syslog.Binding) (egress.Wr
 	return nil, errors.New("invalid hostname")
 }
 
+// healthConnector tracks the drain health of each binding it connects like
+// the syslog connector does.
+type healthConnector struct {
+	*spyConnector
+	health *syslog.DrainHealth
+}
+
+func (c *healthConnector) Connect(ctx context.Context, b syslog.Binding) (egress.Writer, error) {
+	u, err := url.Parse(b.Drain.Url)
+	if err != nil {
+		return nil, err
+	}
+	// Give drain health reports a chance to run while the manager lock is
+	// held.
+	time.Sleep(time.Millisecond)
+	c.health.Status(&syslog.URLBinding{Context: ctx, AppID: b.AppId, URL: u})
+	return c.spyConnector.Connect(ctx, b)
+}
+
 type stubBindingFetcher struct {
 	bindings chan []syslog.Binding
 	errors   chan error
@@ -2,8 +2,13 @@ package binding
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
 	"log"
+	"math/rand"
 	"net/http"
+	"os"
+	"sync"
 	"time"
 
 	metrics "code.cloudfoundry.org/go-metric-registry"
@@ -18,6 +23,114 @@ type Poller struct {
 	logger                     *log.Logger
 	bindingRefreshErrorCounter metrics.Counter
 	lastBindingCount           metrics.Gauge
+
+	snapshotFile string
+	snapshotAge  metrics.Gauge
+	lastRefresh  time.Time
+
+	elector Elector
+	leader  BindingGetter
+
+	compatibility Compatibility
+	fallbacks     metrics.Counter
+
+	maxBackoff   time.Duration
+	pageInterval time.Duration
+	lastPage     time.Time
+	pages        map[int]cachedPage
+
+	pollDuration     metrics.Gauge
+	pagesFetched     metrics.Gauge
+	pagesNotModified metrics.Gauge
+
+	mu       sync.Mutex
+	status   PollStatus
+	failures int
+}
+
+// defaultMaxPollBackoff is the longest time between polls after
+// consecutive failures unless WithPollBackoff is given.
+const defaultMaxPollBackoff = 5 * time.Minute
+
+// cachedPage is a page of bindings along with the validators the binding
+// provider returned for it.
+type cachedPage struct {
+	etag         string
+	lastModified string
+	results      []Binding
+	nextID       int
+}
+
+// maxPollErrors is the number of poll errors kept in the PollStatus.
+const maxPollErrors = 20
+
+// PollStatus describes the recent polls of the binding provider.
+type PollStatus struct {
+	LastPoll    time.Time `json:"last_poll"`
+	LastSuccess time.Time `json:"last_success"`
+	// Source is where the bindings of the last successful poll came from.
+	// It is one of api, legacy_api or leader.
+	Source string `json:"source"`
+	// Bindings is the number of bindings of the last successful poll as
+	// counted by CalculateBindingCount.
+	Bindings int         `json:"bindings"`
+	Errors   []PollError `json:"errors"`
+}
+
+// PollError is a failed poll of the binding provider.
+type PollError struct {
+	Time  time.Time `json:"time"`
+	Error string    `json:"error"`
+}
+
+// Elector decides whether this instance is the leader of the binding
+// caches.
+type Elector interface {
+	IsLeader() bool
+}
+
+// BindingGetter returns the bindings of another binding cache.
+type BindingGetter interface {
+	Get() ([]Binding, error)
+}
+
+// PollerOption allows a Poller to be customized.
+type PollerOption func(*Poller)
+
+// WithSnapshotFile returns a PollerOption that writes the bindings to the
+// given file after each successful poll. The bindings of the file are
+// stored before the first poll so that they are served if the binding
+// provider is unavailable.
+func WithSnapshotFile(path string) PollerOption {
+	return func(p *Poller) {
+		p.snapshotFile = path
+	}
+}
+
+// WithPollBackoff sets the longest time between polls. After consecutive
+// failures the polling interval is doubled per failure up to this maximum.
+// A maximum that is not greater than the polling interval disables the
+// backoff. Defaults to five minutes.
+func WithPollBackoff(max time.Duration) PollerOption {
+	return func(p *Poller) {
+		p.maxBackoff = max
+	}
+}
+
+// WithCompatibility sets the binding API versions the Poller uses. By
+// default only the current version is used.
+func WithCompatibility(c Compatibility) PollerOption {
+	return func(p *Poller) {
+		p.compatibility = c
+	}
+}
+
+// WithPageInterval sets the minimum time between two page requests to the
+// binding provider.
+func WithPageInterval(d time.Duration) PollerOption {
+	return func(p *Poller) {
+		p.pageInterval = d
+	}
 }
 
 type client interface {
@@ -25,6 +138,12 @@ type client interface {
 	LegacyGet(int) (*http.Response, error)
 }
 
+// conditionalClient is a client that can send the validators of a previous
+// response so that unchanged pages are answered with 304 Not Modified.
+type conditionalClient interface {
+	ConditionalGet(nextID int, etag, lastModified string) (*http.Response, error)
+}
+
 type Credentials struct {
 	Cert string `json:"cert" yaml:"cert"`
 	Key  string `json:"key" yaml:"key"`
@@ -42,13 +161,6 @@ type Binding struct {
 	Credentials []Credentials `json:"credentials" yaml:"credentials"`
 }
 
-type AggBinding struct {
-	Url  string `yaml:"url"`
-	Cert string `yaml:"cert"`
-	Key  string `yaml:"key"`
-	CA   string `yaml:"ca"`
-}
-
 type LegacyBinding struct {
 	AppID       string   `json:"app_id"`
 	Drains      []string `json:"drains"`
@@ -64,7 +176,25 @@ type LegacySetter interface {
 	Set([]LegacyBinding)
 }
 
-func NewPoller(ac client, pi time.Duration, s Setter, legacyStore LegacySetter, m Metrics, logger *log.Logger) *Poller {
+// WithLeaderElection returns a PollerOption that only polls the binding
+// provider while the elector reports this instance as leader. Otherwise the
+// bindings are replicated from the leader.
+func WithLeaderElection(e Elector, leader BindingGetter) PollerOption {
+	return func(p *Poller) {
+		p.elector = e
+		p.leader = leader
+	}
+}
+
+func NewPoller(
+	ac client,
+	pi time.Duration,
+	s Setter,
+	legacyStore LegacySetter,
+	m Metrics,
+	logger *log.Logger,
+	opts ...PollerOption,
+) *Poller {
 	p := &Poller{
 		apiClient:       ac,
 		pollingInterval: pi,
@@ -79,82 +209,335 @@ func NewPoller(ac client, pi time.Duration, s Setter, legacyStore LegacySetter,
 			"last_binding_refresh_count",
 			"Current number of bindings received from binding provider during last refresh.",
 		),
+		pollDuration: m.NewGauge(
+			"last_binding_poll_duration_seconds",
+			"Duration of the last poll of the binding provider.",
+		),
+		pagesFetched: m.NewGauge(
+			"last_binding_poll_pages",
+			"Number of pages requested from the binding provider during the last poll.",
+		),
+		pagesNotModified: m.NewGauge(
+			"last_binding_poll_not_modified_pages",
+			"Number of pages the binding provider reported as not modified during the last poll.",
+		),
+		maxBackoff: defaultMaxPollBackoff,
+		pages:      make(map[int]cachedPage),
+	}
+	for _, o := range opts {
+		o(p)
+	}
+
+	if versions := p.compatibility.Versions(); len(versions) > 1 {
+		p.fallbacks = m.NewCounter(
+			"binding_api_fallback",
+			"Total number of polls that fell back to an older binding API version.",
+			metrics.WithMetricLabels(map[string]string{"version": string(versions[1])}),
+		)
+	}
+
+	if p.snapshotFile != "" {
+		p.snapshotAge = m.NewGauge(
+			"binding_snapshot_age_seconds",
+			"Number of seconds since the bindings were last received from the binding provider.",
+		)
+		p.loadSnapshot()
 	}
+
 	p.poll()
+	p.updateSnapshotAge()
 	return p
 }
 
 func (p *Poller) Poll() {
-	t := time.NewTicker(p.pollingInterval)
+	t := time.NewTimer(p.nextPollDelay())
 
 	for range t.C {
 		p.poll()
+		p.updateSnapshotAge()
+		t.Reset(p.nextPollDelay())
+	}
+}
+
+// nextPollDelay returns the polling interval, doubled for each consecutive
+// failed poll up to the maximum backoff. A random jitter of up to a tenth
+// of the delay is added to backed off delays so that binding caches do not
+// retry in lockstep.
+func (p *Poller) nextPollDelay() time.Duration {
+	p.mu.Lock()
+	failures := p.failures
+	p.mu.Unlock()
+
+	if failures == 0 || p.maxBackoff <= p.pollingInterval {
+		return p.pollingInterval
+	}
+
+	d := p.pollingInterval
+	for i := 0; i < failures && d < p.maxBackoff; i++ {
+		d *= 2
+	}
+	if d > p.maxBackoff {
+		d = p.maxBackoff
+	}
+	//nolint:gosec
+	return d + time.Duration(rand.Int63n(int64(d)/10+1))
+}
+
+// waitForPage blocks until the page interval has passed since the last
+// page request.
+func (p *Poller) waitForPage() {
+	if p.pageInterval <= 0 {
+		return
+	}
+	if wait := p.pageInterval - time.Since(p.lastPage); wait > 0 {
+		time.Sleep(wait)
+	}
+	p.lastPage = time.Now()
+}
+
+// getPage requests a page of bindings. If the page was received before and
+// the client supports conditional requests, the validators of the cached
+// page are sent along.
+func (p *Poller) getPage(nextID int) (*http.Response, error) {
+	p.waitForPage()
+
+	cc, ok := p.apiClient.(conditionalClient)
+	if !ok {
+		return p.apiClient.Get(nextID)
+	}
+	cached, ok := p.pages[nextID]
+	if !ok || (cached.etag == "" && cached.lastModified == "") {
+		return p.apiClient.Get(nextID)
+	}
+	return cc.ConditionalGet(nextID, cached.etag, cached.lastModified)
+}
+
+// Status returns the status of the recent polls. Errors are ordered from
+// newest to oldest.
+func (p *Poller) Status() PollStatus {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	s := p.status
+	s.Errors = make([]PollError, len(p.status.Errors))
+	for i, e := range p.status.Errors {
+		s.Errors[len(s.Errors)-1-i] = e
+	}
+	return s
+}
+
+func (p *Poller) pollStarted() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.status.LastPoll = time.Now()
+}
+
+func (p *Poller) pollSucceeded(source string, bindings int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.status.LastSuccess = time.Now()
+	p.status.Source = source
+	p.status.Bindings = bindings
+	p.failures = 0
+}
+
+// pollFailed logs the error and records it in the PollStatus.
+func (p *Poller) pollFailed(format string, v ...any) {
+	msg := fmt.Sprintf(format, v...)
+	p.logger.Print(msg)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.failures++
+	p.status.Errors = append(p.status.Errors, PollError{Time: time.Now(), Error: msg})
+	if len(p.status.Errors) > maxPollErrors {
+		p.status.Errors = p.status.Errors[len(p.status.Errors)-maxPollErrors:]
+	}
+}
+
+func (p *Poller) loadSnapshot() {
+	s, err := readSnapshot(p.snapshotFile)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			p.logger.Printf("failed to load bindings snapshot: %s", err)
+		}
+		return
+	}
+
+	p.logger.Printf("loaded %d bindings from snapshot taken at %s", len(s.Bindings), s.Timestamp.Format(time.RFC3339))
+	p.lastRefresh = s.Timestamp
+	p.store.Set(s.Bindings, CalculateBindingCount(s.Bindings))
+	p.legacyStore.Set(ToLegacyBindings(s.Bindings))
+}
+
+func (p *Poller) updateSnapshotAge() {
+	if p.snapshotAge == nil || p.lastRefresh.IsZero() {
+		return
+	}
+	p.snapshotAge.Set(time.Since(p.lastRefresh).Seconds())
+}
+
+func (p *Poller) setBindings(source string, bindings []Binding, legacyBindings []LegacyBinding) {
+	bindingCount := CalculateBindingCount(bindings)
+	p.pollSucceeded(source, bindingCount)
+	p.lastBindingCount.Set(float64(bindingCount))
+	p.store.Set(bindings, bindingCount)
+	p.legacyStore.Set(legacyBindings)
+
+	if p.snapshotFile == "" {
+		return
+	}
+	p.lastRefresh = time.Now()
+	err := writeSnapshot(p.snapshotFile, snapshot{Timestamp: p.lastRefresh, Bindings: bindings})
+	if err != nil {
+		p.logger.Printf("failed to write bindings snapshot: %s", err)
 	}
 }
 
 func (p *Poller) poll() {
-	nextID := 0
-	var bindings []Binding
-	for {
-		resp, err := p.apiClient.Get(nextID)
-		if err != nil {
-			p.bindingRefreshErrorCounter.Add(1)
-			p.logger.Printf("failed to get page %d from internal bindings endpoint: %s", nextID, err)
+	p.pollStarted()
+	start := time.Now()
+	defer func() {
+		p.pollDuration.Set(time.Since(start).Seconds())
+	}()
+
+	if p.elector != nil && !p.elector.IsLeader() {
+		p.replicate()
+		return
+	}
+
+	versions := p.compatibility.Versions()
+	for i, v := range versions {
+		err := p.pollVersion(v)
+		if err == nil {
 			return
 		}
-		if resp.StatusCode != http.StatusOK {
-			p.logger.Printf("unexpected response from internal bindings endpoint. status code: %d, falling back to legacy endpoint", resp.StatusCode)
-			p.pollLegacyFallback()
+
+		var verr *versionError
+		if !errors.As(err, &verr) || i == len(versions)-1 {
+			p.bindingRefreshErrorCounter.Add(1)
+			p.pollFailed("%s", err)
 			return
 		}
+		p.logger.Printf("%s, falling back to %s binding API", err, versions[i+1])
+		p.fallbacks.Add(1)
+	}
+}
+
+// versionError is returned if the binding provider answers with an
+// unexpected status code or body, which indicates that it does not support
+// the requested binding API version.
+type versionError struct {
+	msg string
+}
+
+func (e *versionError) Error() string {
+	return e.msg
+}
 
-		var aResp apiResponse
-		err = json.NewDecoder(resp.Body).Decode(&aResp)
+func newVersionError(format string, v ...any) error {
+	return &versionError{msg: fmt.Sprintf(format, v...)}
+}
+
+func (p *Poller) pollVersion(v APIVersion) error {
+	switch v {
+	case APIVersionV2:
+		return p.pollV2()
+	case APIVersionLegacy:
+		return p.pollLegacy()
+	default:
+		return fmt.Errorf("unsupported binding API version %q", v)
+	}
+}
+
+func (p *Poller) pollV2() error {
+	nextID := 0
+	var bindings []Binding
+	pages := make(map[int]cachedPage)
+	var notModified int
+	for {
+		resp, err := p.getPage(nextID)
 		if err != nil {
-			p.logger.Printf("failed to decode JSON: %s, falling back to legacy endpoint", err)
-			p.pollLegacyFallback()
-			return
+			return fmt.Errorf("failed to get page %d from internal bindings endpoint: %s", nextID, err)
 		}
 
-		bindings = append(bindings, aResp.Results...)
-		nextID = aResp.NextID
+		page, ok := p.pages[nextID]
+		switch {
+		case resp.StatusCode == http.StatusNotModified && ok:
+			resp.Body.Close()
+			notModified++
+		case resp.StatusCode != http.StatusOK:
+			resp.Body.Close()
+			return newVersionError("unexpected response from internal bindings endpoint. status code: %d", resp.StatusCode)
+		default:
+			var aResp apiResponse
+			err = json.NewDecoder(resp.Body).Decode(&aResp)
+			resp.Body.Close()
+			if err != nil {
+				return newVersionError("failed to decode JSON: %s", err)
+			}
+			page = cachedPage{
+				etag:         resp.Header.Get("ETag"),
+				lastModified: resp.Header.Get("Last-Modified"),
+				results:      aResp.Results,
+				nextID:       aResp.NextID,
+			}
+		}
+
+		pages[nextID] = page
+		bindings = append(bindings, page.results...)
+		nextID = page.nextID
 
 		if nextID == 0 {
 			break
 		}
 	}
 
-	bindingCount := CalculateBindingCount(bindings)
-	p.lastBindingCount.Set(float64(bindingCount))
-	p.store.Set(bindings, bindingCount)
-	p.legacyStore.Set(ToLegacyBindings(bindings))
+	p.pages = pages
+	p.pagesFetched.Set(float64(len(pages)))
+	p.pagesNotModified.Set(float64(notModified))
+	p.setBindings("api", bindings, ToLegacyBindings(bindings))
+	return nil
+}
+
+// replicate sets the bindings of the leader. As the version of the bindings
+// is derived from their content, the Store serves the version of the leader
+// and clients can switch between the leader and its followers.
+func (p *Poller) replicate() {
+	bindings, err := p.leader.Get()
+	if err != nil {
+		p.bindingRefreshErrorCounter.Add(1)
+		p.pollFailed("failed to replicate bindings from leader: %s", err)
+		return
+	}
+
+	p.setBindings("leader", bindings, ToLegacyBindings(bindings))
 }
 
-func (p *Poller) pollLegacyFallback() {
+func (p *Poller) pollLegacy() error {
 	nextID := 0
 	var legacyBindings []LegacyBinding
 
 	for {
+		p.waitForPage()
 		resp, err := p.apiClient.LegacyGet(nextID)
 		if err != nil {
-			p.bindingRefreshErrorCounter.Add(1)
-			p.logger.Printf("failed to get page %d from internal legacy bindings endpoint: %s", nextID, err)
-			return
-		}
-		if resp.StatusCode != http.StatusOK {
-			p.logger.Printf("unexpected response from internal legacy bindings endpoint. status code: %d", resp.StatusCode)
-			return
+			return fmt.Errorf("failed to get page %d from internal legacy bindings endpoint: %s", nextID, err)
 		}
 
 		var aRespLegacy legacyApiResponse
-		err = json.NewDecoder(resp.Body).Decode(&aRespLegacy)
+		if resp.StatusCode == http.StatusOK {
+			err = json.NewDecoder(resp.Body).Decode(&aRespLegacy)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return newVersionError("unexpected response from internal legacy bindings endpoint. status code: %d", resp.StatusCode)
+		}
 		if err != nil {
-			p.logger.Printf("failed to decode legacy JSON: %s", err)
-			return
+			return newVersionError("failed to decode legacy JSON: %s", err)
 		}
 		if aRespLegacy.V5Available {
-			p.logger.Printf("V4 endpoint is deprecated, skipping v4 result parsing.")
-			return
+			return newVersionError("V4 endpoint is deprecated, skipping v4 result parsing")
 		}
 		for k, v := range aRespLegacy.Results {
 			legacyBindings = append(legacyBindings, LegacyBinding{
@@ -170,11 +553,8 @@ func (p *Poller) pollLegacyFallback() {
 			break
 		}
 	}
-	bindings := ToBindings(legacyBindings)
-	bindingCount := CalculateBindingCount(bindings)
-	p.lastBindingCount.Set(float64(bindingCount))
-	p.store.Set(bindings, bindingCount)
-	p.legacyStore.Set(legacyBindings)
+	p.setBindings("legacy_api", ToBindings(legacyBindings), legacyBindings)
+	return nil
 }
 
 func CalculateBindingCount(bindings []Binding) int {
@@ -7,6 +7,9 @@ import (
 	"io"
 	"log"
 	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
 	"sync/atomic"
 	"time"
 
@@ -256,7 +259,9 @@ var _ = Describe("Poller", func() {
 		apiClient.statusCode <- 404
 		apiClient.legacyErrors <- errors.New("expected")
 
-		p := binding.NewPoller(apiClient, 10*time.Millisecond, store, legacyStore, metrics, logger)
+		p := binding.NewPoller(apiClient, 10*time.Millisecond, store, legacyStore, metrics, logger,
+			binding.WithCompatibility(binding.CompatibilityLegacyFallback),
+		)
 		go p.Poll()
 
 		Eventually(func() float64 {
@@ -274,7 +279,9 @@ var _ = Describe("Poller", func() {
 			}{"app-id-0": {Drains: []string{"drain-0", "drain-1"}, Hostname: "app-hostname0"}},
 		}
 
-		p := binding.NewPoller(apiClient, 10*time.Millisecond, store, legacyStore, metrics, logger)
+		p := binding.NewPoller(apiClient, 10*time.Millisecond, store, legacyStore, metrics, logger,
+			binding.WithCompatibility(binding.CompatibilityLegacyFallback),
+		)
 		go p.Poll()
 
 		var expectedBindings []binding.Binding
@@ -311,6 +318,35 @@ var _ = Describe("Poller", func() {
 
 	})
 
+	It("does not fall back to the legacy API by default", func() {
+		apiClient.statusCode <- 404
+		apiClient.legacyBindings <- legacyResponse{
+			Results: map[string]struct {
+				Drains   []string
+				Hostname string
+			}{"app-id-0": {Drains: []string{"drain-0"}, Hostname: "app-hostname0"}},
+		}
+
+		binding.NewPoller(apiClient, time.Hour, store, legacyStore, metrics, logger)
+
+		Expect(store.bindings).ToNot(Receive())
+		Expect(apiClient.legacyBindings).To(HaveLen(1))
+		Expect(metrics.GetMetricValue("binding_refresh_error", nil)).To(Equal(1.0))
+		Expect(metrics.HasMetric("binding_api_fallback", map[string]string{"version": "legacy"})).To(BeFalse())
+	})
+
+	It("counts the fallbacks to the legacy API", func() {
+		apiClient.statusCode <- 404
+
+		binding.NewPoller(apiClient, time.Hour, store, legacyStore, metrics, logger,
+			binding.WithCompatibility(binding.CompatibilityLegacyFallback),
+		)
+
+		Expect(store.bindings).To(Receive())
+		Expect(metrics.GetMetricValue("binding_api_fallback", map[string]string{"version": "legacy"})).To(Equal(1.0))
+		Expect(metrics.GetMetricValue("binding_refresh_error", nil)).To(Equal(0.0))
+	})
+
 	It("fetches the next page with legacy fallback functionality and stores the result", func() {
 
 		apiClient.statusCode <- 404
@@ -328,7 +364,9 @@ var _ = Describe("Poller", func() {
 			}{"app-id-1": {Drains: []string{"drain-1", "drain-2"}, Hostname: "app-hostname1"}},
 		}
 
-		p := binding.NewPoller(apiClient, 10*time.Millisecond, store, legacyStore, metrics, logger)
+		p := binding.NewPoller(apiClient, 10*time.Millisecond, store, legacyStore, metrics, logger,
+			binding.WithCompatibility(binding.CompatibilityLegacyFallback),
+		)
 		go p.Poll()
 
 		var expectedBindings []binding.Binding
@@ -391,7 +429,9 @@ var _ = Describe("Poller", func() {
 			V5Available: true,
 		}
 
-		p := binding.NewPoller(apiClient, 10*time.Millisecond, store, legacyStore, metrics, logger)
+		p := binding.NewPoller(apiClient, 10*time.Millisecond, store, legacyStore, metrics, logger,
+			binding.WithCompatibility(binding.CompatibilityLegacyFallback),
+		)
 		go p.Poll()
 
 		Eventually(store.bindings).Should(BeEmpty())
@@ -402,7 +442,9 @@ var _ = Describe("Poller", func() {
 		apiClient.statusCode <- 404
 		apiClient.legacyStatusCode <- 404
 
-		p := binding.NewPoller(apiClient, 10*time.Millisecond, store, legacyStore, metrics, logger)
+		p := binding.NewPoller(apiClient, 10*time.Millisecond, store, legacyStore, metrics, logger,
+			binding.WithCompatibility(binding.CompatibilityLegacyFallback),
+		)
 		go p.Poll()
 
 		Eventually(store.bindings).Should(BeEmpty())
@@ -614,6 +656,241 @@ var _ = Describe("Poller", func() {
 
 	})
 
+	Context("status", func() {
+		It("reports the last successful poll", func() {
+			apiClient.bindings <- response{Results: []binding.Binding{
+				{
+					Url: "drain-1",
+					Credentials: []binding.Credentials{
+						{Apps: []binding.App{{AppID: "app-id-1"}, {AppID: "app-id-2"}, {AppID: "app-id-3"}}},
+					},
+				},
+				{
+					Url: "drain-2",
+					Credentials: []binding.Credentials{
+						{Apps: []binding.App{{AppID: "app-id-1"}}},
+					},
+				},
+			}}
+
+			p := binding.NewPoller(apiClient, time.Hour, store, legacyStore, metrics, logger)
+
+			status := p.Status()
+			Expect(status.LastPoll).ToNot(BeZero())
+			Expect(status.LastSuccess).ToNot(BeZero())
+			Expect(status.Source).To(Equal("api"))
+			Expect(status.Bindings).To(Equal(3))
+			Expect(metrics.GetMetricValue("last_binding_refresh_count", nil)).To(Equal(3.0))
+			Expect(status.Errors).To(BeEmpty())
+		})
+
+		It("reports the poll errors from newest to oldest", func() {
+			apiClient.statusCode <- 404
+			apiClient.legacyErrors <- errors.New("first")
+
+			p := binding.NewPoller(apiClient, 10*time.Millisecond, store, legacyStore, metrics, logger,
+				binding.WithCompatibility(binding.CompatibilityLegacyFallback),
+			)
+			Expect(p.Status().LastSuccess).To(BeZero())
+
+			apiClient.errors <- errors.New("second")
+			go p.Poll()
+
+			Eventually(func() string {
+				return p.Status().Source
+			}).Should(Equal("api"))
+			errs := p.Status().Errors
+			Expect(errs).To(HaveLen(2))
+			Expect(errs[0].Error).To(Equal("failed to get page 0 from internal bindings endpoint: second"))
+			Expect(errs[1].Error).To(Equal("failed to get page 0 from internal legacy bindings endpoint: first"))
+		})
+
+		It("keeps a limited number of poll errors", func() {
+			leader := &stubLeader{err: errors.New("unavailable")}
+			p := binding.NewPoller(apiClient, time.Millisecond, store, legacyStore, metrics, logger,
+				binding.WithLeaderElection(stubElector(false), leader),
+				binding.WithPollBackoff(0),
+			)
+			go p.Poll()
+
+			Eventually(func() float64 {
+				return metrics.GetMetricValue("binding_refresh_error", nil)
+			}).Should(BeNumerically(">", 25))
+			Expect(p.Status().Errors).To(HaveLen(20))
+		})
+	})
+
+	Context("with leader election", func() {
+		It("polls the binding provider as leader", func() {
+			leader := &stubLeader{bindings: []binding.Binding{{Url: "drain-2"}}}
+			apiClient.bindings <- response{Results: []binding.Binding{{Url: "drain-1"}}}
+
+			binding.NewPoller(apiClient, time.Hour, store, legacyStore, metrics, logger,
+				binding.WithLeaderElection(stubElector(true), leader),
+			)
+
+			Expect(store.bindings).To(Receive(Equal([]binding.Binding{{Url: "drain-1"}})))
+			Expect(leader.called).To(BeZero())
+		})
+
+		It("replicates the bindings of the leader as follower", func() {
+			leader := &stubLeader{bindings: []binding.Binding{{Url: "drain-2"}}}
+
+			binding.NewPoller(apiClient, time.Hour, store, legacyStore, metrics, logger,
+				binding.WithLeaderElection(stubElector(false), leader),
+			)
+
+			Expect(store.bindings).To(Receive(Equal([]binding.Binding{{Url: "drain-2"}})))
+			Expect(legacyStore.bindings).To(Receive())
+			Expect(apiClient.called()).To(BeZero())
+		})
+
+		It("serves the version of the leader as follower", func() {
+			leaderStore := binding.NewStore(metricsHelpers.NewMetricsRegistry())
+			leaderStore.Set([]binding.Binding{{Url: "drain-2"}, {Url: "drain-1"}}, 2)
+			leaderBindings, leaderVersion := leaderStore.GetVersioned()
+			followerStore := binding.NewStore(metricsHelpers.NewMetricsRegistry())
+
+			binding.NewPoller(apiClient, time.Hour, followerStore, legacyStore, metrics, logger,
+				binding.WithLeaderElection(stubElector(false), &stubLeader{bindings: leaderBindings}),
+			)
+
+			_, version := followerStore.GetVersioned()
+			Expect(version).To(Equal(leaderVersion))
+		})
+
+		It("keeps the bindings if the leader is unavailable", func() {
+			leader := &stubLeader{err: errors.New("unavailable")}
+
+			binding.NewPoller(apiClient, time.Hour, store, legacyStore, metrics, logger,
+				binding.WithLeaderElection(stubElector(false), leader),
+			)
+
+			Expect(store.bindings).ToNot(Receive())
+			Expect(metrics.GetMetricValue("binding_refresh_error", nil)).To(Equal(1.0))
+		})
+	})
+
+	Context("with conditional requests", func() {
+		var client *conditionalAPIClient
+
+		BeforeEach(func() {
+			client = &conditionalAPIClient{
+				pages: map[int]response{
+					0: {Results: []binding.Binding{{Url: "drain-1"}}, NextID: 1},
+					1: {Results: []binding.Binding{{Url: "drain-2"}}},
+				},
+				etags: map[int]string{0: `"a"`, 1: `"b"`},
+			}
+		})
+
+		It("sends the ETags of the previous poll and reuses unchanged pages", func() {
+			p := binding.NewPoller(client, 10*time.Millisecond, store, legacyStore, metrics, logger)
+			Expect(store.bindings).To(Receive())
+			Expect(client.received()).To(Equal([]string{"", ""}))
+
+			client.set(1, response{Results: []binding.Binding{{Url: "drain-3"}}}, `"c"`)
+			client.clear()
+			go p.Poll()
+
+			var bindings []binding.Binding
+			Eventually(store.bindings).Should(Receive(&bindings))
+			Expect(bindings).To(Equal([]binding.Binding{{Url: "drain-1"}, {Url: "drain-3"}}))
+			Expect(client.received()[:2]).To(Equal([]string{`"a"`, `"b"`}))
+
+			Eventually(func() float64 {
+				return metrics.GetMetricValue("last_binding_poll_not_modified_pages", nil)
+			}).Should(Equal(2.0))
+			Expect(metrics.GetMetricValue("last_binding_poll_pages", nil)).To(Equal(2.0))
+		})
+	})
+
+	It("backs off after consecutive failures", func() {
+		for i := 0; i < 100; i++ {
+			apiClient.errors <- errors.New("unavailable")
+		}
+		p := binding.NewPoller(apiClient, 10*time.Millisecond, store, legacyStore, metrics, logger,
+			binding.WithPollBackoff(time.Second),
+		)
+		go p.Poll()
+
+		// Without backoff there would be about 30 polls.
+		Consistently(apiClient.called, 300*time.Millisecond).Should(BeNumerically("<=", 8))
+	})
+
+	It("waits the page interval between page requests", func() {
+		apiClient.bindings <- response{Results: []binding.Binding{{Url: "drain-1"}}, NextID: 1}
+		apiClient.bindings <- response{Results: []binding.Binding{{Url: "drain-2"}}, NextID: 2}
+		apiClient.bindings <- response{Results: []binding.Binding{{Url: "drain-3"}}}
+
+		start := time.Now()
+		binding.NewPoller(apiClient, time.Hour, store, legacyStore, metrics, logger,
+			binding.WithPageInterval(50*time.Millisecond),
+		)
+
+		Expect(time.Since(start)).To(BeNumerically(">=", 100*time.Millisecond))
+		Expect(store.bindings).To(Receive(HaveLen(3)))
+		Expect(metrics.GetMetricValue("last_binding_poll_duration_seconds", nil)).To(BeNumerically(">=", 0.1))
+	})
+
+	Context("with a snapshot file", func() {
+		var snapshotFile string
+
+		BeforeEach(func() {
+			snapshotFile = filepath.Join(GinkgoT().TempDir(), "bindings.json")
+		})
+
+		It("writes the bindings to the snapshot file after a poll", func() {
+			apiClient.bindings <- response{
+				Results: []binding.Binding{{Url: "drain-1", Credentials: []binding.Credentials{{Key: "key"}}}},
+			}
+
+			binding.NewPoller(apiClient, time.Hour, store, legacyStore, metrics, logger, binding.WithSnapshotFile(snapshotFile))
+
+			fi, err := os.Stat(snapshotFile)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(fi.Mode().Perm()).To(Equal(os.FileMode(0600)))
+
+			contents, err := os.ReadFile(snapshotFile)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(contents).To(ContainSubstring(`"url":"drain-1"`))
+		})
+
+		It("stores the snapshot if the binding provider is unavailable", func() {
+			apiClient.bindings <- response{
+				Results: []binding.Binding{{Url: "drain-1"}},
+			}
+			binding.NewPoller(apiClient, time.Hour, newFakeStore(), newFakeLegacyStore(), metrics, logger, binding.WithSnapshotFile(snapshotFile))
+
+			apiClient.errors <- errors.New("unavailable")
+			metrics = metricsHelpers.NewMetricsRegistry()
+			binding.NewPoller(apiClient, time.Hour, store, legacyStore, metrics, logger, binding.WithSnapshotFile(snapshotFile))
+
+			var bindings []binding.Binding
+			Expect(store.bindings).To(Receive(&bindings))
+			Expect(bindings).To(Equal([]binding.Binding{{Url: "drain-1"}}))
+			Expect(store.bindings).ToNot(Receive())
+			Expect(legacyStore.bindings).To(Receive())
+
+			Expect(metrics.GetMetricValue("binding_snapshot_age_seconds", nil)).To(BeNumerically(">=", 0))
+		})
+
+		It("exports the age of the bindings", func() {
+			apiClient.errors <- errors.New("unavailable")
+			Expect(os.WriteFile(snapshotFile, []byte(`{"timestamp":"`+time.Now().Add(-time.Hour).Format(time.RFC3339)+`","bindings":[]}`), 0600)).To(Succeed())
+
+			binding.NewPoller(apiClient, time.Hour, store, legacyStore, metrics, logger, binding.WithSnapshotFile(snapshotFile))
+
+			Expect(metrics.GetMetricValue("binding_snapshot_age_seconds", nil)).To(BeNumerically("~", time.Hour.Seconds(), 5))
+		})
+
+		It("polls if the snapshot file does not exist", func() {
+			binding.NewPoller(apiClient, time.Hour, store, legacyStore, metrics, logger, binding.WithSnapshotFile(snapshotFile))
+
+			Expect(store.bindings).To(Receive())
+			Expect(store.bindings).ToNot(Receive())
+		})
+	})
 })
 
 type fakeAPIClient struct {
@@ -737,3 +1014,80 @@ type legacyResponse struct {
 	NextID      int  `json:"next_id"`
 	V5Available bool `json:"v5_available"`
 }
+
+// conditionalAPIClient serves pages with ETags and answers requests with a
+// matching If-None-Match header with 304 Not Modified.
+type conditionalAPIClient struct {
+	mu       sync.Mutex
+	pages    map[int]response
+	etags    map[int]string
+	requests []string
+}
+
+func (c *conditionalAPIClient) Get(nextID int) (*http.Response, error) {
+	return c.ConditionalGet(nextID, "", "")
+}
+
+func (c *conditionalAPIClient) ConditionalGet(nextID int, etag, _ string) (*http.Response, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.requests = append(c.requests, etag)
+
+	header := http.Header{}
+	header.Set("ETag", c.etags[nextID])
+	if etag != "" && etag == c.etags[nextID] {
+		return &http.Response{
+			StatusCode: http.StatusNotModified,
+			Header:     header,
+			Body:       io.NopCloser(bytes.NewReader(nil)),
+		}, nil
+	}
+
+	body, err := json.Marshal(c.pages[nextID])
+	Expect(err).ToNot(HaveOccurred())
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     header,
+		Body:       io.NopCloser(bytes.NewReader(body)),
+	}, nil
+}
+
+func (c *conditionalAPIClient) LegacyGet(int) (*http.Response, error) {
+	return nil, errors.New("not implemented")
+}
+
+func (c *conditionalAPIClient) set(nextID int, page response, etag string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.pages[nextID] = page
+	c.etags[nextID] = etag
+}
+
+func (c *conditionalAPIClient) received() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]string(nil), c.requests...)
+}
+
+func (c *conditionalAPIClient) clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.requests = nil
+}
+
+type stubElector bool
+
+func (e stubElector) IsLeader() bool {
+	return bool(e)
+}
+
+type stubLeader struct {
+	bindings []binding.Binding
+	err      error
+	called   int
+}
+
+func (l *stubLeader) Get() ([]binding.Binding, error) {
+	l.called++
+	return l.bindings, l.err
+}
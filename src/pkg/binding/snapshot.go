@@ -0,0 +1,50 @@
+package binding
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// snapshot holds the bindings of the last successful poll so that they can
+// be served after a restart while the binding provider is unavailable.
+type snapshot struct {
+	Timestamp time.Time `json:"timestamp"`
+	Bindings  []Binding `json:"bindings"`
+}
+
+func readSnapshot(path string) (snapshot, error) {
+	var s snapshot
+	f, err := os.Open(path)
+	if err != nil {
+		return s, err
+	}
+	defer f.Close()
+
+	err = json.NewDecoder(f).Decode(&s)
+	return s, err
+}
+
+// writeSnapshot writes the snapshot to a temporary file and renames it so
+// that a crash never leaves a partially written snapshot behind. The file
+// is only readable by the owner as it contains private keys.
+func writeSnapshot(path string, s snapshot) error {
+	f, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+
+	err = json.NewEncoder(f).Encode(s)
+	if err != nil {
+		f.Close()
+		return err
+	}
+	err = f.Close()
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(f.Name(), path)
+}
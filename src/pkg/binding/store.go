@@ -1,27 +1,83 @@
 package binding
 
 import (
-	"io"
-	"os"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"reflect"
+	"sort"
 	"sync"
 
 	metrics "code.cloudfoundry.org/go-metric-registry"
-	"gopkg.in/yaml.v2"
 )
 
+// maxStoreHistory is the number of versions for which the Store can
+// compute a delta.
+const maxStoreHistory = 100
+
+// Store holds the bindings served by the binding cache. Every Set that
+// changes the bindings changes the version of the Store so that clients can
+// fetch only the changes since a version they already have. The version is
+// derived from the bindings, so binding cache instances and restarts holding
+// the same bindings report the same version and a client can switch between
+// them without fetching all bindings again.
 type Store struct {
 	mu           sync.Mutex
 	bindings     []Binding
 	bindingCount metrics.Gauge
+
+	version     string
+	base        string
+	byURL       map[string]Binding
+	history     []storeChange
+	subscribers map[chan struct{}]struct{}
+}
+
+// storeChange holds the URLs of the bindings changed by a Set and the
+// version it resulted in.
+type storeChange struct {
+	version string
+	urls    []string
+}
+
+// BindingDelta holds the changes to the bindings since a version. Updated
+// holds the bindings that were added or changed and Deleted holds the URLs
+// of the bindings that were removed.
+type BindingDelta struct {
+	Version string    `json:"version"`
+	Updated []Binding `json:"updated"`
+	Deleted []string  `json:"deleted"`
 }
 
 func NewStore(m Metrics) *Store {
+	v := bindingsVersion(nil)
 	return &Store{
 		bindings: make([]Binding, 0),
 		bindingCount: m.NewGauge(
 			"cached_bindings",
 			"Current number of bindings stored in the binding cache.",
 		),
+		version:     v,
+		base:        v,
+		byURL:       make(map[string]Binding),
+		subscribers: make(map[chan struct{}]struct{}),
+	}
+}
+
+// Subscribe returns a channel that receives a value whenever the version of
+// the Store changes. Notifications are coalesced if the subscriber is not
+// ready to receive them. The returned func cancels the subscription.
+func (s *Store) Subscribe() (<-chan struct{}, func()) {
+	c := make(chan struct{}, 1)
+
+	s.mu.Lock()
+	s.subscribers[c] = struct{}{}
+	s.mu.Unlock()
+
+	return c, func() {
+		s.mu.Lock()
+		delete(s.subscribers, c)
+		s.mu.Unlock()
 	}
 }
 
@@ -31,16 +87,133 @@ func (s *Store) Get() []Binding {
 	return s.bindings
 }
 
+// GetVersioned returns the bindings together with their version.
+func (s *Store) GetVersioned() ([]Binding, string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.bindings, s.version
+}
+
+// Since returns the changes to the bindings since the given version. It
+// returns false if the version is unknown or too old to compute a delta, in
+// which case the client has to fetch all bindings. Only versions the Store
+// held itself are known, so a restarted binding cache only knows its current
+// version.
+func (s *Store) Since(version string) (BindingDelta, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delta := BindingDelta{
+		Version: s.version,
+		Updated: []Binding{},
+		Deleted: []string{},
+	}
+	if version == s.version {
+		return delta, true
+	}
+
+	// The bindings may have held the version more than once. The latest
+	// occurrence has the fewest changes since.
+	start := -1
+	for i := len(s.history) - 1; i >= 0; i-- {
+		if s.history[i].version == version {
+			start = i + 1
+			break
+		}
+	}
+	if start < 0 && version == s.base {
+		start = 0
+	}
+	if start < 0 {
+		return BindingDelta{}, false
+	}
+
+	changed := make(map[string]bool)
+	for _, c := range s.history[start:] {
+		for _, u := range c.urls {
+			changed[u] = true
+		}
+	}
+
+	for u := range changed {
+		b, ok := s.byURL[u]
+		if !ok {
+			delta.Deleted = append(delta.Deleted, u)
+			continue
+		}
+		delta.Updated = append(delta.Updated, b)
+	}
+	sort.Strings(delta.Deleted)
+	sort.Slice(delta.Updated, func(i, j int) bool {
+		return delta.Updated[i].Url < delta.Updated[j].Url
+	})
+
+	return delta, true
+}
+
 func (s *Store) Set(bindings []Binding, bindingCount int) {
 	if bindings == nil {
 		bindings = []Binding{}
 		bindingCount = 0
 	}
 
+	byURL := make(map[string]Binding, len(bindings))
+	for _, b := range bindings {
+		byURL[b.Url] = b
+	}
+
 	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var changed []string
+	for u, b := range byURL {
+		old, ok := s.byURL[u]
+		if !ok || !reflect.DeepEqual(old, b) {
+			changed = append(changed, u)
+		}
+	}
+	for u := range s.byURL {
+		if _, ok := byURL[u]; !ok {
+			changed = append(changed, u)
+		}
+	}
+
+	if len(changed) > 0 {
+		s.version = bindingsVersion(byURL)
+		s.history = append(s.history, storeChange{version: s.version, urls: changed})
+		if len(s.history) > maxStoreHistory {
+			drop := len(s.history) - maxStoreHistory
+			s.base = s.history[drop-1].version
+			s.history = s.history[drop:]
+		}
+
+		for c := range s.subscribers {
+			select {
+			case c <- struct{}{}:
+			default:
+			}
+		}
+	}
+
 	s.bindings = bindings
+	s.byURL = byURL
 	s.bindingCount.Set(float64(bindingCount))
-	s.mu.Unlock()
+}
+
+// bindingsVersion returns the version of the given bindings. It does not
+// depend on the order in which the bindings were set.
+func bindingsVersion(byURL map[string]Binding) string {
+	bindings := make([]Binding, 0, len(byURL))
+	for _, b := range byURL {
+		bindings = append(bindings, b)
+	}
+	sort.Slice(bindings, func(i, j int) bool {
+		return bindings[i].Url < bindings[j].Url
+	})
+
+	h := sha256.New()
+	_ = json.NewEncoder(h).Encode(bindings)
+	return hex.EncodeToString(h.Sum(nil)[:16])
 }
 
 type LegacyStore struct {
@@ -68,56 +241,3 @@ func (s *LegacyStore) Set(bindings []LegacyBinding) {
 	s.legacyBindings = bindings
 	s.mu.Unlock()
 }
-
-type AggregateStore struct {
-	Drains []Binding
-}
-
-func NewAggregateStore(drainFileName string) *AggregateStore {
-	drainFile, err := os.Open(drainFileName)
-	if err != nil {
-		panic(err)
-	}
-	contents, err := io.ReadAll(drainFile)
-	if err != nil {
-		panic(err)
-	}
-
-	var bindings []Binding
-	var aggBindings []AggBinding
-	err = yaml.Unmarshal(contents, &aggBindings)
-	if err != nil {
-		panic(err)
-	}
-	for _, binding := range aggBindings {
-		bindings = append(bindings, Binding{
-			Url: binding.Url,
-			Credentials: []Credentials{
-				{
-					Cert: binding.Cert,
-					Key:  binding.Key,
-					CA:   binding.CA,
-				},
-			},
-		})
-	}
-	return &AggregateStore{Drains: bindings}
-}
-
-func (store *AggregateStore) Get() []Binding {
-	return store.Drains
-}
-
-func (store *AggregateStore) LegacyGet() []LegacyBinding {
-	var drains []string
-	for _, binding := range store.Drains {
-		drains = append(drains, binding.Url)
-	}
-	return []LegacyBinding{
-		{
-			AppID:       "",
-			Drains:      drains,
-			V2Available: true,
-		},
-	}
-}
@@ -1,7 +1,9 @@
 package binding_test
 
 import (
+	"fmt"
 	"os"
+	"time"
 
 	metricsHelpers "code.cloudfoundry.org/go-metric-registry/testhelpers"
 	"code.cloudfoundry.org/loggregator-agent-release/src/pkg/binding"
@@ -27,6 +29,136 @@ var _ = Describe("Store", func() {
 		Expect(store.Get()).To(Equal(bindings))
 	})
 
+	Context("versioning", func() {
+		var store *binding.Store
+
+		BeforeEach(func() {
+			store = binding.NewStore(metricsHelpers.NewMetricsRegistry())
+			store.Set([]binding.Binding{{Url: "drain-1"}, {Url: "drain-2"}}, 2)
+		})
+
+		It("changes the version when the bindings change", func() {
+			_, v1 := store.GetVersioned()
+
+			store.Set([]binding.Binding{{Url: "drain-1"}, {Url: "drain-2"}}, 2)
+			_, v2 := store.GetVersioned()
+			Expect(v2).To(Equal(v1))
+
+			store.Set([]binding.Binding{{Url: "drain-1"}}, 1)
+			bindings, v3 := store.GetVersioned()
+			Expect(v3).ToNot(Equal(v1))
+			Expect(bindings).To(Equal([]binding.Binding{{Url: "drain-1"}}))
+		})
+
+		It("returns the changes since a version", func() {
+			_, v1 := store.GetVersioned()
+
+			store.Set([]binding.Binding{
+				{Url: "drain-1", Credentials: []binding.Credentials{{Cert: "cert"}}},
+				{Url: "drain-2"},
+			}, 2)
+			store.Set([]binding.Binding{
+				{Url: "drain-1", Credentials: []binding.Credentials{{Cert: "cert"}}},
+				{Url: "drain-3"},
+			}, 2)
+			_, v3 := store.GetVersioned()
+
+			delta, ok := store.Since(v1)
+			Expect(ok).To(BeTrue())
+			Expect(delta).To(Equal(binding.BindingDelta{
+				Version: v3,
+				Updated: []binding.Binding{
+					{Url: "drain-1", Credentials: []binding.Credentials{{Cert: "cert"}}},
+					{Url: "drain-3"},
+				},
+				Deleted: []string{"drain-2"},
+			}))
+
+			delta, ok = store.Since(v3)
+			Expect(ok).To(BeTrue())
+			Expect(delta.Updated).To(BeEmpty())
+			Expect(delta.Deleted).To(BeEmpty())
+		})
+
+		It("returns the same version for the same bindings in any store", func() {
+			other := binding.NewStore(metricsHelpers.NewMetricsRegistry())
+			other.Set([]binding.Binding{{Url: "drain-2"}, {Url: "drain-1"}}, 2)
+
+			_, v := store.GetVersioned()
+			_, otherV := other.GetVersioned()
+			Expect(otherV).To(Equal(v))
+
+			delta, ok := other.Since(v)
+			Expect(ok).To(BeTrue())
+			Expect(delta.Updated).To(BeEmpty())
+			Expect(delta.Deleted).To(BeEmpty())
+		})
+
+		It("returns the changes since a version held by another store", func() {
+			other := binding.NewStore(metricsHelpers.NewMetricsRegistry())
+			_, v := store.GetVersioned()
+
+			other.Set([]binding.Binding{{Url: "drain-1"}, {Url: "drain-2"}}, 2)
+			other.Set([]binding.Binding{{Url: "drain-1"}, {Url: "drain-3"}}, 2)
+
+			delta, ok := other.Since(v)
+			Expect(ok).To(BeTrue())
+			Expect(delta.Updated).To(Equal([]binding.Binding{{Url: "drain-3"}}))
+			Expect(delta.Deleted).To(Equal([]string{"drain-2"}))
+		})
+
+		It("returns the changes since a version the bindings returned to", func() {
+			_, v := store.GetVersioned()
+			store.Set([]binding.Binding{{Url: "drain-1"}}, 1)
+			store.Set([]binding.Binding{{Url: "drain-1"}, {Url: "drain-2"}}, 2)
+			store.Set([]binding.Binding{{Url: "drain-1"}, {Url: "drain-2"}, {Url: "drain-3"}}, 3)
+
+			delta, ok := store.Since(v)
+			Expect(ok).To(BeTrue())
+			Expect(delta.Updated).To(Equal([]binding.Binding{{Url: "drain-3"}}))
+			Expect(delta.Deleted).To(BeEmpty())
+		})
+
+		It("does not return changes for unknown versions", func() {
+			_, v := store.GetVersioned()
+
+			_, ok := store.Since("invalid")
+			Expect(ok).To(BeFalse())
+
+			_, ok = store.Since("1-1")
+			Expect(ok).To(BeFalse())
+
+			_, ok = store.Since(v + "0")
+			Expect(ok).To(BeFalse())
+		})
+
+		It("does not return changes for versions that are too old", func() {
+			_, v := store.GetVersioned()
+			for i := 0; i < 101; i++ {
+				store.Set([]binding.Binding{{Url: fmt.Sprintf("drain-%d", i)}}, 1)
+			}
+
+			_, ok := store.Since(v)
+			Expect(ok).To(BeFalse())
+		})
+
+		It("notifies subscribers when the bindings change", func() {
+			notify, unsubscribe := store.Subscribe()
+
+			store.Set([]binding.Binding{{Url: "drain-1"}, {Url: "drain-2"}}, 2)
+			Consistently(notify, 50*time.Millisecond).ShouldNot(Receive())
+
+			store.Set([]binding.Binding{{Url: "drain-1"}}, 1)
+			store.Set([]binding.Binding{{Url: "drain-3"}}, 1)
+			Eventually(notify).Should(Receive())
+			Consistently(notify, 50*time.Millisecond).ShouldNot(Receive())
+
+			unsubscribe()
+			store.Set([]binding.Binding{{Url: "drain-4"}}, 1)
+			Consistently(notify, 50*time.Millisecond).ShouldNot(Receive())
+		})
+	})
+
 	It("should store and retrieve legacy bindings", func() {
 		legacyStore := binding.NewLegacyStore()
 		bindings := []binding.LegacyBinding{
@@ -2,9 +2,16 @@ package cache
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"log"
 	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
 
 	"code.cloudfoundry.org/loggregator-agent-release/src/pkg/binding"
 )
@@ -13,50 +20,379 @@ type httpGetter interface {
 	Get(string) (*http.Response, error)
 }
 
+type httpDoer interface {
+	Do(*http.Request) (*http.Response, error)
+}
+
+var errVersionGone = errors.New("binding version is unknown to the binding cache")
+
+// maxFilterSourceIDs is the number of source IDs above which the bindings
+// are no longer filtered so that the source_ids query parameter stays short
+// enough for proxies in front of the binding cache.
+const maxFilterSourceIDs = 100
+
+// CacheClient fetches bindings from the binding cache. Once it has fetched
+// all bindings it only fetches the changes since the version it has. While
+// it is subscribed to the binding stream of the cache it returns the
+// streamed bindings without fetching them. If several binding caches are
+// given it fails over to the next one when a request fails.
 type CacheClient struct {
-	cacheAddr string
+	addrs     []string
+	current   atomic.Int64
 	h         httpGetter
+	sourceIDs func() []string
+	log       *log.Logger
+
+	mu            sync.Mutex
+	version       string
+	bindings      []binding.Binding
+	filterIDs     map[string]bool
+	filter        string
+	unfiltered    bool
+	streaming     bool
+	stopStream    func()
+	filterChanged bool
 }
 
-func NewClient(cacheAddr string, h httpGetter) *CacheClient {
-	return &CacheClient{
-		cacheAddr: cacheAddr,
-		h:         h,
+// ClientOption allows a CacheClient to be customized.
+type ClientOption func(*CacheClient)
+
+// WithSourceIDs returns a ClientOption that only fetches the bindings of the
+// source IDs returned by f. The bindings of new source IDs are fetched and
+// merged into the held bindings. Source IDs are never removed from the
+// filter, so the bindings of idle sources are kept up to date. Once more
+// than maxFilterSourceIDs source IDs have been seen all bindings are fetched.
+func WithSourceIDs(f func() []string) ClientOption {
+	return func(c *CacheClient) {
+		c.sourceIDs = f
 	}
 }
 
-func (c *CacheClient) Get() ([]binding.Binding, error) {
-	return c.get("v2/bindings")
+// WithClientLogger returns a ClientOption that logs to the given logger.
+// Defaults to the standard logger.
+func WithClientLogger(l *log.Logger) ClientOption {
+	return func(c *CacheClient) {
+		c.log = l
+	}
 }
 
-func (c *CacheClient) LegacyGet() ([]binding.LegacyBinding, error) {
-	return c.legacyGet("bindings")
+// WithFailoverAddrs returns a ClientOption that fails over to the given
+// binding caches in order if a request fails.
+func WithFailoverAddrs(addrs ...string) ClientOption {
+	return func(c *CacheClient) {
+		c.addrs = append(c.addrs, addrs...)
+	}
 }
 
-func (c *CacheClient) GetAggregate() ([]binding.Binding, error) {
-	return c.get("v2/aggregate")
+func NewClient(cacheAddr string, h httpGetter, opts ...ClientOption) *CacheClient {
+	c := &CacheClient{
+		addrs: []string{cacheAddr},
+		h:     h,
+		log:   log.Default(),
+	}
+	for _, o := range opts {
+		o(c)
+	}
+	return c
 }
 
-func (c *CacheClient) GetLegacyAggregate() ([]binding.LegacyBinding, error) {
-	return c.legacyGet("aggregate")
+func (c *CacheClient) Get() ([]binding.Binding, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	changed, err := c.updateFilter()
+	if err != nil {
+		return nil, err
+	}
+
+	if changed && c.streaming {
+		c.streaming = false
+		c.filterChanged = true
+		c.stopStream()
+	}
+
+	if c.streaming {
+		return c.currentBindings(), nil
+	}
+
+	err = c.failover(c.update)
+	if err != nil {
+		return nil, err
+	}
+
+	return c.currentBindings(), nil
 }
 
-func (c *CacheClient) get(path string) ([]binding.Binding, error) {
+// update fetches the changes since the held version or all bindings if the
+// version is unknown to the binding cache.
+func (c *CacheClient) update() error {
+	if c.version != "" {
+		err := c.getDelta()
+		if err == nil {
+			return nil
+		}
+		if !errors.Is(err, errVersionGone) {
+			return err
+		}
+		c.version = ""
+		c.bindings = nil
+	}
+
 	var bindings []binding.Binding
-	resp, err := c.h.Get(fmt.Sprintf("%s/"+path, c.cacheAddr))
+	header, err := c.fetch(c.bindingsPath("v2/bindings", ""), &bindings)
 	if err != nil {
-		return nil, err
+		return err
+	}
+	c.version = parseETag(header.Get("ETag"))
+	c.bindings = bindings
+
+	return nil
+}
+
+// failover calls f until it succeeds, trying each binding cache once
+// starting with the current one.
+func (c *CacheClient) failover(f func() error) error {
+	var err error
+	for range c.addrs {
+		err = f()
+		if err == nil {
+			return nil
+		}
+		c.next()
+	}
+	return err
+}
+
+func (c *CacheClient) addr() string {
+	return c.addrs[int(c.current.Load())%len(c.addrs)]
+}
+
+// next switches to the next binding cache.
+func (c *CacheClient) next() {
+	if len(c.addrs) > 1 {
+		c.current.Add(1)
+	}
+}
+
+func (c *CacheClient) getDelta() error {
+	resp, err := c.h.Get(fmt.Sprintf("%s/%s", c.addr(), c.bindingsPath("v2/bindings", c.version)))
+	if err != nil {
+		return err
 	}
 	defer func() {
 		_, _ = io.Copy(io.Discard, resp.Body)
 		resp.Body.Close()
 	}()
 
+	if resp.StatusCode == http.StatusGone {
+		return errVersionGone
+	}
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("unexpected http response from binding cache: %d", resp.StatusCode)
+		return fmt.Errorf("unexpected http response from binding cache: %d", resp.StatusCode)
+	}
+
+	// A binding cache that does not support deltas ignores the since
+	// parameter and returns all bindings without a version.
+	version := parseETag(resp.Header.Get("ETag"))
+	if version == "" {
+		return errVersionGone
+	}
+
+	var delta binding.BindingDelta
+	err = json.NewDecoder(resp.Body).Decode(&delta)
+	if err != nil {
+		return err
+	}
+
+	c.bindings = applyDelta(c.bindings, delta)
+	c.version = delta.Version
+
+	return nil
+}
+
+// updateFilter adds new source IDs to the filter and merges their bindings
+// into the held bindings so that the held version stays valid. It returns
+// true if the filter changed.
+func (c *CacheClient) updateFilter() (bool, error) {
+	if c.sourceIDs == nil || c.unfiltered {
+		return false, nil
+	}
+
+	var added []string
+	for _, id := range c.sourceIDs() {
+		if !c.filterIDs[id] {
+			added = append(added, id)
+		}
 	}
+	if len(added) == 0 && c.filterIDs != nil {
+		return false, nil
+	}
+
+	if len(c.filterIDs)+len(added) > maxFilterSourceIDs {
+		c.log.Printf("fetching all bindings because more than %d source IDs are active", maxFilterSourceIDs)
+		c.unfiltered = true
+		c.filterIDs = nil
+		c.filter = ""
+		c.version = ""
+		c.bindings = nil
+		return true, nil
+	}
+
+	sort.Strings(added)
+	if c.version != "" && len(added) > 0 {
+		q := url.Values{}
+		q.Set("source_ids", strings.Join(added, ","))
+
+		var bindings []binding.Binding
+		err := c.failover(func() error {
+			_, err := c.fetch("v2/bindings?"+q.Encode(), &bindings)
+			return err
+		})
+		if err != nil {
+			return false, err
+		}
+		c.bindings = mergeBindings(c.bindings, bindings)
+	}
+
+	if c.filterIDs == nil {
+		c.filterIDs = make(map[string]bool, len(added))
+	}
+	ids := make([]string, 0, len(c.filterIDs)+len(added))
+	for id := range c.filterIDs {
+		ids = append(ids, id)
+	}
+	for _, id := range added {
+		c.filterIDs[id] = true
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	c.filter = strings.Join(ids, ",")
+
+	return true, nil
+}
+
+// bindingsPath returns the path with the since and source_ids query
+// parameters if they apply.
+func (c *CacheClient) bindingsPath(path, since string) string {
+	q := url.Values{}
+	if since != "" {
+		q.Set("since", since)
+	}
+	if c.sourceIDs != nil && !c.unfiltered {
+		q.Set("source_ids", c.filter)
+	}
+	if len(q) == 0 {
+		return path
+	}
+	return path + "?" + q.Encode()
+}
+
+func (c *CacheClient) currentBindings() []binding.Binding {
+	bindings := make([]binding.Binding, len(c.bindings))
+	copy(bindings, c.bindings)
+	return bindings
+}
+
+// applyDelta applies the delta to the bindings. Changed bindings keep their
+// position and new bindings are appended.
+func applyDelta(bindings []binding.Binding, delta binding.BindingDelta) []binding.Binding {
+	updated := make(map[string]binding.Binding, len(delta.Updated))
+	for _, b := range delta.Updated {
+		updated[b.Url] = b
+	}
+	deleted := make(map[string]bool, len(delta.Deleted))
+	for _, u := range delta.Deleted {
+		deleted[u] = true
+	}
+
+	result := make([]binding.Binding, 0, len(bindings)+len(delta.Updated))
+	for _, b := range bindings {
+		if deleted[b.Url] {
+			continue
+		}
+		if u, ok := updated[b.Url]; ok {
+			result = append(result, u)
+			delete(updated, b.Url)
+			continue
+		}
+		result = append(result, b)
+	}
+	for _, b := range delta.Updated {
+		if _, ok := updated[b.Url]; ok {
+			result = append(result, b)
+		}
+	}
+
+	return result
+}
+
+// mergeBindings adds the apps of the given bindings to the bindings with the
+// same URL and credentials. Bindings with new URLs are appended.
+func mergeBindings(bindings, added []binding.Binding) []binding.Binding {
+	result := make([]binding.Binding, len(bindings), len(bindings)+len(added))
+	copy(result, bindings)
+
+	index := make(map[string]int, len(result))
+	for i, b := range result {
+		index[b.Url] = i
+	}
+
+	for _, a := range added {
+		i, ok := index[a.Url]
+		if !ok {
+			index[a.Url] = len(result)
+			result = append(result, a)
+			continue
+		}
 
-	err = json.NewDecoder(resp.Body).Decode(&bindings)
+		creds := append([]binding.Credentials{}, result[i].Credentials...)
+		for _, ac := range a.Credentials {
+			merged := false
+			for j, c := range creds {
+				if c.Cert == ac.Cert && c.Key == ac.Key && c.CA == ac.CA {
+					c.Apps = append(append([]binding.App{}, c.Apps...), ac.Apps...)
+					creds[j] = c
+					merged = true
+					break
+				}
+			}
+			if !merged {
+				creds = append(creds, ac)
+			}
+		}
+		result[i].Credentials = creds
+	}
+
+	return result
+}
+
+// parseETag returns the version of the ETag without the hash of the source
+// IDs of filtered responses.
+func parseETag(etag string) string {
+	if len(etag) < 2 || etag[0] != '"' || etag[len(etag)-1] != '"' {
+		return ""
+	}
+	return etagVersion(etag[1 : len(etag)-1])
+}
+
+func (c *CacheClient) LegacyGet() ([]binding.LegacyBinding, error) {
+	return c.legacyGet("bindings")
+}
+
+func (c *CacheClient) GetAggregate() ([]binding.Binding, error) {
+	return c.get("v2/aggregate")
+}
+
+func (c *CacheClient) GetLegacyAggregate() ([]binding.LegacyBinding, error) {
+	return c.legacyGet("aggregate")
+}
+
+func (c *CacheClient) get(path string) ([]binding.Binding, error) {
+	var bindings []binding.Binding
+	err := c.failover(func() error {
+		_, err := c.fetch(path, &bindings)
+		return err
+	})
 	if err != nil {
 		return nil, err
 	}
@@ -64,9 +400,8 @@ func (c *CacheClient) get(path string) ([]binding.Binding, error) {
 	return bindings, nil
 }
 
-func (c *CacheClient) legacyGet(path string) ([]binding.LegacyBinding, error) {
-	var bindings []binding.LegacyBinding
-	resp, err := c.h.Get(fmt.Sprintf("%s/"+path, c.cacheAddr))
+func (c *CacheClient) fetch(path string, v any) (http.Header, error) {
+	resp, err := c.h.Get(fmt.Sprintf("%s/%s", c.addr(), path))
 	if err != nil {
 		return nil, err
 	}
@@ -79,7 +414,20 @@ func (c *CacheClient) legacyGet(path string) ([]binding.LegacyBinding, error) {
 		return nil, fmt.Errorf("unexpected http response from binding cache: %d", resp.StatusCode)
 	}
 
-	err = json.NewDecoder(resp.Body).Decode(&bindings)
+	err = json.NewDecoder(resp.Body).Decode(v)
+	if err != nil {
+		return nil, err
+	}
+
+	return resp.Header, nil
+}
+
+func (c *CacheClient) legacyGet(path string) ([]binding.LegacyBinding, error) {
+	var bindings []binding.LegacyBinding
+	err := c.failover(func() error {
+		_, err := c.fetch(path, &bindings)
+		return err
+	})
 	if err != nil {
 		return nil, err
 	}
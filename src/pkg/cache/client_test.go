@@ -4,7 +4,9 @@ import (
 	"bytes"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"io"
+	"log"
 	"net/http"
 	"strings"
 
@@ -51,6 +53,254 @@ var _ = Describe("Client", func() {
 		Expect(spyHTTPClient.requestURL).To(Equal("https://cache.address.com/v2/bindings"))
 	})
 
+	Context("when the binding cache versions the bindings", func() {
+		response := func(status int, etag string, body string) *http.Response {
+			header := http.Header{}
+			if etag != "" {
+				header.Set("ETag", etag)
+			}
+			return &http.Response{
+				StatusCode: status,
+				Header:     header,
+				Body:       io.NopCloser(strings.NewReader(body)),
+			}
+		}
+
+		BeforeEach(func() {
+			spyHTTPClient.responses = []*http.Response{
+				response(http.StatusOK, `"1-1"`, `[{"url":"drain-1"},{"url":"drain-2"},{"url":"drain-3"}]`),
+			}
+			Expect(client.Get()).To(HaveLen(3))
+		})
+
+		It("only fetches the changes since the last version", func() {
+			spyHTTPClient.responses = []*http.Response{
+				response(http.StatusOK, `"1-2"`, `{
+					"version": "1-2",
+					"updated": [{"url":"drain-2","credentials":[{"cert":"cert"}]},{"url":"drain-4"}],
+					"deleted": ["drain-1"]
+				}`),
+				response(http.StatusOK, `"1-2"`, `{"version":"1-2","updated":[],"deleted":[]}`),
+			}
+
+			expected := []binding.Binding{
+				{Url: "drain-2", Credentials: []binding.Credentials{{Cert: "cert"}}},
+				{Url: "drain-3"},
+				{Url: "drain-4"},
+			}
+			Expect(client.Get()).To(Equal(expected))
+			Expect(spyHTTPClient.requestURL).To(Equal("https://cache.address.com/v2/bindings?since=1-1"))
+
+			Expect(client.Get()).To(Equal(expected))
+			Expect(spyHTTPClient.requestURL).To(Equal("https://cache.address.com/v2/bindings?since=1-2"))
+		})
+
+		It("fetches all bindings if the version is gone", func() {
+			spyHTTPClient.responses = []*http.Response{
+				response(http.StatusGone, "", ""),
+				response(http.StatusOK, `"2-1"`, `[{"url":"drain-5"}]`),
+			}
+
+			Expect(client.Get()).To(Equal([]binding.Binding{{Url: "drain-5"}}))
+			Expect(spyHTTPClient.requestURLs).To(Equal([]string{
+				"https://cache.address.com/v2/bindings",
+				"https://cache.address.com/v2/bindings?since=1-1",
+				"https://cache.address.com/v2/bindings",
+			}))
+		})
+
+		It("fetches all bindings if the binding cache does not support deltas", func() {
+			spyHTTPClient.responses = []*http.Response{
+				response(http.StatusOK, "", `[{"url":"drain-5"}]`),
+				response(http.StatusOK, "", `[{"url":"drain-5"}]`),
+			}
+
+			Expect(client.Get()).To(Equal([]binding.Binding{{Url: "drain-5"}}))
+			Expect(spyHTTPClient.requestURL).To(Equal("https://cache.address.com/v2/bindings"))
+		})
+
+		It("keeps the version if fetching the changes fails", func() {
+			spyHTTPClient.responses = []*http.Response{
+				response(http.StatusInternalServerError, "", ""),
+				response(http.StatusOK, `"1-1"`, `{"version":"1-1","updated":[],"deleted":[]}`),
+			}
+
+			_, err := client.Get()
+			Expect(err).To(MatchError("unexpected http response from binding cache: 500"))
+
+			Expect(client.Get()).To(HaveLen(3))
+			Expect(spyHTTPClient.requestURL).To(Equal("https://cache.address.com/v2/bindings?since=1-1"))
+		})
+	})
+
+	Context("with source IDs", func() {
+		var sourceIDs []string
+
+		newResponse := func(etag, body string) *http.Response {
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Header:     http.Header{"Etag": []string{etag}},
+				Body:       io.NopCloser(strings.NewReader(body)),
+			}
+		}
+
+		BeforeEach(func() {
+			sourceIDs = []string{"app-2", "app-1"}
+			client = cache.NewClient(addr, spyHTTPClient, cache.WithSourceIDs(func() []string {
+				return append([]string{}, sourceIDs...)
+			}))
+		})
+
+		It("only fetches the bindings of the source IDs", func() {
+			spyHTTPClient.responses = []*http.Response{
+				newResponse(`"1-1"`, `[{"url":"drain-1"}]`),
+				newResponse(`"1-1"`, `{"version":"1-1","updated":[],"deleted":[]}`),
+			}
+
+			Expect(client.Get()).To(HaveLen(1))
+			Expect(client.Get()).To(HaveLen(1))
+
+			Expect(spyHTTPClient.requestURLs).To(Equal([]string{
+				"https://cache.address.com/v2/bindings?source_ids=app-1%2Capp-2",
+				"https://cache.address.com/v2/bindings?since=1-1&source_ids=app-1%2Capp-2",
+			}))
+		})
+
+		It("only fetches the bindings of new source IDs and keeps the version", func() {
+			spyHTTPClient.responses = []*http.Response{
+				newResponse(`"1-1"`, `[{"url":"drain-1","credentials":[{"cert":"cert","key":"key","apps":[{"hostname":"host-1","app_id":"app-1"}]}]}]`),
+				newResponse(`"1-2"`, `[{"url":"drain-1","credentials":[{"cert":"cert","key":"key","apps":[{"hostname":"host-3","app_id":"app-3"}]}]},{"url":"drain-2"}]`),
+				newResponse(`"1-2"`, `{"version":"1-2","updated":[],"deleted":[]}`),
+			}
+
+			Expect(client.Get()).To(HaveLen(1))
+
+			sourceIDs = append(sourceIDs, "app-3")
+			Expect(client.Get()).To(Equal([]binding.Binding{
+				{
+					Url: "drain-1",
+					Credentials: []binding.Credentials{
+						{
+							Cert: "cert",
+							Key:  "key",
+							Apps: []binding.App{
+								{Hostname: "host-1", AppID: "app-1"},
+								{Hostname: "host-3", AppID: "app-3"},
+							},
+						},
+					},
+				},
+				{Url: "drain-2"},
+			}))
+
+			Expect(spyHTTPClient.requestURLs).To(Equal([]string{
+				"https://cache.address.com/v2/bindings?source_ids=app-1%2Capp-2",
+				"https://cache.address.com/v2/bindings?source_ids=app-3",
+				"https://cache.address.com/v2/bindings?since=1-1&source_ids=app-1%2Capp-2%2Capp-3",
+			}))
+		})
+
+		It("keeps the bindings of source IDs that are no longer active", func() {
+			spyHTTPClient.responses = []*http.Response{
+				newResponse(`"1-1"`, `[{"url":"drain-1"},{"url":"drain-2"}]`),
+				newResponse(`"1-1"`, `{"version":"1-1","updated":[],"deleted":[]}`),
+			}
+
+			Expect(client.Get()).To(HaveLen(2))
+
+			sourceIDs = []string{"app-1"}
+			Expect(client.Get()).To(HaveLen(2))
+
+			Expect(spyHTTPClient.requestURLs).To(Equal([]string{
+				"https://cache.address.com/v2/bindings?source_ids=app-1%2Capp-2",
+				"https://cache.address.com/v2/bindings?since=1-1&source_ids=app-1%2Capp-2",
+			}))
+		})
+
+		It("fetches all bindings once there are too many source IDs", func() {
+			spyHTTPClient.responses = []*http.Response{
+				newResponse(`"1-1"`, `[{"url":"drain-1"}]`),
+				newResponse(`"1-1"`, `[{"url":"drain-1"},{"url":"drain-2"}]`),
+			}
+
+			Expect(client.Get()).To(HaveLen(1))
+
+			for i := 0; i < 100; i++ {
+				sourceIDs = append(sourceIDs, fmt.Sprintf("app-%d", i+3))
+			}
+			Expect(client.Get()).To(HaveLen(2))
+
+			Expect(spyHTTPClient.requestURLs).To(Equal([]string{
+				"https://cache.address.com/v2/bindings?source_ids=app-1%2Capp-2",
+				"https://cache.address.com/v2/bindings",
+			}))
+		})
+
+		It("logs when it fetches all bindings because of too many source IDs", func() {
+			var logs bytes.Buffer
+			client = cache.NewClient(addr, spyHTTPClient,
+				cache.WithSourceIDs(func() []string {
+					return append([]string{}, sourceIDs...)
+				}),
+				cache.WithClientLogger(log.New(&logs, "", 0)),
+			)
+			spyHTTPClient.responses = []*http.Response{
+				newResponse(`"1-1"`, `[{"url":"drain-1"}]`),
+				newResponse(`"1-1"`, `[{"url":"drain-1"},{"url":"drain-2"}]`),
+			}
+			Expect(client.Get()).To(HaveLen(1))
+			Expect(logs.String()).To(BeEmpty())
+
+			for i := 0; i < 100; i++ {
+				sourceIDs = append(sourceIDs, fmt.Sprintf("app-%d", i+3))
+			}
+			Expect(client.Get()).To(HaveLen(2))
+
+			Expect(logs.String()).To(Equal("fetching all bindings because more than 100 source IDs are active\n"))
+		})
+
+		It("strips the source ID hash from the version of filtered responses", func() {
+			spyHTTPClient.responses = []*http.Response{
+				newResponse(`"1-1;0123456789abcdef"`, `[{"url":"drain-1"}]`),
+				newResponse(`"1-1;0123456789abcdef"`, `{"version":"1-1","updated":[],"deleted":[]}`),
+			}
+
+			Expect(client.Get()).To(HaveLen(1))
+			Expect(client.Get()).To(HaveLen(1))
+
+			Expect(spyHTTPClient.requestURLs).To(ContainElement(
+				"https://cache.address.com/v2/bindings?since=1-1&source_ids=app-1%2Capp-2",
+			))
+		})
+	})
+
+	It("fails over to the next binding cache", func() {
+		client = cache.NewClient(addr, spyHTTPClient, cache.WithFailoverAddrs("https://other.cache.com"))
+		spyHTTPClient.responses = []*http.Response{
+			{StatusCode: http.StatusInternalServerError, Body: io.NopCloser(strings.NewReader(""))},
+			{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(`[{"url":"drain-1"}]`))},
+			{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(`[{"url":"drain-2"}]`))},
+		}
+
+		Expect(client.Get()).To(Equal([]binding.Binding{{Url: "drain-1"}}))
+		Expect(client.GetAggregate()).To(Equal([]binding.Binding{{Url: "drain-2"}}))
+
+		Expect(spyHTTPClient.requestURLs).To(Equal([]string{
+			"https://cache.address.com/v2/bindings",
+			"https://other.cache.com/v2/bindings",
+			"https://other.cache.com/v2/aggregate",
+		}))
+	})
+
+	It("returns the error if all binding caches fail", func() {
+		client = cache.NewClient(addr, spyHTTPClient, cache.WithFailoverAddrs("https://other.cache.com"))
+		spyHTTPClient.err = errors.New("http error")
+
+		_, err := client.Get()
+		Expect(err).To(MatchError("http error"))
+		Expect(spyHTTPClient.requestURLs).To(HaveLen(2))
+	})
+
 	It("returns legacy bindings from the cache", func() {
 		bindings := []binding.LegacyBinding{
 			{
@@ -173,9 +423,11 @@ var _ = Describe("Client", func() {
 })
 
 type spyHTTPClient struct {
-	response   *http.Response
-	requestURL string
-	err        error
+	response    *http.Response
+	responses   []*http.Response
+	requestURL  string
+	requestURLs []string
+	err         error
 }
 
 func newSpyHTTPClient() *spyHTTPClient {
@@ -184,6 +436,12 @@ func newSpyHTTPClient() *spyHTTPClient {
 
 func (s *spyHTTPClient) Get(url string) (*http.Response, error) {
 	s.requestURL = url
+	s.requestURLs = append(s.requestURLs, url)
+	if len(s.responses) > 0 {
+		resp := s.responses[0]
+		s.responses = s.responses[1:]
+		return resp, s.err
+	}
 	return s.response, s.err
 }
 
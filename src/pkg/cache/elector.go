@@ -0,0 +1,262 @@
+package cache
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	metrics "code.cloudfoundry.org/go-metric-registry"
+	"code.cloudfoundry.org/loggregator-agent-release/src/pkg/binding"
+)
+
+const (
+	defaultLeaseDuration      = 30 * time.Second
+	defaultLeaseCheckInterval = 5 * time.Second
+)
+
+type gaugeMetrics interface {
+	NewGauge(name, helpText string, o ...metrics.MetricOption) metrics.Gauge
+}
+
+// PeerElector elects the leader of a group of binding caches. The binding
+// caches are ordered by their index. A binding cache defers to the lease
+// holder reported by the binding caches with a lower index than its own if
+// it is another node and otherwise claims the lease. A binding cache that
+// claims the lease only becomes the leader once no binding cache with a
+// higher index reports that it is the leader, so that a restarted binding
+// cache takes over from the current leader instead of running alongside it.
+type PeerElector struct {
+	addrs         []string
+	index         int
+	h             httpGetter
+	leaseDuration time.Duration
+	checkInterval time.Duration
+	leaderMetric  metrics.Gauge
+
+	mu     sync.Mutex
+	leases map[int]lease
+	leader string
+}
+
+// lease is the answer to a lease check. Holder is the address of the
+// binding cache the answering binding cache considers the leader and
+// ExpiresAt is when that lease expires unless it is renewed.
+type lease struct {
+	Leader    bool      `json:"leader"`
+	Holder    string    `json:"holder"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// PeerElectorOption allows a PeerElector to be customized.
+type PeerElectorOption func(*PeerElector)
+
+// WithLeaseDuration sets how long a lease is valid after it was last
+// renewed. Defaults to 30 seconds.
+func WithLeaseDuration(d time.Duration) PeerElectorOption {
+	return func(e *PeerElector) {
+		e.leaseDuration = d
+	}
+}
+
+// WithLeaseCheckInterval sets how often the other binding caches are
+// checked. Defaults to 5 seconds.
+func WithLeaseCheckInterval(d time.Duration) PeerElectorOption {
+	return func(e *PeerElector) {
+		e.checkInterval = d
+	}
+}
+
+// NewPeerElector returns a PeerElector for the binding cache with the given
+// index in addrs. The other binding caches are checked once before it
+// returns.
+func NewPeerElector(addrs []string, index int, h httpGetter, m gaugeMetrics, opts ...PeerElectorOption) *PeerElector {
+	e := &PeerElector{
+		addrs:         addrs,
+		index:         index,
+		h:             h,
+		leaseDuration: defaultLeaseDuration,
+		checkInterval: defaultLeaseCheckInterval,
+		leaderMetric: m.NewGauge(
+			"binding_cache_leader",
+			"Whether this binding cache is the leader polling the binding provider.",
+		),
+		leases: make(map[int]lease),
+	}
+	for _, o := range opts {
+		o(e)
+	}
+
+	e.check()
+
+	return e
+}
+
+// Run checks the other binding caches every check interval. Run blocks.
+func (e *PeerElector) Run() {
+	t := time.NewTicker(e.checkInterval)
+	defer t.Stop()
+
+	for range t.C {
+		e.check()
+	}
+}
+
+// Leader returns the address of the leader and whether this binding cache
+// is the leader. While this binding cache waits for a binding cache with a
+// higher index to step down, that binding cache is returned as the leader.
+func (e *PeerElector) Leader() (string, bool) {
+	_, leader := e.currentLease()
+	return leader, leader == e.addrs[e.index]
+}
+
+// currentLease returns the lease this binding cache reports to the other
+// binding caches and the address of the leader. The lease is the one of the
+// first binding cache with a lower index that reported an unexpired lease
+// held by another node or a lease held by this binding cache. A lease held
+// by this binding cache only makes it the leader if no binding cache with a
+// higher index reported an unexpired lease it is the leader of.
+func (e *PeerElector) currentLease() (lease, string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	self := e.addrs[e.index]
+	now := time.Now()
+	for i := 0; i < e.index && i < len(e.addrs); i++ {
+		l := e.leases[i]
+		if l.Holder != "" && l.Holder != self && now.Before(l.ExpiresAt) {
+			return lease{Holder: l.Holder, ExpiresAt: l.ExpiresAt}, l.Holder
+		}
+	}
+
+	claim := lease{Holder: self, ExpiresAt: now.Add(e.leaseDuration)}
+	for i := e.index + 1; i < len(e.addrs); i++ {
+		l := e.leases[i]
+		if l.Leader && l.Holder == e.addrs[i] && now.Before(l.ExpiresAt) {
+			return claim, l.Holder
+		}
+	}
+	claim.Leader = true
+	return claim, self
+}
+
+// IsLeader reports whether this binding cache is the leader.
+func (e *PeerElector) IsLeader() bool {
+	_, self := e.Leader()
+	return self
+}
+
+func (e *PeerElector) check() {
+	for i := range e.addrs {
+		if i == e.index {
+			continue
+		}
+
+		l, err := e.checkPeer(e.addrs[i])
+		if err != nil {
+			continue
+		}
+
+		// The expiry is capped at the lease duration so that a peer with
+		// a skewed clock cannot hold the lease for longer.
+		if limit := time.Now().Add(e.leaseDuration); l.ExpiresAt.After(limit) {
+			l.ExpiresAt = limit
+		}
+
+		e.mu.Lock()
+		e.leases[i] = l
+		e.mu.Unlock()
+	}
+
+	leader, self := e.Leader()
+	if self {
+		e.leaderMetric.Set(1)
+	} else {
+		e.leaderMetric.Set(0)
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if leader != e.leader {
+		log.Printf("binding cache leader changed to %s", leader)
+		e.leader = leader
+	}
+}
+
+func (e *PeerElector) checkPeer(addr string) (lease, error) {
+	resp, err := e.h.Get(fmt.Sprintf("%s/v2/lease", addr))
+	if err != nil {
+		return lease{}, err
+	}
+	defer func() {
+		_, _ = io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+	}()
+
+	if resp.StatusCode != http.StatusOK {
+		return lease{}, fmt.Errorf("unexpected http response from binding cache: %d", resp.StatusCode)
+	}
+
+	var l lease
+	err = json.NewDecoder(resp.Body).Decode(&l)
+	if err != nil {
+		return lease{}, err
+	}
+	return l, nil
+}
+
+// LeaseHandler answers the lease checks of other binding caches. It writes
+// whether this binding cache is the leader, the holder of the lease and
+// when the lease expires.
+func LeaseHandler(e *PeerElector) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		l, _ := e.currentLease()
+		err := json.NewEncoder(w).Encode(l)
+		if err != nil {
+			log.Printf("failed to encode response body: %s", err)
+			return
+		}
+	}
+}
+
+// LeaderClient fetches the bindings from the leader of the binding caches.
+type LeaderClient struct {
+	e *PeerElector
+	h httpGetter
+
+	mu     sync.Mutex
+	addr   string
+	client *CacheClient
+}
+
+// NewLeaderClient returns a LeaderClient that follows the leader elected by
+// the given PeerElector.
+func NewLeaderClient(e *PeerElector, h httpGetter) *LeaderClient {
+	return &LeaderClient{
+		e: e,
+		h: h,
+	}
+}
+
+// Get returns the bindings of the leader. It fails if this binding cache is
+// the leader.
+func (c *LeaderClient) Get() ([]binding.Binding, error) {
+	addr, self := c.e.Leader()
+	if self {
+		return nil, errors.New("this binding cache is the leader")
+	}
+
+	c.mu.Lock()
+	if addr != c.addr {
+		c.addr = addr
+		c.client = NewClient(addr, c.h)
+	}
+	client := c.client
+	c.mu.Unlock()
+
+	return client.Get()
+}
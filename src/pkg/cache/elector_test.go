@@ -0,0 +1,269 @@
+package cache_test
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"time"
+
+	metricsHelpers "code.cloudfoundry.org/go-metric-registry/testhelpers"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"code.cloudfoundry.org/loggregator-agent-release/src/pkg/binding"
+	"code.cloudfoundry.org/loggregator-agent-release/src/pkg/cache"
+)
+
+var _ = Describe("PeerElector", func() {
+	var (
+		peers *stubPeers
+		addrs []string
+		sm    *metricsHelpers.SpyMetricsRegistry
+	)
+
+	BeforeEach(func() {
+		peers = newStubPeers()
+		addrs = []string{"https://cache-0", "https://cache-1", "https://cache-2"}
+		sm = metricsHelpers.NewMetricsRegistry()
+	})
+
+	It("is the leader if no binding cache with a lower index answers", func() {
+		peers.setLease("https://cache-2", fmt.Sprintf(
+			`{"leader":false,"holder":"https://cache-1","expires_at":%q}`,
+			time.Now().Add(time.Minute).Format(time.RFC3339Nano),
+		))
+		e := cache.NewPeerElector(addrs, 1, peers, sm)
+
+		leader, self := e.Leader()
+		Expect(leader).To(Equal("https://cache-1"))
+		Expect(self).To(BeTrue())
+		Expect(sm.GetMetricValue("binding_cache_leader", nil)).To(Equal(1.0))
+		Expect(peers.requested()).To(ConsistOf("https://cache-0/v2/lease", "https://cache-2/v2/lease"))
+	})
+
+	It("waits for the leader with a higher index to step down", func() {
+		peers.setAvailable("https://cache-1", true)
+		e := cache.NewPeerElector(
+			addrs,
+			0,
+			peers,
+			sm,
+			cache.WithLeaseCheckInterval(10*time.Millisecond),
+		)
+		go e.Run()
+
+		leader, self := e.Leader()
+		Expect(leader).To(Equal("https://cache-1"))
+		Expect(self).To(BeFalse())
+
+		peers.setLease("https://cache-1", fmt.Sprintf(
+			`{"leader":false,"holder":"https://cache-0","expires_at":%q}`,
+			time.Now().Add(time.Minute).Format(time.RFC3339Nano),
+		))
+
+		Eventually(e.IsLeader).Should(BeTrue())
+	})
+
+	It("claims the lease while it waits for the leader to step down", func() {
+		peers.setAvailable("https://cache-1", true)
+		e := cache.NewPeerElector(addrs, 0, peers, sm)
+
+		rw := httptest.NewRecorder()
+		req, err := http.NewRequest(http.MethodGet, "/v2/lease", nil)
+		Expect(err).ToNot(HaveOccurred())
+		cache.LeaseHandler(e).ServeHTTP(rw, req)
+
+		var l struct {
+			Leader bool   `json:"leader"`
+			Holder string `json:"holder"`
+		}
+		Expect(json.Unmarshal(rw.Body.Bytes(), &l)).To(Succeed())
+		Expect(l.Leader).To(BeFalse())
+		Expect(l.Holder).To(Equal("https://cache-0"))
+	})
+
+	It("does not wait for a binding cache that does not answer", func() {
+		e := cache.NewPeerElector(addrs, 0, peers, sm)
+
+		Expect(e.IsLeader()).To(BeTrue())
+		Expect(peers.requested()).To(ConsistOf("https://cache-1/v2/lease", "https://cache-2/v2/lease"))
+	})
+
+	It("follows the binding cache with the lowest index that answers", func() {
+		peers.setAvailable("https://cache-1", true)
+		e := cache.NewPeerElector(addrs, 2, peers, sm)
+
+		leader, self := e.Leader()
+		Expect(leader).To(Equal("https://cache-1"))
+		Expect(self).To(BeFalse())
+		Expect(sm.GetMetricValue("binding_cache_leader", nil)).To(Equal(0.0))
+	})
+
+	It("does not follow a binding cache that does not hold the lease", func() {
+		peers.setLease("https://cache-0", `{"leader":false,"holder":"https://cache-1"}`)
+		e := cache.NewPeerElector(addrs, 1, peers, sm)
+
+		Expect(e.IsLeader()).To(BeTrue())
+	})
+
+	It("does not follow an expired lease", func() {
+		peers.setLease("https://cache-0", fmt.Sprintf(
+			`{"leader":true,"holder":"https://cache-0","expires_at":%q}`,
+			time.Now().Add(-time.Second).Format(time.RFC3339Nano),
+		))
+		e := cache.NewPeerElector(addrs, 1, peers, sm)
+
+		Expect(e.IsLeader()).To(BeTrue())
+	})
+
+	It("does not follow a binding cache that answers without a lease", func() {
+		peers.setLease("https://cache-0", `{}`)
+		e := cache.NewPeerElector(addrs, 1, peers, sm)
+
+		Expect(e.IsLeader()).To(BeTrue())
+	})
+
+	It("follows the lease holder reported by a binding cache", func() {
+		peers.setLease("https://cache-1", fmt.Sprintf(
+			`{"leader":false,"holder":"https://cache-0","expires_at":%q}`,
+			time.Now().Add(time.Minute).Format(time.RFC3339Nano),
+		))
+		e := cache.NewPeerElector(addrs, 2, peers, sm)
+
+		leader, self := e.Leader()
+		Expect(leader).To(Equal("https://cache-0"))
+		Expect(self).To(BeFalse())
+	})
+
+	It("takes over once the lease of the leader expires", func() {
+		peers.setAvailable("https://cache-0", true)
+		e := cache.NewPeerElector(
+			addrs,
+			1,
+			peers,
+			sm,
+			cache.WithLeaseDuration(50*time.Millisecond),
+			cache.WithLeaseCheckInterval(10*time.Millisecond),
+		)
+		go e.Run()
+		Expect(e.IsLeader()).To(BeFalse())
+
+		peers.setAvailable("https://cache-0", false)
+
+		Eventually(e.IsLeader).Should(BeTrue())
+	})
+
+	It("serves the lease", func() {
+		e := cache.NewPeerElector(addrs, 0, peers, sm)
+
+		rw := httptest.NewRecorder()
+		req, err := http.NewRequest(http.MethodGet, "/v2/lease", nil)
+		Expect(err).ToNot(HaveOccurred())
+		cache.LeaseHandler(e).ServeHTTP(rw, req)
+
+		Expect(rw.Code).To(Equal(http.StatusOK))
+		var l struct {
+			Leader    bool      `json:"leader"`
+			Holder    string    `json:"holder"`
+			ExpiresAt time.Time `json:"expires_at"`
+		}
+		Expect(json.Unmarshal(rw.Body.Bytes(), &l)).To(Succeed())
+		Expect(l.Leader).To(BeTrue())
+		Expect(l.Holder).To(Equal("https://cache-0"))
+		Expect(l.ExpiresAt).To(BeTemporally("~", time.Now().Add(30*time.Second), time.Second))
+	})
+
+	Describe("LeaderClient", func() {
+		It("fetches the bindings from the leader", func() {
+			peers.setAvailable("https://cache-0", true)
+			peers.bindings = `[{"url":"drain-1"}]`
+			e := cache.NewPeerElector(addrs, 1, peers, sm)
+
+			c := cache.NewLeaderClient(e, peers)
+
+			Expect(c.Get()).To(Equal([]binding.Binding{{Url: "drain-1"}}))
+			Expect(peers.requested()).To(ContainElement("https://cache-0/v2/bindings"))
+		})
+
+		It("fails if this binding cache is the leader", func() {
+			e := cache.NewPeerElector(addrs, 0, peers, sm)
+
+			_, err := cache.NewLeaderClient(e, peers).Get()
+			Expect(err).To(HaveOccurred())
+		})
+	})
+})
+
+type stubPeers struct {
+	mu        sync.Mutex
+	available map[string]bool
+	leases    map[string]string
+	requests  []string
+	bindings  string
+}
+
+func newStubPeers() *stubPeers {
+	return &stubPeers{
+		available: make(map[string]bool),
+		leases:    make(map[string]string),
+	}
+}
+
+// setLease makes the binding cache available and answer lease checks with
+// the given body instead of a lease held by itself.
+func (s *stubPeers) setLease(addr, body string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.available[addr] = true
+	s.leases[addr] = body
+}
+
+func (s *stubPeers) setAvailable(addr string, available bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.available[addr] = available
+}
+
+func (s *stubPeers) requested() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string{}, s.requests...)
+}
+
+func (s *stubPeers) Get(url string) (*http.Response, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.requests = append(s.requests, url)
+
+	for addr, available := range s.available {
+		if !strings.HasPrefix(url, addr+"/") {
+			continue
+		}
+		if !available {
+			break
+		}
+		body := "{}"
+		switch {
+		case strings.HasSuffix(url, "/v2/bindings"):
+			body = s.bindings
+		case s.leases[addr] != "":
+			body = s.leases[addr]
+		case strings.HasSuffix(url, "/v2/lease"):
+			body = fmt.Sprintf(
+				`{"leader":true,"holder":%q,"expires_at":%q}`,
+				addr,
+				time.Now().Add(time.Minute).Format(time.RFC3339Nano),
+			)
+		}
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Body:       io.NopCloser(strings.NewReader(body)),
+		}, nil
+	}
+	return nil, errors.New("connection refused")
+}
@@ -0,0 +1,84 @@
+package cache
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+
+	"code.cloudfoundry.org/loggregator-agent-release/src/pkg/binding"
+)
+
+// sourceFilter returns the source IDs given in the source_ids query
+// parameter as a comma separated list. It returns false if the parameter is
+// not given. An empty parameter filters out all bindings.
+func sourceFilter(r *http.Request) (map[string]bool, bool) {
+	values, ok := r.URL.Query()["source_ids"]
+	if !ok {
+		return nil, false
+	}
+
+	ids := make(map[string]bool)
+	for _, v := range values {
+		for _, id := range strings.Split(v, ",") {
+			if id != "" {
+				ids[id] = true
+			}
+		}
+	}
+	return ids, true
+}
+
+// filterBindings returns the bindings with only the apps whose app ID is in
+// the given source IDs. Bindings without any of these apps are removed.
+func filterBindings(bindings []binding.Binding, ids map[string]bool) []binding.Binding {
+	filtered := make([]binding.Binding, 0)
+	for _, b := range bindings {
+		if fb, ok := filterBinding(b, ids); ok {
+			filtered = append(filtered, fb)
+		}
+	}
+	return filtered
+}
+
+// filterDelta filters the updated bindings of the delta. Bindings that no
+// longer have any of the given source IDs are reported as deleted.
+func filterDelta(delta binding.BindingDelta, ids map[string]bool) binding.BindingDelta {
+	filtered := binding.BindingDelta{
+		Version: delta.Version,
+		Updated: make([]binding.Binding, 0, len(delta.Updated)),
+		Deleted: append([]string{}, delta.Deleted...),
+	}
+	for _, b := range delta.Updated {
+		fb, ok := filterBinding(b, ids)
+		if !ok {
+			filtered.Deleted = append(filtered.Deleted, b.Url)
+			continue
+		}
+		filtered.Updated = append(filtered.Updated, fb)
+	}
+	sort.Strings(filtered.Deleted)
+	return filtered
+}
+
+func filterBinding(b binding.Binding, ids map[string]bool) (binding.Binding, bool) {
+	var creds []binding.Credentials
+	for _, c := range b.Credentials {
+		var apps []binding.App
+		for _, a := range c.Apps {
+			if ids[a.AppID] {
+				apps = append(apps, a)
+			}
+		}
+		if len(apps) == 0 {
+			continue
+		}
+		c.Apps = apps
+		creds = append(creds, c)
+	}
+	if len(creds) == 0 {
+		return binding.Binding{}, false
+	}
+
+	b.Credentials = creds
+	return b, true
+}
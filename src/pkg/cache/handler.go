@@ -1,9 +1,13 @@
 package cache
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"log"
 	"net/http"
+	"sort"
+	"strings"
 
 	"code.cloudfoundry.org/loggregator-agent-release/src/pkg/binding"
 )
@@ -12,6 +16,14 @@ type Getter interface {
 	Get() []binding.Binding
 }
 
+// VersionedGetter is a Getter that versions its bindings and can compute
+// the changes since a version.
+type VersionedGetter interface {
+	Getter
+	GetVersioned() ([]binding.Binding, string)
+	Since(version string) (binding.BindingDelta, bool)
+}
+
 type LegacyGetter interface {
 	Get() []binding.LegacyBinding
 }
@@ -21,9 +33,58 @@ type AggregateGetter interface {
 	LegacyGet() []binding.LegacyBinding
 }
 
+// Handler writes the bindings of the store. If the store is a
+// VersionedGetter the response carries the version as ETag, requests with a
+// matching If-None-Match header are answered with 304 Not Modified and the
+// since query parameter returns only the changes since the given version.
+// Unknown versions are answered with 410 Gone. If the source_ids query
+// parameter is given only the bindings of these source IDs are written and
+// the ETag additionally identifies the source IDs, so that it only matches
+// requests with the same filter.
 func Handler(store Getter) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		err := json.NewEncoder(w).Encode(store.Get())
+		ids, filtered := sourceFilter(r)
+
+		vs, ok := store.(VersionedGetter)
+		if !ok {
+			bindings := store.Get()
+			if filtered {
+				bindings = filterBindings(bindings, ids)
+			}
+			err := json.NewEncoder(w).Encode(bindings)
+			if err != nil {
+				log.Printf("failed to encode response body: %s", err)
+				return
+			}
+			return
+		}
+
+		var body any
+		if since := r.URL.Query().Get("since"); since != "" {
+			delta, ok := vs.Since(etagVersion(since))
+			if !ok {
+				w.WriteHeader(http.StatusGone)
+				return
+			}
+			if filtered {
+				delta = filterDelta(delta, ids)
+			}
+			w.Header().Set("ETag", etag(delta.Version, ids))
+			body = delta
+		} else {
+			bindings, version := vs.GetVersioned()
+			if r.Header.Get("If-None-Match") == etag(version, ids) {
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+			if filtered {
+				bindings = filterBindings(bindings, ids)
+			}
+			w.Header().Set("ETag", etag(version, ids))
+			body = bindings
+		}
+
+		err := json.NewEncoder(w).Encode(body)
 		if err != nil {
 			log.Printf("failed to encode response body: %s", err)
 			return
@@ -31,6 +92,29 @@ func Handler(store Getter) http.HandlerFunc {
 	}
 }
 
+// etag returns the ETag of the version. If the response is filtered by
+// source IDs the ETag carries a hash of them after a semicolon.
+func etag(version string, ids map[string]bool) string {
+	if ids == nil {
+		return `"` + version + `"`
+	}
+
+	sorted := make([]string, 0, len(ids))
+	for id := range ids {
+		sorted = append(sorted, id)
+	}
+	sort.Strings(sorted)
+	h := sha256.Sum256([]byte(strings.Join(sorted, ",")))
+	return `"` + version + ";" + hex.EncodeToString(h[:8]) + `"`
+}
+
+// etagVersion returns the version of an ETag value without the hash of
+// the source IDs.
+func etagVersion(v string) string {
+	version, _, _ := strings.Cut(v, ";")
+	return version
+}
+
 func LegacyHandler(store LegacyGetter) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		err := json.NewEncoder(w).Encode(store.Get())
@@ -37,6 +37,192 @@ var _ = Describe("Handler", func() {
 		Expect(rw.Body.String()).To(MatchJSON(j))
 	})
 
+	Context("with source IDs", func() {
+		var store *stubStore
+
+		BeforeEach(func() {
+			store = newStubStore([]binding.Binding{
+				{
+					Url: "drain-1",
+					Credentials: []binding.Credentials{
+						{Cert: "cert-1", Apps: []binding.App{{AppID: "app-1"}, {AppID: "app-2"}}},
+						{Cert: "cert-2", Apps: []binding.App{{AppID: "app-3"}}},
+					},
+				},
+				{
+					Url: "drain-2",
+					Credentials: []binding.Credentials{
+						{Apps: []binding.App{{AppID: "app-3"}}},
+					},
+				},
+			})
+		})
+
+		It("only writes the bindings of the source IDs", func() {
+			rw := httptest.NewRecorder()
+			req, err := http.NewRequest(http.MethodGet, "/v2/bindings?source_ids=app-1,app-4", nil)
+			Expect(err).ToNot(HaveOccurred())
+			cache.Handler(store).ServeHTTP(rw, req)
+
+			Expect(rw.Body.String()).To(MatchJSON(`[{
+				"url": "drain-1",
+				"credentials": [{"cert":"cert-1","key":"","ca":"","apps":[{"hostname":"","app_id":"app-1"}]}]
+			}]`))
+		})
+
+		It("writes no bindings if the source IDs are empty", func() {
+			rw := httptest.NewRecorder()
+			req, err := http.NewRequest(http.MethodGet, "/v2/bindings?source_ids=", nil)
+			Expect(err).ToNot(HaveOccurred())
+			cache.Handler(store).ServeHTTP(rw, req)
+
+			Expect(rw.Body.String()).To(MatchJSON(`[]`))
+		})
+
+		It("reports bindings without the source IDs as deleted in deltas", func() {
+			vs := &stubVersionedStore{
+				stubStore: *store,
+				version:   "1-2",
+				deltas: map[string]binding.BindingDelta{
+					"1-1": {
+						Version: "1-2",
+						Updated: store.bindings,
+						Deleted: []string{"drain-3"},
+					},
+				},
+			}
+
+			rw := httptest.NewRecorder()
+			req, err := http.NewRequest(http.MethodGet, "/v2/bindings?since=1-1&source_ids=app-3", nil)
+			Expect(err).ToNot(HaveOccurred())
+			cache.Handler(vs).ServeHTTP(rw, req)
+
+			var delta binding.BindingDelta
+			Expect(json.Unmarshal(rw.Body.Bytes(), &delta)).To(Succeed())
+			Expect(delta.Updated).To(HaveLen(2))
+			Expect(delta.Deleted).To(Equal([]string{"drain-3"}))
+
+			rw = httptest.NewRecorder()
+			req, err = http.NewRequest(http.MethodGet, "/v2/bindings?since=1-1&source_ids=app-2", nil)
+			Expect(err).ToNot(HaveOccurred())
+			cache.Handler(vs).ServeHTTP(rw, req)
+
+			Expect(json.Unmarshal(rw.Body.Bytes(), &delta)).To(Succeed())
+			Expect(delta.Updated).To(HaveLen(1))
+			Expect(delta.Updated[0].Url).To(Equal("drain-1"))
+			Expect(delta.Deleted).To(Equal([]string{"drain-2", "drain-3"}))
+		})
+	})
+
+	Context("with a versioned store", func() {
+		var (
+			store   *stubVersionedStore
+			handler http.HandlerFunc
+		)
+
+		BeforeEach(func() {
+			store = &stubVersionedStore{
+				stubStore: stubStore{bindings: []binding.Binding{{Url: "drain-1"}}},
+				version:   "1-2",
+				deltas: map[string]binding.BindingDelta{
+					"1-1": {
+						Version: "1-2",
+						Updated: []binding.Binding{{Url: "drain-1"}},
+						Deleted: []string{"drain-2"},
+					},
+				},
+			}
+			handler = cache.Handler(store)
+		})
+
+		It("sets the version as ETag", func() {
+			rw := httptest.NewRecorder()
+			req, err := http.NewRequest(http.MethodGet, "/v2/bindings", nil)
+			Expect(err).ToNot(HaveOccurred())
+			handler.ServeHTTP(rw, req)
+
+			Expect(rw.Code).To(Equal(http.StatusOK))
+			Expect(rw.Header().Get("ETag")).To(Equal(`"1-2"`))
+			Expect(rw.Body.String()).To(MatchJSON(`[{"url":"drain-1","credentials":null}]`))
+		})
+
+		It("responds with not modified if the version matches", func() {
+			rw := httptest.NewRecorder()
+			req, err := http.NewRequest(http.MethodGet, "/v2/bindings", nil)
+			Expect(err).ToNot(HaveOccurred())
+			req.Header.Set("If-None-Match", `"1-2"`)
+			handler.ServeHTTP(rw, req)
+
+			Expect(rw.Code).To(Equal(http.StatusNotModified))
+			Expect(rw.Body.String()).To(BeEmpty())
+		})
+
+		It("writes the changes since a version", func() {
+			rw := httptest.NewRecorder()
+			req, err := http.NewRequest(http.MethodGet, "/v2/bindings?since=1-1", nil)
+			Expect(err).ToNot(HaveOccurred())
+			handler.ServeHTTP(rw, req)
+
+			Expect(rw.Code).To(Equal(http.StatusOK))
+			Expect(rw.Header().Get("ETag")).To(Equal(`"1-2"`))
+			Expect(rw.Body.String()).To(MatchJSON(`{
+				"version": "1-2",
+				"updated": [{"url":"drain-1","credentials":null}],
+				"deleted": ["drain-2"]
+			}`))
+		})
+
+		It("only responds with not modified if the source IDs match", func() {
+			rw := httptest.NewRecorder()
+			req, err := http.NewRequest(http.MethodGet, "/v2/bindings?source_ids=app-1", nil)
+			Expect(err).ToNot(HaveOccurred())
+			handler.ServeHTTP(rw, req)
+			filteredETag := rw.Header().Get("ETag")
+			Expect(filteredETag).To(HavePrefix(`"1-2;`))
+
+			rw = httptest.NewRecorder()
+			req, err = http.NewRequest(http.MethodGet, "/v2/bindings?source_ids=app-2", nil)
+			Expect(err).ToNot(HaveOccurred())
+			req.Header.Set("If-None-Match", filteredETag)
+			handler.ServeHTTP(rw, req)
+			Expect(rw.Code).To(Equal(http.StatusOK))
+			Expect(rw.Header().Get("ETag")).ToNot(Equal(filteredETag))
+
+			rw = httptest.NewRecorder()
+			req, err = http.NewRequest(http.MethodGet, "/v2/bindings?source_ids=app-1", nil)
+			Expect(err).ToNot(HaveOccurred())
+			req.Header.Set("If-None-Match", `"1-2"`)
+			handler.ServeHTTP(rw, req)
+			Expect(rw.Code).To(Equal(http.StatusOK))
+
+			rw = httptest.NewRecorder()
+			req, err = http.NewRequest(http.MethodGet, "/v2/bindings?source_ids=app-1", nil)
+			Expect(err).ToNot(HaveOccurred())
+			req.Header.Set("If-None-Match", filteredETag)
+			handler.ServeHTTP(rw, req)
+			Expect(rw.Code).To(Equal(http.StatusNotModified))
+		})
+
+		It("writes the changes since the version of a filtered ETag", func() {
+			rw := httptest.NewRecorder()
+			req, err := http.NewRequest(http.MethodGet, "/v2/bindings?since=1-1;0123456789abcdef&source_ids=app-1", nil)
+			Expect(err).ToNot(HaveOccurred())
+			handler.ServeHTTP(rw, req)
+
+			Expect(rw.Code).To(Equal(http.StatusOK))
+			Expect(rw.Header().Get("ETag")).To(HavePrefix(`"1-2;`))
+		})
+
+		It("responds with gone if the version is unknown", func() {
+			rw := httptest.NewRecorder()
+			req, err := http.NewRequest(http.MethodGet, "/v2/bindings?since=0-1", nil)
+			Expect(err).ToNot(HaveOccurred())
+			handler.ServeHTTP(rw, req)
+
+			Expect(rw.Code).To(Equal(http.StatusGone))
+		})
+	})
+
 	It("should write results from the legacy store", func() {
 		bindings := []binding.LegacyBinding{
 			{
@@ -108,6 +294,21 @@ type stubStore struct {
 	bindings []binding.Binding
 }
 
+type stubVersionedStore struct {
+	stubStore
+	version string
+	deltas  map[string]binding.BindingDelta
+}
+
+func (s *stubVersionedStore) GetVersioned() ([]binding.Binding, string) {
+	return s.bindings, s.version
+}
+
+func (s *stubVersionedStore) Since(version string) (binding.BindingDelta, bool) {
+	d, ok := s.deltas[version]
+	return d, ok
+}
+
 type stubLegacyStore struct {
 	bindings []binding.LegacyBinding
 }
@@ -0,0 +1,204 @@
+package cache
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+
+	"code.cloudfoundry.org/loggregator-agent-release/src/pkg/binding"
+	"code.cloudfoundry.org/loggregator-agent-release/src/pkg/egress/syslog"
+)
+
+const (
+	defaultQueryBatchSize = 100
+	maxQueryBatchSize     = 1000
+)
+
+// PollStatusGetter returns the status of the polls of the binding provider.
+type PollStatusGetter interface {
+	Status() binding.PollStatus
+}
+
+type queryPage struct {
+	Results any `json:"results"`
+	NextID  int `json:"next_id"`
+}
+
+type appDrain struct {
+	URL      string `json:"url"`
+	Hostname string `json:"hostname"`
+}
+
+type drainCount struct {
+	Scheme string `json:"scheme"`
+	Host   string `json:"host"`
+	Drains int    `json:"drains"`
+	Apps   int    `json:"apps"`
+}
+
+// QueryHandler returns a handler that answers support queries about the
+// bindings of the store and the polls of the binding provider. Drain URLs
+// are anonymized. Lists are paginated like the binding provider: the
+// batch_size and next_id query parameters select a page and the response
+// holds the next_id of the following page, which is 0 on the last page.
+//
+//	GET /apps/{app_id}/drains
+//	GET /drains/apps?url=<anonymized drain url>
+//	GET /drains/counts
+//	GET /poll
+func QueryHandler(store Getter, poller PollStatusGetter) http.Handler {
+	router := chi.NewRouter()
+	router.Get("/apps/{app_id}/drains", func(w http.ResponseWriter, r *http.Request) {
+		writePage(w, r, appDrains(store.Get(), chi.URLParam(r, "app_id")))
+	})
+	router.Get("/drains/apps", func(w http.ResponseWriter, r *http.Request) {
+		drainURL := r.URL.Query().Get("url")
+		if drainURL == "" {
+			http.Error(w, "url query parameter is required", http.StatusBadRequest)
+			return
+		}
+		writePage(w, r, drainApps(store.Get(), syslog.AnonymizeDrainURL(drainURL)))
+	})
+	router.Get("/drains/counts", func(w http.ResponseWriter, r *http.Request) {
+		writePage(w, r, drainCounts(store.Get()))
+	})
+	router.Get("/poll", func(w http.ResponseWriter, _ *http.Request) {
+		writeJSON(w, poller.Status())
+	})
+
+	return router
+}
+
+func appDrains(bindings []binding.Binding, appID string) []appDrain {
+	drains := []appDrain{}
+	for _, b := range bindings {
+		for _, c := range b.Credentials {
+			for _, a := range c.Apps {
+				if a.AppID == appID {
+					drains = append(drains, appDrain{
+						URL:      syslog.AnonymizeDrainURL(b.Url),
+						Hostname: a.Hostname,
+					})
+				}
+			}
+		}
+	}
+	sort.Slice(drains, func(i, j int) bool {
+		return drains[i].URL < drains[j].URL
+	})
+	return drains
+}
+
+func drainApps(bindings []binding.Binding, drainURL string) []binding.App {
+	seen := make(map[string]bool)
+	apps := []binding.App{}
+	for _, b := range bindings {
+		if syslog.AnonymizeDrainURL(b.Url) != drainURL {
+			continue
+		}
+		for _, c := range b.Credentials {
+			for _, a := range c.Apps {
+				if seen[a.AppID] {
+					continue
+				}
+				seen[a.AppID] = true
+				apps = append(apps, a)
+			}
+		}
+	}
+	sort.Slice(apps, func(i, j int) bool {
+		return apps[i].AppID < apps[j].AppID
+	})
+	return apps
+}
+
+func drainCounts(bindings []binding.Binding) []drainCount {
+	type key struct {
+		scheme string
+		host   string
+	}
+	drains := make(map[key]int)
+	apps := make(map[key]map[string]bool)
+	for _, b := range bindings {
+		u, err := url.Parse(b.Url)
+		if err != nil {
+			continue
+		}
+		k := key{scheme: u.Scheme, host: u.Hostname()}
+		drains[k]++
+		if apps[k] == nil {
+			apps[k] = make(map[string]bool)
+		}
+		for _, c := range b.Credentials {
+			for _, a := range c.Apps {
+				apps[k][a.AppID] = true
+			}
+		}
+	}
+
+	counts := []drainCount{}
+	for k, n := range drains {
+		counts = append(counts, drainCount{
+			Scheme: k.scheme,
+			Host:   k.host,
+			Drains: n,
+			Apps:   len(apps[k]),
+		})
+	}
+	sort.Slice(counts, func(i, j int) bool {
+		if counts[i].Scheme != counts[j].Scheme {
+			return counts[i].Scheme < counts[j].Scheme
+		}
+		return counts[i].Host < counts[j].Host
+	})
+	return counts
+}
+
+// writePage writes the page of the results selected by the batch_size and
+// next_id query parameters.
+func writePage[T any](w http.ResponseWriter, r *http.Request, results []T) {
+	batchSize, ok := queryInt(r, "batch_size", defaultQueryBatchSize)
+	if !ok || batchSize <= 0 || batchSize > maxQueryBatchSize {
+		http.Error(w, "invalid batch_size", http.StatusBadRequest)
+		return
+	}
+	start, ok := queryInt(r, "next_id", 0)
+	if !ok || start < 0 {
+		http.Error(w, "invalid next_id", http.StatusBadRequest)
+		return
+	}
+
+	page := queryPage{Results: []T{}}
+	if start < len(results) {
+		end := start + batchSize
+		if end < len(results) {
+			page.NextID = end
+		} else {
+			end = len(results)
+		}
+		page.Results = results[start:end]
+	}
+	writeJSON(w, page)
+}
+
+func queryInt(r *http.Request, name string, def int) (int, bool) {
+	v := r.URL.Query().Get(name)
+	if v == "" {
+		return def, true
+	}
+	n, err := strconv.Atoi(v)
+	return n, err == nil
+}
+
+func writeJSON(w http.ResponseWriter, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	err := json.NewEncoder(w).Encode(body)
+	if err != nil {
+		log.Printf("failed to encode response body: %s", err)
+	}
+}
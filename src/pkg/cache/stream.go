@@ -0,0 +1,109 @@
+package cache
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"code.cloudfoundry.org/loggregator-agent-release/src/pkg/binding"
+)
+
+// Streamer is a VersionedGetter that notifies subscribers when its version
+// changes.
+type Streamer interface {
+	VersionedGetter
+	Subscribe() (<-chan struct{}, func())
+}
+
+type snapshot struct {
+	Version  string            `json:"version"`
+	Bindings []binding.Binding `json:"bindings"`
+}
+
+// StreamHandler streams the bindings of the store as server-sent events.
+// The first event is a snapshot event with all bindings unless the since
+// query parameter names a version known to the store, in which case it is a
+// delta event with the changes since that version. Every change of the
+// store is then sent as a delta event. Keepalive comments are written every
+// keepalive interval so that idle connections are not closed. The
+// source_ids query parameter filters the bindings as in Handler.
+func StreamHandler(store Streamer, keepalive time.Duration) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		f, ok := w.(http.Flusher)
+		if !ok {
+			http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+			return
+		}
+
+		notify, unsubscribe := store.Subscribe()
+		defer unsubscribe()
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.WriteHeader(http.StatusOK)
+
+		ids, _ := sourceFilter(r)
+
+		version := etagVersion(r.URL.Query().Get("since"))
+		if err := sendChanges(w, store, ids, &version, true); err != nil {
+			return
+		}
+		f.Flush()
+
+		t := time.NewTicker(keepalive)
+		defer t.Stop()
+
+		for {
+			select {
+			case <-r.Context().Done():
+				return
+			case <-t.C:
+				if _, err := fmt.Fprint(w, ": keepalive\n\n"); err != nil {
+					return
+				}
+			case <-notify:
+				if err := sendChanges(w, store, ids, &version, false); err != nil {
+					return
+				}
+			}
+			f.Flush()
+		}
+	}
+}
+
+// sendChanges writes the changes since the given version and updates it.
+// A snapshot is written if the version is unknown to the store. Empty
+// deltas are only written if force is set. The bindings are filtered by the
+// given source IDs unless they are nil.
+func sendChanges(w http.ResponseWriter, store Streamer, ids map[string]bool, version *string, force bool) error {
+	delta, ok := store.Since(*version)
+	if *version == "" || !ok {
+		bindings, v := store.GetVersioned()
+		if ids != nil {
+			bindings = filterBindings(bindings, ids)
+		}
+		*version = v
+		return writeEvent(w, "snapshot", snapshot{Version: v, Bindings: bindings})
+	}
+
+	if !force && delta.Version == *version {
+		return nil
+	}
+	if ids != nil {
+		delta = filterDelta(delta, ids)
+	}
+	*version = delta.Version
+	return writeEvent(w, "delta", delta)
+}
+
+func writeEvent(w http.ResponseWriter, event string, data any) error {
+	body, err := json.Marshal(data)
+	if err != nil {
+		log.Printf("failed to encode %s event: %s", event, err)
+		return err
+	}
+	_, err = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, body)
+	return err
+}
@@ -0,0 +1,153 @@
+package cache
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"code.cloudfoundry.org/loggregator-agent-release/src/pkg/binding"
+)
+
+// streamIdleTimeout is the time after which a stream without any event or
+// keepalive is considered dead.
+const streamIdleTimeout = 2 * time.Minute
+
+// Stream subscribes to the binding stream of the cache and keeps the
+// bindings returned by Get up to date. onChange is called whenever the
+// bindings change. If the stream is unavailable Get fetches the bindings
+// again and the subscription is retried every retryInterval. Stream blocks
+// until the context is done. It returns immediately if the HTTP client does
+// not support requests with a context.
+func (c *CacheClient) Stream(ctx context.Context, retryInterval time.Duration, onChange func()) {
+	d, ok := c.h.(httpDoer)
+	if !ok {
+		return
+	}
+
+	for {
+		err := c.stream(ctx, d, onChange)
+
+		c.mu.Lock()
+		c.streaming = false
+		restart := c.filterChanged
+		c.filterChanged = false
+		c.mu.Unlock()
+
+		if ctx.Err() != nil {
+			return
+		}
+		if restart {
+			continue
+		}
+		log.Printf("binding cache stream unavailable, polling instead: %s", err)
+		c.next()
+
+		t := time.NewTimer(retryInterval)
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return
+		case <-t.C:
+		}
+	}
+}
+
+func (c *CacheClient) stream(ctx context.Context, d httpDoer, onChange func()) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	c.mu.Lock()
+	if _, err := c.updateFilter(); err != nil {
+		c.mu.Unlock()
+		return err
+	}
+	c.stopStream = cancel
+	filter := c.filter
+	addr := fmt.Sprintf("%s/%s", c.addr(), c.bindingsPath("v2/bindings/stream", c.version))
+	c.mu.Unlock()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, addr, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "text/event-stream")
+
+	resp, err := d.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected http response from binding cache: %d", resp.StatusCode)
+	}
+
+	idle := time.AfterFunc(streamIdleTimeout, cancel)
+	defer idle.Stop()
+
+	var event, data string
+	r := bufio.NewReader(resp.Body)
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return err
+		}
+		idle.Reset(streamIdleTimeout)
+
+		line = strings.TrimRight(line, "\r\n")
+		switch {
+		case line == "":
+			if event == "" {
+				continue
+			}
+			if err := c.applyEvent(filter, event, data); err != nil {
+				return err
+			}
+			event, data = "", ""
+			onChange()
+		case strings.HasPrefix(line, ":"):
+		case strings.HasPrefix(line, "event:"):
+			event = strings.TrimSpace(strings.TrimPrefix(line, "event:"))
+		case strings.HasPrefix(line, "data:"):
+			data += strings.TrimSpace(strings.TrimPrefix(line, "data:"))
+		}
+	}
+}
+
+func (c *CacheClient) applyEvent(filter, event, data string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// The source IDs changed while the event was received.
+	if filter != c.filter {
+		return errors.New("binding stream source IDs changed")
+	}
+
+	switch event {
+	case "snapshot":
+		var s snapshot
+		if err := json.Unmarshal([]byte(data), &s); err != nil {
+			return err
+		}
+		c.bindings = s.Bindings
+		c.version = s.Version
+	case "delta":
+		var delta binding.BindingDelta
+		if err := json.Unmarshal([]byte(data), &delta); err != nil {
+			return err
+		}
+		c.bindings = applyDelta(c.bindings, delta)
+		c.version = delta.Version
+	default:
+		return errors.New("unknown binding stream event: " + event)
+	}
+	c.streaming = true
+
+	return nil
+}
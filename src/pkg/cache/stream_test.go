@@ -0,0 +1,200 @@
+package cache_test
+
+import (
+	"bufio"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	metricsHelpers "code.cloudfoundry.org/go-metric-registry/testhelpers"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"code.cloudfoundry.org/loggregator-agent-release/src/pkg/binding"
+	"code.cloudfoundry.org/loggregator-agent-release/src/pkg/cache"
+)
+
+var _ = Describe("StreamHandler", func() {
+	var (
+		store  *binding.Store
+		server *httptest.Server
+	)
+
+	BeforeEach(func() {
+		store = binding.NewStore(metricsHelpers.NewMetricsRegistry())
+		store.Set([]binding.Binding{{Url: "drain-1"}}, 1)
+
+		router := http.NewServeMux()
+		router.Handle("/v2/bindings", cache.Handler(store))
+		router.Handle("/v2/bindings/stream", cache.StreamHandler(store, 10*time.Millisecond))
+		server = httptest.NewServer(router)
+	})
+
+	AfterEach(func() {
+		server.CloseClientConnections()
+		server.Close()
+	})
+
+	readEvents := func(path string) (chan sseEvent, context.CancelFunc) {
+		ctx, cancel := context.WithCancel(context.Background())
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, server.URL+path, nil)
+		Expect(err).ToNot(HaveOccurred())
+		resp, err := http.DefaultClient.Do(req)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(resp.Header.Get("Content-Type")).To(Equal("text/event-stream"))
+
+		events := make(chan sseEvent, 100)
+		go func() {
+			defer resp.Body.Close()
+			var e sseEvent
+			r := bufio.NewReader(resp.Body)
+			for {
+				line, err := r.ReadString('\n')
+				if err != nil {
+					return
+				}
+				line = strings.TrimSpace(line)
+				switch {
+				case line == "":
+					events <- e
+					e = sseEvent{}
+				case strings.HasPrefix(line, ":"):
+					e.comment = strings.TrimSpace(line[1:])
+				case strings.HasPrefix(line, "event:"):
+					e.name = strings.TrimSpace(strings.TrimPrefix(line, "event:"))
+				case strings.HasPrefix(line, "data:"):
+					e.data = strings.TrimSpace(strings.TrimPrefix(line, "data:"))
+				}
+			}
+		}()
+		return events, cancel
+	}
+
+	nextEvent := func(events chan sseEvent) sseEvent {
+		for {
+			var e sseEvent
+			Eventually(events).Should(Receive(&e))
+			if e.name != "" {
+				return e
+			}
+		}
+	}
+
+	It("sends a snapshot followed by deltas", func() {
+		_, v1 := store.GetVersioned()
+		events, cancel := readEvents("/v2/bindings/stream")
+		defer cancel()
+
+		e := nextEvent(events)
+		Expect(e.name).To(Equal("snapshot"))
+		Expect(e.data).To(MatchJSON(`{"version":"` + v1 + `","bindings":[{"url":"drain-1","credentials":null}]}`))
+
+		store.Set([]binding.Binding{{Url: "drain-2"}}, 1)
+		_, v2 := store.GetVersioned()
+
+		e = nextEvent(events)
+		Expect(e.name).To(Equal("delta"))
+		Expect(e.data).To(MatchJSON(`{"version":"` + v2 + `","updated":[{"url":"drain-2","credentials":null}],"deleted":["drain-1"]}`))
+	})
+
+	It("sends the changes since a known version", func() {
+		_, v1 := store.GetVersioned()
+		store.Set([]binding.Binding{{Url: "drain-2"}}, 1)
+
+		events, cancel := readEvents("/v2/bindings/stream?since=" + v1)
+		defer cancel()
+
+		Expect(nextEvent(events).name).To(Equal("delta"))
+	})
+
+	It("sends a snapshot for unknown versions", func() {
+		events, cancel := readEvents("/v2/bindings/stream?since=1-1")
+		defer cancel()
+
+		Expect(nextEvent(events).name).To(Equal("snapshot"))
+	})
+
+	It("filters the bindings by source IDs", func() {
+		store.Set([]binding.Binding{
+			{Url: "drain-1", Credentials: []binding.Credentials{{Apps: []binding.App{{AppID: "app-1"}}}}},
+			{Url: "drain-2", Credentials: []binding.Credentials{{Apps: []binding.App{{AppID: "app-2"}}}}},
+		}, 2)
+		events, cancel := readEvents("/v2/bindings/stream?source_ids=app-1")
+		defer cancel()
+
+		e := nextEvent(events)
+		Expect(e.name).To(Equal("snapshot"))
+		Expect(e.data).To(ContainSubstring("drain-1"))
+		Expect(e.data).ToNot(ContainSubstring("drain-2"))
+
+		store.Set([]binding.Binding{
+			{Url: "drain-1", Credentials: []binding.Credentials{{Apps: []binding.App{{AppID: "app-2"}}}}},
+		}, 1)
+
+		e = nextEvent(events)
+		Expect(e.name).To(Equal("delta"))
+		Expect(e.data).To(MatchRegexp(`"updated":\[\],"deleted":\["drain-1","drain-2"\]`))
+	})
+
+	It("sends keepalives", func() {
+		events, cancel := readEvents("/v2/bindings/stream")
+		defer cancel()
+
+		Eventually(events).Should(Receive(Satisfy(func(e sseEvent) bool {
+			return e.comment == "keepalive"
+		})))
+	})
+
+	Describe("CacheClient", func() {
+		var (
+			client  *cache.CacheClient
+			changes int64
+			ctx     context.Context
+			cancel  context.CancelFunc
+		)
+
+		BeforeEach(func() {
+			changes = 0
+			client = cache.NewClient(server.URL, server.Client())
+			ctx, cancel = context.WithCancel(context.Background())
+			go client.Stream(ctx, 10*time.Millisecond, func() {
+				atomic.AddInt64(&changes, 1)
+			})
+		})
+
+		AfterEach(func() {
+			cancel()
+		})
+
+		It("returns the streamed bindings", func() {
+			Eventually(func() int64 { return atomic.LoadInt64(&changes) }).Should(Equal(int64(1)))
+			Expect(client.Get()).To(Equal([]binding.Binding{{Url: "drain-1"}}))
+
+			store.Set([]binding.Binding{{Url: "drain-1"}, {Url: "drain-2"}}, 2)
+
+			Eventually(func() int64 { return atomic.LoadInt64(&changes) }).Should(Equal(int64(2)))
+			Expect(client.Get()).To(Equal([]binding.Binding{{Url: "drain-1"}, {Url: "drain-2"}}))
+		})
+
+		It("resubscribes when the stream is closed", func() {
+			Eventually(func() int64 { return atomic.LoadInt64(&changes) }).Should(Equal(int64(1)))
+
+			server.CloseClientConnections()
+			store.Set([]binding.Binding{{Url: "drain-2"}}, 1)
+
+			Eventually(func() ([]binding.Binding, error) {
+				return client.Get()
+			}).Should(Equal([]binding.Binding{{Url: "drain-2"}}))
+			Eventually(func() int64 { return atomic.LoadInt64(&changes) }).Should(BeNumerically(">=", 2))
+		})
+	})
+})
+
+type sseEvent struct {
+	name    string
+	data    string
+	comment string
+}
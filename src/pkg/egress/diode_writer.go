@@ -2,6 +2,7 @@ package egress
 
 import (
 	"io"
+	"sync/atomic"
 
 	"golang.org/x/net/context"
 
@@ -24,11 +25,19 @@ type WriteCloser interface {
 	io.Closer
 }
 
+// diodeWriterCapacity is the number of envelopes a DiodeWriter buffers
+// before it starts dropping them.
+const diodeWriterCapacity = 10000
+
 type DiodeWriter struct {
 	wc    WriteCloser
 	diode *diodes.OneToOneEnvelopeV2
 	wg    WaitGroup
 
+	written int64
+	read    int64
+	dropped int64
+
 	ctx context.Context
 }
 
@@ -39,11 +48,18 @@ func NewDiodeWriter(
 	wg WaitGroup,
 ) *DiodeWriter {
 	dw := &DiodeWriter{
-		wc:    wc,
-		diode: diodes.NewOneToOneEnvelopeV2(10000, alerter, gendiodes.WithWaiterContext(ctx)),
-		wg:    wg,
-		ctx:   ctx,
+		wc:  wc,
+		wg:  wg,
+		ctx: ctx,
 	}
+	dw.diode = diodes.NewOneToOneEnvelopeV2(
+		diodeWriterCapacity,
+		gendiodes.AlertFunc(func(missed int) {
+			atomic.AddInt64(&dw.dropped, int64(missed))
+			alerter.Alert(missed)
+		}),
+		gendiodes.WithWaiterContext(ctx),
+	)
 	wg.Add(1)
 	go dw.start()
 
@@ -52,6 +68,7 @@ func NewDiodeWriter(
 
 // Write writes an envelope into the diode. This can not fail.
 func (d *DiodeWriter) Write(env *loggregator_v2.Envelope) error {
+	atomic.AddInt64(&d.written, 1)
 	d.diode.Set(env)
 
 	return nil
@@ -66,6 +83,7 @@ func (d *DiodeWriter) start() {
 		if e == nil {
 			return
 		}
+		atomic.AddInt64(&d.read, 1)
 
 		err := d.wc.Write(e)
 		if err != nil && ContextDone(d.ctx) {
@@ -74,6 +92,23 @@ func (d *DiodeWriter) start() {
 	}
 }
 
+// Len returns the approximate number of envelopes buffered in the diode.
+func (d *DiodeWriter) Len() int {
+	n := atomic.LoadInt64(&d.written) - atomic.LoadInt64(&d.read) - atomic.LoadInt64(&d.dropped)
+	if n < 0 {
+		return 0
+	}
+	if n > diodeWriterCapacity {
+		return diodeWriterCapacity
+	}
+	return int(n)
+}
+
+// Cap returns the number of envelopes the diode can buffer.
+func (d *DiodeWriter) Cap() int {
+	return diodeWriterCapacity
+}
+
 func ContextDone(ctx context.Context) bool {
 	select {
 	case <-ctx.Done():
@@ -102,6 +102,30 @@ var _ = Describe("DiodeWriter", func() {
 		Eventually(spyWriter.CloseCalled).ShouldNot(BeZero())
 	})
 
+	It("reports the number of buffered envelopes", func() {
+		spyWaitGroup := &SpyWaitGroup{}
+		spyWriter := &SpyWriter{
+			blockWrites: true,
+		}
+		spyAlerter := &SpyAlerter{}
+		ctx, cancel := context.WithCancel(context.TODO())
+		defer cancel()
+
+		dw := egress.NewDiodeWriter(ctx, spyWriter, spyAlerter, spyWaitGroup)
+		Expect(dw.Cap()).To(Equal(10000))
+		Expect(dw.Len()).To(Equal(0))
+
+		for i := 0; i < 11; i++ {
+			_ = dw.Write(&loggregator_v2.Envelope{})
+		}
+
+		// One envelope is held by the blocked writer.
+		Eventually(dw.Len).Should(Equal(10))
+
+		spyWriter.WriteBlocked(false)
+		Eventually(dw.Len).Should(Equal(0))
+	})
+
 	It("registers with the wait group and deregisters when done", func() {
 		spyWaitGroup := &SpyWaitGroup{}
 		spyWriter := &SpyWriter{
@@ -0,0 +1,253 @@
+package syslog
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"code.cloudfoundry.org/go-loggregator/v9"
+	metrics "code.cloudfoundry.org/go-metric-registry"
+	"code.cloudfoundry.org/loggregator-agent-release/src/pkg/egress"
+	"code.cloudfoundry.org/loggregator-agent-release/src/pkg/plumbing"
+)
+
+const (
+	defaultCertCheckInterval = time.Minute
+	certWarningInterval      = 24 * time.Hour
+)
+
+type gaugeMetricClient interface {
+	NewGauge(name, helpText string, o ...metrics.MetricOption) metrics.Gauge
+	RemoveGauge(g metrics.Gauge)
+}
+
+// CertificateMonitor tracks the client certificates of syslog drain
+// bindings. It exports their expiry and warns in the app's log stream when a
+// certificate is about to expire or has expired. Bindings with the same
+// certificate share its expiry gauge, which is removed once no binding uses
+// the certificate anymore.
+type CertificateMonitor struct {
+	m           gaugeMetricClient
+	logClient   LogClient
+	sourceIndex string
+	threshold   time.Duration
+	interval    time.Duration
+
+	mu     sync.Mutex
+	certs  map[drainKey]*drainCertificate
+	gauges map[string]*expiryGauge
+}
+
+// expiryGauge is the expiry gauge of a certificate and the number of
+// bindings using it.
+type expiryGauge struct {
+	gauge metrics.Gauge
+	refs  int
+}
+
+type drainCertificate struct {
+	ctx        context.Context
+	url        string
+	cert       *x509.Certificate
+	expiry     metrics.Gauge
+	lastWarned time.Time
+}
+
+// CertificateMonitorOption allows a CertificateMonitor to be customized.
+type CertificateMonitorOption func(*CertificateMonitor)
+
+// WithCertificateLogClient returns a CertificateMonitorOption that will emit
+// the expiry warnings to the given log client.
+func WithCertificateLogClient(logClient LogClient, sourceIndex string) CertificateMonitorOption {
+	return func(c *CertificateMonitor) {
+		c.logClient = logClient
+		c.sourceIndex = sourceIndex
+	}
+}
+
+// WithCertificateCheckInterval sets how often the expiry of the certificates
+// is checked. Defaults to one minute.
+func WithCertificateCheckInterval(d time.Duration) CertificateMonitorOption {
+	return func(c *CertificateMonitor) {
+		c.interval = d
+	}
+}
+
+// NewCertificateMonitor returns a CertificateMonitor that warns about
+// certificates that expire within the given threshold.
+func NewCertificateMonitor(m gaugeMetricClient, threshold time.Duration, opts ...CertificateMonitorOption) *CertificateMonitor {
+	c := &CertificateMonitor{
+		m:         m,
+		logClient: nullLogClient{},
+		threshold: threshold,
+		interval:  defaultCertCheckInterval,
+		certs:     make(map[drainKey]*drainCertificate),
+		gauges:    make(map[string]*expiryGauge),
+	}
+	for _, o := range opts {
+		o(c)
+	}
+	return c
+}
+
+// Track starts tracking the client certificate of the given binding. It does
+// nothing if the binding has no parsable client certificate. The
+// certificate is forgotten once the binding's context is done. Tracking the
+// same certificate again for a binding keeps its warning throttle.
+func (c *CertificateMonitor) Track(ub *URLBinding) {
+	if c == nil {
+		return
+	}
+
+	cert := parseCertificate(ub.Certificate)
+	if cert == nil {
+		return
+	}
+
+	ctx := ub.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	k := drainKey{appID: ub.AppID, url: anonymizeURL(ub.URL)}
+
+	c.mu.Lock()
+	dc, ok := c.certs[k]
+	if ok && dc.cert.Equal(cert) {
+		dc.ctx = ctx
+	} else {
+		if ok {
+			c.releaseGauge(dc.cert)
+		}
+		dc = &drainCertificate{
+			ctx:    ctx,
+			url:    k.url,
+			cert:   cert,
+			expiry: c.acquireGauge(cert),
+		}
+		c.certs[k] = dc
+	}
+	c.mu.Unlock()
+
+	c.check(k.appID, dc, time.Now())
+}
+
+// acquireGauge returns the expiry gauge of the certificate. It must be
+// called while holding c.mu.
+func (c *CertificateMonitor) acquireGauge(cert *x509.Certificate) metrics.Gauge {
+	fingerprint := plumbing.CertificateFingerprint(cert)
+	eg, ok := c.gauges[fingerprint]
+	if !ok {
+		eg = &expiryGauge{
+			gauge: c.m.NewGauge(
+				"cert_expiry_seconds",
+				"Number of seconds until the certificate expires.",
+				metrics.WithMetricLabels(map[string]string{
+					"purpose":     "drain_client",
+					"subject":     plumbing.CertificateSubject(cert),
+					"fingerprint": fingerprint,
+				}),
+			),
+		}
+		c.gauges[fingerprint] = eg
+	}
+	eg.refs++
+	return eg.gauge
+}
+
+// releaseGauge removes the expiry gauge of the certificate once no binding
+// uses it. It must be called while holding c.mu.
+func (c *CertificateMonitor) releaseGauge(cert *x509.Certificate) {
+	fingerprint := plumbing.CertificateFingerprint(cert)
+	eg, ok := c.gauges[fingerprint]
+	if !ok {
+		return
+	}
+	eg.refs--
+	if eg.refs <= 0 {
+		c.m.RemoveGauge(eg.gauge)
+		delete(c.gauges, fingerprint)
+	}
+}
+
+// Run checks the expiry of all tracked certificates every interval. Run
+// blocks.
+func (c *CertificateMonitor) Run() {
+	t := time.NewTicker(c.interval)
+	defer t.Stop()
+
+	for range t.C {
+		c.checkAll(time.Now())
+	}
+}
+
+func (c *CertificateMonitor) checkAll(now time.Time) {
+	c.mu.Lock()
+	certs := make(map[drainKey]*drainCertificate, len(c.certs))
+	for k, dc := range c.certs {
+		if egress.ContextDone(dc.ctx) {
+			c.releaseGauge(dc.cert)
+			delete(c.certs, k)
+			continue
+		}
+		certs[k] = dc
+	}
+	c.mu.Unlock()
+
+	for k, dc := range certs {
+		c.check(k.appID, dc, now)
+	}
+}
+
+func (c *CertificateMonitor) check(appID string, dc *drainCertificate, now time.Time) {
+	remaining := dc.cert.NotAfter.Sub(now)
+	dc.expiry.Set(remaining.Seconds())
+
+	if remaining > c.threshold {
+		return
+	}
+
+	c.mu.Lock()
+	if !dc.lastWarned.IsZero() && now.Sub(dc.lastWarned) < certWarningInterval {
+		c.mu.Unlock()
+		return
+	}
+	dc.lastWarned = now
+	c.mu.Unlock()
+
+	expiry := dc.cert.NotAfter.UTC().Format(time.RFC3339)
+	msg := fmt.Sprintf("Syslog drain %s client certificate expires at %s", dc.url, expiry)
+	if remaining <= 0 {
+		msg = fmt.Sprintf("Syslog drain %s client certificate expired at %s", dc.url, expiry)
+	}
+
+	if appID == "" {
+		log.Print(msg)
+		return
+	}
+	c.logClient.EmitLog(msg, loggregator.WithAppInfo(appID, "LGR", ""))
+	c.logClient.EmitLog(msg, loggregator.WithAppInfo(appID, "SYS", c.sourceIndex))
+}
+
+func parseCertificate(certPEM []byte) *x509.Certificate {
+	for {
+		var block *pem.Block
+		block, certPEM = pem.Decode(certPEM)
+		if block == nil {
+			return nil
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil
+		}
+		return cert
+	}
+}
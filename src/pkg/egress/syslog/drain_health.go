@@ -0,0 +1,490 @@
+package syslog
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"code.cloudfoundry.org/go-loggregator/v9"
+	metrics "code.cloudfoundry.org/go-metric-registry"
+	"code.cloudfoundry.org/loggregator-agent-release/src/pkg/egress"
+)
+
+// Error classes reported for failing or rejected drains.
+const (
+	ErrorClassDNS          = "dns"
+	ErrorClassTLSHandshake = "tls_handshake"
+	ErrorClassHTTPStatus   = "http_status"
+	ErrorClassTimeout      = "timeout"
+	ErrorClassConnection   = "connection"
+	ErrorClassInvalid      = "invalid"
+	ErrorClassBlacklisted  = "blacklisted"
+)
+
+// HTTPStatusError is returned by the HTTPS writer when a drain responds with
+// a non 2xx status code.
+type HTTPStatusError struct {
+	StatusCode int
+}
+
+func (e HTTPStatusError) Error() string {
+	return fmt.Sprintf("syslog Writer: Post responded with %d status code", e.StatusCode)
+}
+
+type healthMetricClient interface {
+	NewGauge(name, helpText string, o ...metrics.MetricOption) metrics.Gauge
+	NewCounter(name, helpText string, o ...metrics.MetricOption) metrics.Counter
+	RemoveGauge(g metrics.Gauge)
+	RemoveCounter(c metrics.Counter)
+}
+
+type drainKey struct {
+	appID string
+	url   string
+}
+
+// DrainHealth tracks the delivery health of syslog drain bindings. It
+// periodically reports a summary of each binding into the app's log stream.
+type DrainHealth struct {
+	m         healthMetricClient
+	logClient LogClient
+	interval  time.Duration
+
+	mu       sync.Mutex
+	statuses map[drainKey]*DrainStatus
+	rejected map[drainKey]string
+}
+
+// DrainHealthOption allows a DrainHealth to be customized.
+type DrainHealthOption func(*DrainHealth)
+
+// WithHealthLogClient returns a DrainHealthOption that will emit the health
+// summaries to the given log client.
+func WithHealthLogClient(logClient LogClient) DrainHealthOption {
+	return func(h *DrainHealth) {
+		h.logClient = logClient
+	}
+}
+
+// NewDrainHealth returns a DrainHealth that reports every interval.
+func NewDrainHealth(m healthMetricClient, interval time.Duration, opts ...DrainHealthOption) *DrainHealth {
+	h := &DrainHealth{
+		m:         m,
+		logClient: nullLogClient{},
+		interval:  interval,
+		statuses:  make(map[drainKey]*DrainStatus),
+		rejected:  make(map[drainKey]string),
+	}
+	for _, o := range opts {
+		o(h)
+	}
+	return h
+}
+
+// Status returns the DrainStatus for the given binding, creating it if it
+// does not exist yet. The status and its metrics are removed once the
+// binding's context is done.
+func (h *DrainHealth) Status(ub *URLBinding) *DrainStatus {
+	if h == nil {
+		return nil
+	}
+
+	k := drainKey{appID: ub.AppID, url: anonymizeURL(ub.URL)}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if s, ok := h.statuses[k]; ok {
+		if !egress.ContextDone(s.ctx) {
+			return s
+		}
+		// The metrics of the new status have the same labels.
+		s.removeMetrics()
+	}
+
+	drainScope := "app"
+	if ub.AppID == "" {
+		drainScope = "aggregate"
+	}
+	labels := map[string]string{
+		"drain_scope": drainScope,
+		"drain_url":   k.url,
+		"source_id":   ub.AppID,
+	}
+
+	ctx := ub.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	s := &DrainStatus{
+		ctx:    ctx,
+		url:    k.url,
+		labels: labels,
+		m:      h.m,
+		connected: h.m.NewGauge(
+			"drain_connected",
+			"Whether the last write to the syslog drain succeeded.",
+			metrics.WithMetricLabels(labels),
+		),
+		bytesSent: h.m.NewCounter(
+			"egress_bytes",
+			"Total number of bytes successfully egressed to the syslog drain.",
+			metrics.WithMetricLabels(labels),
+		),
+		errors: make(map[string]metrics.Counter),
+	}
+	h.statuses[k] = s
+
+	return s
+}
+
+// SetRejected replaces the set of bindings that were rejected during the
+// last binding fetch. The map is keyed by binding and holds the error class.
+func (h *DrainHealth) SetRejected(rejected map[Binding]string) {
+	if h == nil {
+		return
+	}
+
+	r := make(map[drainKey]string, len(rejected))
+	for b, class := range rejected {
+		r[drainKey{appID: b.AppId, url: AnonymizeDrainURL(b.Drain.Url)}] = class
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.rejected = r
+}
+
+// Run reports the health of all bindings every interval. Only bindings of
+// sources for which active returns true are reported. Run blocks forever and
+// returns immediately if the interval is not positive.
+func (h *DrainHealth) Run(active func(sourceID string) bool) {
+	if h.interval <= 0 {
+		return
+	}
+
+	t := time.NewTicker(h.interval)
+	for range t.C {
+		h.report(active)
+	}
+}
+
+// report emits the health of the bindings of active sources. active is
+// called without holding h.mu because it may take locks that are held while
+// Status is called.
+func (h *DrainHealth) report(active func(sourceID string) bool) {
+	type statusEntry struct {
+		appID  string
+		status *DrainStatus
+	}
+
+	h.mu.Lock()
+	statuses := make([]statusEntry, 0, len(h.statuses))
+	for k, s := range h.statuses {
+		if egress.ContextDone(s.ctx) {
+			s.removeMetrics()
+			delete(h.statuses, k)
+			continue
+		}
+		statuses = append(statuses, statusEntry{appID: k.appID, status: s})
+	}
+	rejected := make(map[drainKey]string, len(h.rejected))
+	for k, class := range h.rejected {
+		rejected[k] = class
+	}
+	h.mu.Unlock()
+
+	var msgs []healthMessage
+	for _, e := range statuses {
+		if e.appID == "" || !active(e.appID) {
+			continue
+		}
+		msgs = append(msgs, healthMessage{appID: e.appID, msg: e.status.summary()})
+	}
+	for k, class := range rejected {
+		if k.appID == "" || !active(k.appID) {
+			continue
+		}
+		msgs = append(msgs, healthMessage{
+			appID: k.appID,
+			msg:   fmt.Sprintf("Syslog drain %s was rejected: state=%s", displayURL(k.url), class),
+		})
+	}
+
+	sort.Slice(msgs, func(i, j int) bool { return msgs[i].msg < msgs[j].msg })
+	for _, m := range msgs {
+		h.logClient.EmitLog(m.msg, loggregator.WithAppInfo(m.appID, "LGR", ""))
+	}
+}
+
+// DrainReport is a point in time view of the health of a binding.
+type DrainReport struct {
+	AppID          string `json:"source_id"`
+	URL            string `json:"url"`
+	State          string `json:"state"`
+	LastError      string `json:"last_error,omitempty"`
+	MessagesSent   uint64 `json:"messages_sent"`
+	BytesSent      uint64 `json:"bytes_sent"`
+	Dropped        uint64 `json:"dropped"`
+	RetryAttempts  int    `json:"retry_attempts"`
+	BufferDepth    int    `json:"buffer_depth"`
+	BufferCapacity int    `json:"buffer_capacity"`
+}
+
+// Reports returns a DrainReport for every binding that is currently tracked.
+func (h *DrainHealth) Reports() []DrainReport {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	reports := make([]DrainReport, 0, len(h.statuses))
+	for k, s := range h.statuses {
+		if egress.ContextDone(s.ctx) {
+			continue
+		}
+		r := s.report()
+		r.AppID = k.appID
+		reports = append(reports, r)
+	}
+	for k, class := range h.rejected {
+		reports = append(reports, DrainReport{
+			AppID:     k.appID,
+			URL:       k.url,
+			State:     class,
+			LastError: class,
+		})
+	}
+
+	sort.Slice(reports, func(i, j int) bool {
+		if reports[i].AppID != reports[j].AppID {
+			return reports[i].AppID < reports[j].AppID
+		}
+		return reports[i].URL < reports[j].URL
+	})
+
+	return reports
+}
+
+type healthMessage struct {
+	appID string
+	msg   string
+}
+
+// DrainStatus holds the delivery health of a single binding. All methods are
+// safe to call on a nil DrainStatus.
+type DrainStatus struct {
+	ctx    context.Context
+	url    string
+	labels map[string]string
+	m      healthMetricClient
+
+	connected metrics.Gauge
+	bytesSent metrics.Counter
+
+	mu            sync.Mutex
+	state         string
+	lastError     string
+	messagesSent  uint64
+	bytes         uint64
+	dropped       uint64
+	retryAttempts int
+	buffer        buffer
+	errors        map[string]metrics.Counter
+}
+
+type buffer interface {
+	Len() int
+	Cap() int
+}
+
+// Sent records that a message of the given size was written to the drain.
+func (s *DrainStatus) Sent(n int) {
+	if s == nil {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.state = "connected"
+	s.retryAttempts = 0
+	s.messagesSent++
+	s.bytes += uint64(n)
+	s.connected.Set(1)
+	s.bytesSent.Add(float64(n))
+}
+
+// Failed records that a write to the drain failed.
+func (s *DrainStatus) Failed(err error) {
+	if s == nil {
+		return
+	}
+
+	class := ClassifyError(err)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.state = "failing"
+	s.lastError = class
+	s.retryAttempts++
+	s.connected.Set(0)
+
+	c, ok := s.errors[class]
+	if !ok {
+		labels := map[string]string{"error_class": class}
+		for k, v := range s.labels {
+			labels[k] = v
+		}
+		c = s.m.NewCounter(
+			"drain_errors",
+			"Total number of failed writes to the syslog drain by error class.",
+			metrics.WithMetricLabels(labels),
+		)
+		s.errors[class] = c
+	}
+	c.Add(1)
+}
+
+// removeMetrics unregisters the metrics of the status.
+func (s *DrainStatus) removeMetrics() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.m.RemoveGauge(s.connected)
+	s.m.RemoveCounter(s.bytesSent)
+	for _, c := range s.errors {
+		s.m.RemoveCounter(c)
+	}
+}
+
+// Dropped records that envelopes were dropped before they could be written
+// to the drain.
+func (s *DrainStatus) Dropped(n int) {
+	if s == nil {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.dropped += uint64(n)
+}
+
+// SetBuffer sets the buffer holding envelopes for the drain.
+func (s *DrainStatus) SetBuffer(b buffer) {
+	if s == nil {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.buffer = b
+}
+
+func (s *DrainStatus) report() DrainReport {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	state := s.state
+	if state == "" {
+		state = "pending"
+	}
+
+	r := DrainReport{
+		URL:           s.url,
+		State:         state,
+		LastError:     s.lastError,
+		MessagesSent:  s.messagesSent,
+		BytesSent:     s.bytes,
+		Dropped:       s.dropped,
+		RetryAttempts: s.retryAttempts,
+	}
+	if s.buffer != nil {
+		r.BufferDepth = s.buffer.Len()
+		r.BufferCapacity = s.buffer.Cap()
+	}
+
+	return r
+}
+
+func (s *DrainStatus) summary() string {
+	r := s.report()
+
+	lastError := r.LastError
+	if lastError == "" {
+		lastError = "none"
+	}
+
+	return fmt.Sprintf(
+		"Syslog drain %s health: state=%s last_error=%s messages_sent=%d bytes_sent=%d",
+		displayURL(r.URL), r.State, lastError, r.MessagesSent, r.BytesSent,
+	)
+}
+
+// ClassifyError returns the error class of an error returned by a syslog
+// writer.
+func ClassifyError(err error) string {
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) {
+		return ErrorClassDNS
+	}
+
+	var statusErr HTTPStatusError
+	if errors.As(err, &statusErr) {
+		return ErrorClassHTTPStatus
+	}
+
+	var verifyErr *tls.CertificateVerificationError
+	var recordErr tls.RecordHeaderError
+	var unknownAuthorityErr x509.UnknownAuthorityError
+	var hostnameErr x509.HostnameError
+	var certInvalidErr x509.CertificateInvalidError
+	if errors.As(err, &verifyErr) ||
+		errors.As(err, &recordErr) ||
+		errors.As(err, &unknownAuthorityErr) ||
+		errors.As(err, &hostnameErr) ||
+		errors.As(err, &certInvalidErr) ||
+		strings.Contains(err.Error(), "tls:") {
+		return ErrorClassTLSHandshake
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return ErrorClassTimeout
+	}
+
+	return ErrorClassConnection
+}
+
+// AnonymizeDrainURL removes the user info and query parameters, which may
+// hold credentials, from a drain URL. It returns an empty string if the URL
+// cannot be parsed.
+func AnonymizeDrainURL(drainURL string) string {
+	u, err := url.Parse(drainURL)
+	if err != nil {
+		return ""
+	}
+	return anonymizeURL(u)
+}
+
+func anonymizeURL(u *url.URL) string {
+	anonymousURL := *u
+	anonymousURL.User = nil
+	anonymousURL.RawQuery = ""
+	return anonymousURL.String()
+}
+
+func displayURL(u string) string {
+	if u == "" {
+		return "<unparsable url>"
+	}
+	return u
+}
@@ -3,7 +3,6 @@ package syslog
 import (
 	"crypto/tls"
 	"errors"
-	"fmt"
 	"net/url"
 	"strings"
 	"time"
@@ -21,6 +20,7 @@ type HTTPSWriter struct {
 	client          *fasthttp.Client
 	egressMetric    metrics.Counter
 	syslogConverter *Converter
+	health          *DrainStatus
 }
 
 func NewHTTPSWriter(
@@ -40,6 +40,7 @@ func NewHTTPSWriter(
 		client:          client,
 		egressMetric:    egressMetric,
 		syslogConverter: c,
+		health:          binding.Health,
 	}
 }
 
@@ -64,10 +65,11 @@ func (w *HTTPSWriter) Write(env *loggregator_v2.Envelope) error {
 		}
 
 		if resp.StatusCode() < 200 || resp.StatusCode() > 299 {
-			return fmt.Errorf("syslog Writer: Post responded with %d status code", resp.StatusCode())
+			return HTTPStatusError{StatusCode: resp.StatusCode()}
 		}
 
 		w.egressMetric.Add(1)
+		w.health.Sent(len(msg))
 	}
 
 	return nil
@@ -52,6 +52,7 @@ func (r *RetryWriter) Write(e *loggregator_v2.Envelope) error {
 		if egress.ContextDone(r.binding.Context) {
 			return err
 		}
+		r.binding.Health.Failed(err)
 
 		sleepDuration := r.retryDuration(i)
 		log.Printf(logTemplate, r.binding.URL.Host, sleepDuration, err)
@@ -12,13 +12,14 @@ import (
 	"code.cloudfoundry.org/go-loggregator/v9/rfc5424"
 )
 
-var findSpaces, findInvalidCharactersProcID, findInvalidCharactersHostname, findTrailingDashes *regexp.Regexp
+var findSpaces, findInvalidCharactersProcID, findInvalidCharactersHostname, findTrailingDashes, findInvalidCharactersSDName *regexp.Regexp
 
 func init() {
 	findSpaces = regexp.MustCompile(`\s+`)
 	findInvalidCharactersProcID = regexp.MustCompile("[^[:graph:]]+")
 	findInvalidCharactersHostname = regexp.MustCompile("[^-a-zA-Z0-9]+")
 	findTrailingDashes = regexp.MustCompile("-+$")
+	findInvalidCharactersSDName = regexp.MustCompile(`[^[:graph:]]|[="\]]`)
 }
 
 const RFC5424TimeOffsetNum = "2006-01-02T15:04:05.999999-07:00"
@@ -28,6 +29,7 @@ const RFC5424TimeOffsetNum = "2006-01-02T15:04:05.999999-07:00"
 // See: https://www.iana.org/assignments/enterprise-numbers/enterprise-numbers
 const (
 	gaugeStructuredDataID   = "gauge@47450"
+	unitStructuredDataID    = "unit@47450"
 	timerStructuredDataID   = "timer@47450"
 	counterStructuredDataID = "counter@47450"
 	eventStructuredDataID   = "event@47450"
@@ -42,8 +44,18 @@ func WithoutSyslogMetadata() ConverterOption {
 	}
 }
 
+// WithCombinedMetrics encodes each metric envelope as a single syslog
+// message. Gauges with multiple metrics carry all of them in one structured
+// data element and timers additionally carry their duration.
+func WithCombinedMetrics() ConverterOption {
+	return func(c *Converter) {
+		c.combineMetrics = true
+	}
+}
+
 type Converter struct {
-	omitTags bool
+	omitTags       bool
+	combineMetrics bool
 }
 
 func NewConverter(opts ...ConverterOption) *Converter {
@@ -117,6 +129,10 @@ func (c *Converter) toRFC5424CounterMessage(env *loggregator_v2.Envelope, hostna
 }
 
 func (c *Converter) toRFC5424GaugeMessage(env *loggregator_v2.Envelope, hostname, appID string) ([][]byte, error) {
+	if c.combineMetrics {
+		return c.toRFC5424CombinedGaugeMessage(env, hostname, appID)
+	}
+
 	gauges := make([][]byte, 0, 5)
 
 	for name, g := range env.GetGauge().GetMetrics() {
@@ -131,9 +147,60 @@ func (c *Converter) toRFC5424GaugeMessage(env *loggregator_v2.Envelope, hostname
 	return gauges, nil
 }
 
+// toRFC5424CombinedGaugeMessage encodes every metric of the gauge as a
+// <name>="<value>" parameter of the gauge structured data element and its
+// unit as a <name>="<unit>" parameter of the unit structured data element.
+// The parameters are sorted by metric name and characters that are not
+// allowed in parameter names are replaced by underscores.
+func (c *Converter) toRFC5424CombinedGaugeMessage(env *loggregator_v2.Envelope, hostname, appID string) ([][]byte, error) {
+	metrics := env.GetGauge().GetMetrics()
+	names := make([]string, 0, len(metrics))
+	for name := range metrics {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	values := make([]rfc5424.SDParam, 0, len(names))
+	var units []rfc5424.SDParam
+	for _, name := range names {
+		g := metrics[name]
+		sdName := c.sanitizeSDName(name)
+		values = append(values, rfc5424.SDParam{Name: sdName, Value: strconv.FormatFloat(g.GetValue(), 'g', -1, 64)})
+		if g.GetUnit() != "" {
+			units = append(units, rfc5424.SDParam{Name: sdName, Value: g.GetUnit()})
+		}
+	}
+
+	sds := []rfc5424.StructuredData{{ID: gaugeStructuredDataID, Parameters: values}}
+	if len(units) > 0 {
+		sds = append(sds, rfc5424.StructuredData{ID: unitStructuredDataID, Parameters: units})
+	}
+	messageBinary, err := c.toRFC5424MetricMessage(env, hostname, appID, sds...)
+	return [][]byte{messageBinary}, err
+}
+
+func (c *Converter) sanitizeSDName(name string) string {
+	if name == "" {
+		return "_"
+	}
+	return findInvalidCharactersSDName.ReplaceAllString(name, "_")
+}
+
 func (c *Converter) toRFC5424TimerMessage(env *loggregator_v2.Envelope, hostname, appID string) ([][]byte, error) {
 	timer := env.GetTimer()
 	sd := rfc5424.StructuredData{ID: timerStructuredDataID, Parameters: []rfc5424.SDParam{{Name: "name", Value: timer.GetName()}, {Name: "start", Value: strconv.FormatInt(timer.GetStart(), 10)}, {Name: "stop", Value: strconv.FormatInt(timer.GetStop(), 10)}}}
+	if c.combineMetrics {
+		sd.Parameters = append(sd.Parameters, rfc5424.SDParam{Name: "duration", Value: strconv.FormatInt(timer.GetStop()-timer.GetStart(), 10)})
+		// The trace tags are part of the tags structured data element
+		// unless it is omitted.
+		if c.omitTags {
+			for _, k := range []string{"trace_id", "span_id"} {
+				if v, ok := env.GetTags()[k]; ok {
+					sd.Parameters = append(sd.Parameters, rfc5424.SDParam{Name: k, Value: v})
+				}
+			}
+		}
+	}
 	messageBinary, err := c.toRFC5424MetricMessage(env, hostname, appID, sd)
 	return [][]byte{messageBinary}, err
 }
@@ -201,13 +268,13 @@ func (c *Converter) buildTagsStructuredData(tags map[string]string) rfc5424.Stru
 	return rfc5424.StructuredData{ID: tagsStructuredDataID, Parameters: tagsData}
 }
 
-func (c *Converter) toRFC5424MetricMessage(env *loggregator_v2.Envelope, hostname, appID string, structuredData rfc5424.StructuredData) ([]byte, error) {
+func (c *Converter) toRFC5424MetricMessage(env *loggregator_v2.Envelope, hostname, appID string, structuredData ...rfc5424.StructuredData) ([]byte, error) {
 	ts := time.Unix(0, env.GetTimestamp()).UTC()
 	hostname = c.nilify(hostname)
 	appID = c.nilify(appID)
 	pid := "[" + env.InstanceId + "]"
 	priority := 14
-	structuredDatas := []rfc5424.StructuredData{structuredData}
+	structuredDatas := structuredData
 	baseSD := c.buildTagsStructuredData(env.GetTags())
 	if baseSD.ID != "" {
 		structuredDatas = append(structuredDatas, baseSD)
@@ -219,7 +286,7 @@ func (c *Converter) toRFC5424MetricMessage(env *loggregator_v2.Envelope, hostnam
 		AppName:        appID,
 		ProcessID:      pid,
 		Message:        []byte(""),
-		StructuredData: structuredDatas, //TODO: Fix this to get both structured datas
+		StructuredData: structuredDatas,
 	}
 	messageBinary, err := message.MarshalBinary()
 	return append(messageBinary, []byte(" \n")...), err
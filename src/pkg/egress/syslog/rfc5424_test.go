@@ -126,6 +126,66 @@ var _ = Describe("RFC5424", func() {
 		expectConversion(receivedMsgs, `<14>1 1970-01-01T00:00:00.012345+00:00 test-hostname test-app-id [1] - [counter@47450 name="some-counter" total="99" delta="1"] `+"\n")
 	})
 
+	Context("with combined metrics", func() {
+		BeforeEach(func() {
+			c = syslog.NewConverter(syslog.WithCombinedMetrics())
+		})
+
+		It("converts a gauge envelope to a single message with all metrics", func() {
+			env := buildGaugeEnvelope("1")
+
+			Expect(c.ToRFC5424(env, "test-hostname")).To(Equal([][]byte{
+				[]byte(`<14>1 1970-01-01T00:00:00.012345+00:00 test-hostname test-app-id [1] - [gauge@47450 cpu="0.23" disk="1234" disk_quota="1024" memory="5423" memory_quota="8000"][unit@47450 cpu="percentage" disk="bytes" disk_quota="bytes" memory="bytes" memory_quota="bytes"] ` + "\n"),
+			}))
+		})
+
+		It("replaces characters that are not allowed in parameter names", func() {
+			env := &loggregator_v2.Envelope{
+				SourceId:   "test-app-id",
+				InstanceId: "1",
+				Timestamp:  12345000,
+				Message: &loggregator_v2.Envelope_Gauge{
+					Gauge: &loggregator_v2.Gauge{
+						Metrics: map[string]*loggregator_v2.GaugeValue{
+							`request count="total"]`: {Value: 1},
+						},
+					},
+				},
+			}
+
+			Expect(c.ToRFC5424(env, "test-hostname")).To(Equal([][]byte{
+				[]byte(`<14>1 1970-01-01T00:00:00.012345+00:00 test-hostname test-app-id [1] - [gauge@47450 request_count__total__="1"] ` + "\n"),
+			}))
+		})
+
+		It("adds the duration to a timer envelope", func() {
+			env := buildTimerEnvelope("1")
+			env.Tags = map[string]string{"trace_id": "some-trace", "span_id": "some-span"}
+
+			receivedMsgs, err := c.ToRFC5424(env, "test-hostname")
+			Expect(err).ToNot(HaveOccurred())
+			expectConversion(receivedMsgs, `<14>1 1970-01-01T00:00:00.012345+00:00 test-hostname test-app-id [1] - [timer@47450 name="http" start="10" stop="20" duration="10"][tags@47450 span_id="some-span" trace_id="some-trace"] `+"\n")
+		})
+
+		It("adds the trace tags to a timer envelope without metadata", func() {
+			c = syslog.NewConverter(syslog.WithCombinedMetrics(), syslog.WithoutSyslogMetadata())
+			env := buildTimerEnvelope("1")
+			env.Tags = map[string]string{"trace_id": "some-trace", "span_id": "some-span"}
+
+			receivedMsgs, err := c.ToRFC5424(env, "test-hostname")
+			Expect(err).ToNot(HaveOccurred())
+			expectConversion(receivedMsgs, `<14>1 1970-01-01T00:00:00.012345+00:00 test-hostname test-app-id [1] - [timer@47450 name="http" start="10" stop="20" duration="10" trace_id="some-trace" span_id="some-span"] `+"\n")
+		})
+
+		It("does not change counter envelopes", func() {
+			env := buildCounterEnvelope("1")
+
+			Expect(c.ToRFC5424(env, "test-hostname")).To(Equal([][]byte{
+				[]byte("<14>1 1970-01-01T00:00:00.012345+00:00 test-hostname test-app-id [1] - [counter@47450 name=\"some-counter\" total=\"99\" delta=\"1\"] \n"),
+			}))
+		})
+	})
+
 	It("builds hostname from org, space, and app name tags", func() {
 		logEnv := buildLogEnvelope("MY TASK", "2", "just a test", loggregator_v2.Log_ERR)
 		logEnv.Tags["organization_name"] = "some-org"
@@ -14,12 +14,13 @@ import (
 )
 
 type Binding struct {
-	AppId        string    `json:"appId,omitempty"`
-	Hostname     string    `json:"hostname,omitempty"`
-	Drain        Drain     `json:"drain,omitempty"`
-	DrainData    DrainData `json:"type,omitempty"`
-	OmitMetadata bool
-	InternalTls  bool
+	AppId          string    `json:"appId,omitempty"`
+	Hostname       string    `json:"hostname,omitempty"`
+	Drain          Drain     `json:"drain,omitempty"`
+	DrainData      DrainData `json:"type,omitempty"`
+	OmitMetadata   bool
+	CombineMetrics bool
+	InternalTls    bool
 }
 
 type Drain struct {
@@ -56,6 +57,8 @@ type SyslogConnector struct {
 	wg             egress.WaitGroup
 	sourceIndex    string
 	writerFactory  writerFactory
+	health         *DrainHealth
+	certs          *CertificateMonitor
 
 	metricClient  metricClient
 	droppedMetric metrics.Counter
@@ -102,6 +105,22 @@ func WithLogClient(logClient LogClient, sourceIndex string) ConnectorOption {
 	}
 }
 
+// WithDrainHealth returns a ConnectorOption that will track the delivery
+// health of every connected binding.
+func WithDrainHealth(h *DrainHealth) ConnectorOption {
+	return func(sc *SyslogConnector) {
+		sc.health = h
+	}
+}
+
+// WithCertificateMonitor returns a ConnectorOption that will track the
+// expiry of the client certificate of every connected binding.
+func WithCertificateMonitor(c *CertificateMonitor) ConnectorOption {
+	return func(sc *SyslogConnector) {
+		sc.certs = c
+	}
+}
+
 // Connect returns an egress writer based on the scheme of the binding drain
 // URL.
 func (w *SyslogConnector) Connect(ctx context.Context, b Binding) (egress.Writer, error) {
@@ -109,11 +128,13 @@ func (w *SyslogConnector) Connect(ctx context.Context, b Binding) (egress.Writer
 	if err != nil {
 		return nil, err
 	}
+	urlBinding.Health = w.health.Status(urlBinding)
 
 	writer, err := w.writerFactory.NewWriter(urlBinding)
 	if err != nil {
 		return nil, err
 	}
+	w.certs.Track(urlBinding)
 
 	anonymousUrl := *urlBinding.URL
 	anonymousUrl.User = nil
@@ -137,10 +158,12 @@ func (w *SyslogConnector) Connect(ctx context.Context, b Binding) (egress.Writer
 	dw := egress.NewDiodeWriter(ctx, writer, diodes.AlertFunc(func(missed int) {
 		w.droppedMetric.Add(float64(missed))
 		drainDroppedMetric.Add(float64(missed))
+		urlBinding.Health.Dropped(missed)
 
 		w.emitLoggregatorErrorLog(b.AppId, fmt.Sprintf("%d messages lost for application %s in user provided syslog drain with url %s", missed, b.AppId, anonymousUrl.String()))
 		w.emitStandardOutErrorLog(b.AppId, urlBinding.Scheme(), anonymousUrl.String(), missed)
 	}), w.wg)
+	urlBinding.Health.SetBuffer(dw)
 
 	filteredWriter, err := NewFilteringDrainWriter(b, dw)
 	if err != nil {
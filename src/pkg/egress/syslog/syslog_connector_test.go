@@ -1,6 +1,8 @@
 package syslog_test
 
 import (
+	"crypto/x509"
+	"encoding/pem"
 	"errors"
 	"fmt"
 	"io"
@@ -13,6 +15,8 @@ import (
 	metricsHelpers "code.cloudfoundry.org/go-metric-registry/testhelpers"
 	"code.cloudfoundry.org/loggregator-agent-release/src/pkg/egress"
 	"code.cloudfoundry.org/loggregator-agent-release/src/pkg/egress/syslog"
+	"code.cloudfoundry.org/loggregator-agent-release/src/pkg/plumbing"
+	"code.cloudfoundry.org/tlsconfig/certtest"
 
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
@@ -78,6 +82,45 @@ var _ = Describe("SyslogConnector", func() {
 		Expect(err).ToNot(HaveOccurred())
 	})
 
+	It("tracks the client certificate of the binding", func() {
+		writerFactory.writer = &SleepWriterCloser{metric: func(uint64) {}}
+		connector := syslog.NewSyslogConnector(
+			true,
+			spyWaitGroup,
+			writerFactory,
+			sm,
+			syslog.WithCertificateMonitor(syslog.NewCertificateMonitor(sm, time.Hour)),
+		)
+
+		ca, err := certtest.BuildCA("ca")
+		Expect(err).ToNot(HaveOccurred())
+		cert, err := ca.BuildSignedCertificate("drain-client")
+		Expect(err).ToNot(HaveOccurred())
+		certPEM, keyPEM, err := cert.CertificatePEMAndPrivateKey()
+		Expect(err).ToNot(HaveOccurred())
+
+		binding := syslog.Binding{
+			AppId: "app-id",
+			Drain: syslog.Drain{
+				Url: "syslog-tls://drain.example.com:6514",
+				Credentials: syslog.Credentials{
+					Cert: string(certPEM),
+					Key:  string(keyPEM),
+				},
+			},
+		}
+		_, err = connector.Connect(ctx, binding)
+		Expect(err).ToNot(HaveOccurred())
+		block, _ := pem.Decode(certPEM)
+		leaf, err := x509.ParseCertificate(block.Bytes)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(sm.HasMetric("cert_expiry_seconds", map[string]string{
+			"purpose":     "drain_client",
+			"subject":     "drain-client",
+			"fingerprint": plumbing.CertificateFingerprint(leaf),
+		})).To(BeTrue())
+	})
+
 	It("returns an error when the writer factory returns an error", func() {
 		writerFactory.err = errors.New("unsupported protocol")
 		connector := syslog.NewSyslogConnector(
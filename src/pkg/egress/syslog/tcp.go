@@ -30,6 +30,7 @@ type TCPWriter struct {
 	scheme          string
 	conn            net.Conn
 	syslogConverter *Converter
+	health          *DrainStatus
 
 	egressMetric metrics.Counter
 }
@@ -58,6 +59,7 @@ func NewTCPWriter(
 		scheme:          "syslog",
 		egressMetric:    egressMetric,
 		syslogConverter: c,
+		health:          binding.Health,
 	}
 
 	return w
@@ -82,13 +84,14 @@ func (w *TCPWriter) Write(env *loggregator_v2.Envelope) error {
 			return err
 		}
 
-		_, err = conn.Write([]byte(strconv.Itoa(len(msg)) + " " + string(msg)))
+		n, err := conn.Write([]byte(strconv.Itoa(len(msg)) + " " + string(msg)))
 		if err != nil {
 			_ = w.Close()
 			return err
 		}
 
 		w.egressMetric.Add(1)
+		w.health.Sent(n)
 	}
 
 	return nil
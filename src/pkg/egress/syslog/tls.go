@@ -48,6 +48,7 @@ func NewTLSWriter(
 			scheme:          "syslog-tls",
 			egressMetric:    egressMetric,
 			syslogConverter: syslogConverter,
+			health:          binding.Health,
 		},
 	}
 
@@ -9,15 +9,17 @@ import (
 // application is identified by AppID and Hostname. The syslog URL is
 // identified by URL.
 type URLBinding struct {
-	Context      context.Context
-	AppID        string
-	Hostname     string
-	OmitMetadata bool
-	InternalTls  bool
-	URL          *url.URL
-	PrivateKey   []byte
-	Certificate  []byte
-	CA           []byte
+	Context        context.Context
+	AppID          string
+	Hostname       string
+	OmitMetadata   bool
+	CombineMetrics bool
+	InternalTls    bool
+	URL            *url.URL
+	PrivateKey     []byte
+	Certificate    []byte
+	CA             []byte
+	Health         *DrainStatus
 }
 
 // Scheme is a convenience wrapper around the *url.URL Scheme field
@@ -32,15 +34,16 @@ func buildBinding(c context.Context, b Binding) (*URLBinding, error) {
 	}
 
 	u := &URLBinding{
-		AppID:        b.AppId,
-		OmitMetadata: b.OmitMetadata,
-		InternalTls:  b.InternalTls,
-		URL:          url,
-		Hostname:     b.Hostname,
-		Context:      c,
-		PrivateKey:   []byte(b.Drain.Credentials.Key),
-		Certificate:  []byte(b.Drain.Credentials.Cert),
-		CA:           []byte(b.Drain.Credentials.CA),
+		AppID:          b.AppId,
+		OmitMetadata:   b.OmitMetadata,
+		CombineMetrics: b.CombineMetrics,
+		InternalTls:    b.InternalTls,
+		URL:            url,
+		Hostname:       b.Hostname,
+		Context:        c,
+		PrivateKey:     []byte(b.Drain.Credentials.Key),
+		Certificate:    []byte(b.Drain.Credentials.Cert),
+		CA:             []byte(b.Drain.Credentials.CA),
 	}
 
 	return u, nil
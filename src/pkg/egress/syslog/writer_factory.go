@@ -2,6 +2,7 @@ package syslog
 
 import (
 	"crypto/tls"
+	"crypto/x509"
 	"fmt"
 	"net/url"
 
@@ -36,20 +37,48 @@ func (e WriterFactoryError) Error() string {
 	return fmt.Sprintf("%q: %s", e.anonymizedURL(), e.Message)
 }
 
+// CertPoolProvider provides the current pool of trusted CAs.
+type CertPoolProvider interface {
+	CertPool() *x509.CertPool
+}
+
 type WriterFactory struct {
 	internalTlsConfig *tls.Config
 	externalTlsConfig *tls.Config
 	netConf           NetworkTimeoutConfig
 	m                 metricClient
+	certPool          CertPoolProvider
 }
 
-func NewWriterFactory(internalTlsConfig *tls.Config, externalTlsConfig *tls.Config, netConf NetworkTimeoutConfig, m metricClient) WriterFactory {
-	return WriterFactory{
+// WriterFactoryOption allows a WriterFactory to be customized.
+type WriterFactoryOption func(*WriterFactory)
+
+// WithCertPoolProvider configures the WriterFactory to trust the CAs of the
+// given provider. The pool is read whenever a writer is created so that
+// reloaded CAs are used for new connections.
+func WithCertPoolProvider(p CertPoolProvider) WriterFactoryOption {
+	return func(f *WriterFactory) {
+		f.certPool = p
+	}
+}
+
+func NewWriterFactory(
+	internalTlsConfig *tls.Config,
+	externalTlsConfig *tls.Config,
+	netConf NetworkTimeoutConfig,
+	m metricClient,
+	opts ...WriterFactoryOption,
+) WriterFactory {
+	f := WriterFactory{
 		internalTlsConfig: internalTlsConfig,
 		externalTlsConfig: externalTlsConfig,
 		netConf:           netConf,
 		m:                 m,
 	}
+	for _, o := range opts {
+		o(&f)
+	}
+	return f
 }
 
 func (f WriterFactory) NewWriter(ub *URLBinding) (egress.WriteCloser, error) {
@@ -57,6 +86,9 @@ func (f WriterFactory) NewWriter(ub *URLBinding) (egress.WriteCloser, error) {
 	if ub.InternalTls {
 		tlsCfg = f.internalTlsConfig.Clone()
 	}
+	if f.certPool != nil {
+		tlsCfg.RootCAs = f.certPool.CertPool().Clone()
+	}
 	if len(ub.Certificate) > 0 && len(ub.PrivateKey) > 0 {
 		cert, err := tls.X509KeyPair(ub.Certificate, ub.PrivateKey)
 		if err != nil {
@@ -94,6 +126,9 @@ func (f WriterFactory) NewWriter(ub *URLBinding) (egress.WriteCloser, error) {
 	if ub.OmitMetadata {
 		o = append(o, WithoutSyslogMetadata())
 	}
+	if ub.CombineMetrics {
+		o = append(o, WithCombinedMetrics())
+	}
 	converter := NewConverter(o...)
 
 	var w egress.WriteCloser
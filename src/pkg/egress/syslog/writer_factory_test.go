@@ -2,6 +2,7 @@ package syslog_test
 
 import (
 	"crypto/tls"
+	"crypto/x509"
 	"net/url"
 
 	. "github.com/onsi/ginkgo/v2"
@@ -9,6 +10,7 @@ import (
 
 	metricsHelpers "code.cloudfoundry.org/go-metric-registry/testhelpers"
 	"code.cloudfoundry.org/loggregator-agent-release/src/pkg/egress/syslog"
+	"code.cloudfoundry.org/tlsconfig/certtest"
 )
 
 var _ = Describe("EgressFactory", func() {
@@ -107,6 +109,34 @@ var _ = Describe("EgressFactory", func() {
 		})
 	})
 
+	Context("with a cert pool provider", func() {
+		It("does not modify the provided pool when adding a binding CA", func() {
+			pool := x509.NewCertPool()
+			f = syslog.NewWriterFactory(
+				&tls.Config{}, //nolint:gosec
+				&tls.Config{}, //nolint:gosec
+				syslog.NetworkTimeoutConfig{},
+				sm,
+				syslog.WithCertPoolProvider(stubCertPoolProvider{pool: pool}),
+			)
+
+			ca, err := certtest.BuildCA("binding-ca")
+			Expect(err).ToNot(HaveOccurred())
+			caPEM, err := ca.CertificatePEM()
+			Expect(err).ToNot(HaveOccurred())
+
+			url, err := url.Parse("syslog-tls://syslog.example.com")
+			Expect(err).ToNot(HaveOccurred())
+			_, err = f.NewWriter(&syslog.URLBinding{
+				URL: url,
+				CA:  caPEM,
+			})
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(pool.Equal(x509.NewCertPool())).To(BeTrue())
+		})
+	})
+
 	DescribeTable("Errors",
 		func(u string, certFail bool, caFail bool, expectedErr string) {
 			url, err := url.Parse(u)
@@ -164,3 +194,11 @@ var _ = Describe("EgressFactory", func() {
 		Entry("For syslog-tls app drain", "syslog-tls://syslog.example.com", false),
 	)
 })
+
+type stubCertPoolProvider struct {
+	pool *x509.CertPool
+}
+
+func (p stubCertPoolProvider) CertPool() *x509.CertPool {
+	return p.pool
+}
@@ -20,6 +20,23 @@ func (w Client) Get(nextID int) (*http.Response, error) {
 	return w.Client.Get(fmt.Sprintf(pathTemplate, w.Addr, w.BatchSize, nextID))
 }
 
+// ConditionalGet is like Get but sends the given ETag and Last-Modified
+// values of a previous response as If-None-Match and If-Modified-Since
+// headers. The response is 304 Not Modified if the page did not change.
+func (w Client) ConditionalGet(nextID int, etag, lastModified string) (*http.Response, error) {
+	req, err := http.NewRequest(http.MethodGet, fmt.Sprintf(pathTemplate, w.Addr, w.BatchSize, nextID), nil)
+	if err != nil {
+		return nil, err
+	}
+	if etag != "" {
+		req.Header.Set("If-None-Match", etag)
+	}
+	if lastModified != "" {
+		req.Header.Set("If-Modified-Since", lastModified)
+	}
+	return w.Client.Do(req)
+}
+
 func (w Client) LegacyGet(nextID int) (*http.Response, error) {
 	return w.Client.Get(fmt.Sprintf(legacyPathTemplate, w.Addr, w.BatchSize, nextID))
 }
@@ -2,6 +2,9 @@ package bindings
 
 import (
 	"errors"
+	"fmt"
+
+	metrics "code.cloudfoundry.org/go-metric-registry"
 
 	"code.cloudfoundry.org/loggregator-agent-release/src/pkg/binding"
 	"code.cloudfoundry.org/loggregator-agent-release/src/pkg/egress/syslog"
@@ -13,12 +16,31 @@ type CacheFetcher interface {
 }
 
 type AggregateDrainFetcher struct {
-	bindings []syslog.Binding
-	cf       CacheFetcher
+	bindings      []syslog.Binding
+	cf            CacheFetcher
+	compatibility binding.Compatibility
+	fallbacks     metrics.Counter
 }
 
-func NewAggregateDrainFetcher(bindings []string, cf CacheFetcher) *AggregateDrainFetcher {
+// AggregateDrainFetcherOption allows an AggregateDrainFetcher to be
+// customized.
+type AggregateDrainFetcherOption func(*AggregateDrainFetcher)
+
+// WithAggregateCompatibility sets the binding API versions the
+// AggregateDrainFetcher uses and counts the fallbacks to older versions. By
+// default only the current version is used.
+func WithAggregateCompatibility(c binding.Compatibility, m Metrics) AggregateDrainFetcherOption {
+	return func(a *AggregateDrainFetcher) {
+		a.compatibility = c
+		a.fallbacks = newFallbackCounter(m, c, "aggregate")
+	}
+}
+
+func NewAggregateDrainFetcher(bindings []string, cf CacheFetcher, opts ...AggregateDrainFetcherOption) *AggregateDrainFetcher {
 	drainFetcher := &AggregateDrainFetcher{cf: cf}
+	for _, o := range opts {
+		o(drainFetcher)
+	}
 	parsedDrains := constructLegacyBindings(bindings)
 	drainFetcher.bindings = parsedDrains
 	return drainFetcher
@@ -30,9 +52,26 @@ func (a *AggregateDrainFetcher) FetchBindings() ([]syslog.Binding, error) {
 		bindings = append(bindings, a.bindings...)
 		return bindings, nil
 	} else if a.cf != nil {
+		versions := a.compatibility.Versions()
+		for i, v := range versions {
+			bindings, err := a.fetch(v)
+			if err == nil || i == len(versions)-1 {
+				return bindings, err
+			}
+			a.fallbacks.Add(1)
+		}
+		return nil, errors.New("no binding API version configured")
+	} else {
+		return []syslog.Binding{}, nil
+	}
+}
+
+func (a *AggregateDrainFetcher) fetch(v binding.APIVersion) ([]syslog.Binding, error) {
+	switch v {
+	case binding.APIVersionV2:
 		aggregate, err := a.cf.GetAggregate()
 		if err != nil {
-			return a.FetchBindingsLegacyFallback()
+			return []syslog.Binding{}, err
 		}
 		syslogBindings := []syslog.Binding{}
 		for _, i := range aggregate {
@@ -53,24 +92,22 @@ func (a *AggregateDrainFetcher) FetchBindings() ([]syslog.Binding, error) {
 			syslogBindings = append(syslogBindings, b)
 		}
 		return syslogBindings, nil
-	} else {
-		return []syslog.Binding{}, nil
-	}
-}
-
-func (a *AggregateDrainFetcher) FetchBindingsLegacyFallback() ([]syslog.Binding, error) {
-	aggregateLegacy, err := a.cf.GetLegacyAggregate()
-	if err != nil {
-		return []syslog.Binding{}, err
-	}
-	syslogBindings := []syslog.Binding{}
-	for _, i := range aggregateLegacy {
-		if i.V2Available {
-			return nil, errors.New("v2 is available")
+	case binding.APIVersionLegacy:
+		aggregateLegacy, err := a.cf.GetLegacyAggregate()
+		if err != nil {
+			return []syslog.Binding{}, err
+		}
+		syslogBindings := []syslog.Binding{}
+		for _, i := range aggregateLegacy {
+			if i.V2Available {
+				return nil, errors.New("v2 is available")
+			}
+			syslogBindings = append(syslogBindings, constructLegacyBindings(i.Drains)...)
 		}
-		syslogBindings = append(syslogBindings, constructLegacyBindings(i.Drains)...)
+		return syslogBindings, nil
+	default:
+		return nil, fmt.Errorf("unsupported binding API version %q", v)
 	}
-	return syslogBindings, nil
 }
 
 func constructLegacyBindings(urls []string) []syslog.Binding {
@@ -3,6 +3,7 @@ package bindings_test
 import (
 	"errors"
 
+	metricsHelpers "code.cloudfoundry.org/go-metric-registry/testhelpers"
 	"code.cloudfoundry.org/loggregator-agent-release/src/pkg/binding"
 	"code.cloudfoundry.org/loggregator-agent-release/src/pkg/egress/syslog"
 	"code.cloudfoundry.org/loggregator-agent-release/src/pkg/ingress/bindings"
@@ -11,9 +12,10 @@ import (
 )
 
 var _ = Describe("Aggregate Drain Binding Fetcher", func() {
-	var ()
+	var metrics *metricsHelpers.SpyMetricsRegistry
 
 	BeforeEach(func() {
+		metrics = metricsHelpers.NewMetricsRegistry()
 	})
 
 	Context("cache fetcher is nil", func() {
@@ -161,7 +163,9 @@ var _ = Describe("Aggregate Drain Binding Fetcher", func() {
 				}}},
 				err: errors.New("error"),
 			}
-			fetcher := bindings.NewAggregateDrainFetcher(bs, &cacheFetcher)
+			fetcher := bindings.NewAggregateDrainFetcher(bs, &cacheFetcher,
+				bindings.WithAggregateCompatibility(binding.CompatibilityLegacyFallback, metrics),
+			)
 
 			b, err := fetcher.FetchBindings()
 			Expect(err).ToNot(HaveOccurred())
@@ -176,11 +180,28 @@ var _ = Describe("Aggregate Drain Binding Fetcher", func() {
 					Drain: syslog.Drain{Url: "syslog://aggregate-drain2.url.com"},
 				},
 			))
+			Expect(metrics.GetMetricValue("binding_api_fallback", map[string]string{
+				"version":  "legacy",
+				"endpoint": "aggregate",
+			})).To(Equal(1.0))
+		})
+		It("does not fall back to the legacy cache by default", func() {
+			bs := []string{""}
+			cacheFetcher := mockCacheFetcher{
+				legacyBindings: []binding.LegacyBinding{{Drains: []string{"syslog://aggregate-drain1.url.com"}}},
+				err:            errors.New("error"),
+			}
+			fetcher := bindings.NewAggregateDrainFetcher(bs, &cacheFetcher)
+
+			_, err := fetcher.FetchBindings()
+			Expect(err).To(MatchError("error"))
 		})
 		It("returns error if fetching fails", func() {
 			bs := []string{""}
 			cacheFetcher := mockCacheFetcher{legacyErr: errors.New("error2"), err: errors.New("error")}
-			fetcher := bindings.NewAggregateDrainFetcher(bs, &cacheFetcher)
+			fetcher := bindings.NewAggregateDrainFetcher(bs, &cacheFetcher,
+				bindings.WithAggregateCompatibility(binding.CompatibilityLegacyFallback, metrics),
+			)
 
 			_, err := fetcher.FetchBindings()
 			Expect(err).To(MatchError("error2"))
@@ -191,7 +212,9 @@ var _ = Describe("Aggregate Drain Binding Fetcher", func() {
 				legacyBindings: []binding.LegacyBinding{{V2Available: true, Drains: []string{"syslog://aggregate-drain1.url.com"}}},
 				err:            errors.New("error"),
 			}
-			fetcher := bindings.NewAggregateDrainFetcher(bs, &cacheFetcher)
+			fetcher := bindings.NewAggregateDrainFetcher(bs, &cacheFetcher,
+				bindings.WithAggregateCompatibility(binding.CompatibilityLegacyFallback, metrics),
+			)
 
 			_, err := fetcher.FetchBindings()
 			Expect(err).To(MatchError("v2 is available"))
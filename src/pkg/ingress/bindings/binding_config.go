@@ -33,6 +33,7 @@ func (d *DrainParamParser) FetchBindings() ([]syslog.Binding, error) {
 		}
 
 		b.OmitMetadata = getOmitMetadata(urlParsed, d.defaultDrainMetadata)
+		b.CombineMetrics = getCombineMetrics(urlParsed)
 		b.InternalTls = getInternalTLS(urlParsed)
 		b.DrainData = getBindingType(urlParsed)
 
@@ -46,6 +47,10 @@ func getInternalTLS(url *url.URL) bool {
 	return url.Query().Get("ssl-strict-internal") == "true"
 }
 
+func getCombineMetrics(url *url.URL) bool {
+	return url.Query().Get("combine-metrics") == "true"
+}
+
 func getOmitMetadata(url *url.URL, defaultDrainMetadata bool) bool {
 	if defaultDrainMetadata && getRemoveMetadataQuery(url) == "true" {
 		return true
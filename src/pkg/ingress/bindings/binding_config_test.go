@@ -69,6 +69,19 @@ var _ = Describe("Drain Param Config", func() {
 		Expect(configedBindings[0].InternalTls).To(BeTrue())
 	})
 
+	It("sets combine metrics to true if the drain contains 'combine-metrics=true'", func() {
+		bs := []syslog.Binding{
+			{Drain: syslog.Drain{Url: "https://test.org/drain?combine-metrics=true"}},
+			{Drain: syslog.Drain{Url: "https://test.org/drain"}},
+		}
+		f := newStubFetcher(bs, nil)
+		wf := bindings.NewDrainParamParser(f, true)
+
+		configedBindings, _ := wf.FetchBindings()
+		Expect(configedBindings[0].CombineMetrics).To(BeTrue())
+		Expect(configedBindings[1].CombineMetrics).To(BeFalse())
+	})
+
 	It("sets drain data appropriately'", func() {
 		bs := []syslog.Binding{
 			{Drain: syslog.Drain{Url: "https://test.org/drain"}},
@@ -2,6 +2,7 @@ package bindings
 
 import (
 	"errors"
+	"fmt"
 	"log"
 	"math"
 	"sort"
@@ -27,15 +28,28 @@ type Getter interface {
 
 // BindingFetcher uses a Getter to fetch and decode Bindings
 type BindingFetcher struct {
-	refreshCount metrics.Counter
-	maxLatency   metrics.Gauge
-	limit        int
-	getter       Getter
-	logger       *log.Logger
+	refreshCount  metrics.Counter
+	maxLatency    metrics.Gauge
+	fallbacks     metrics.Counter
+	limit         int
+	getter        Getter
+	logger        *log.Logger
+	compatibility binding.Compatibility
+}
+
+// BindingFetcherOption allows a BindingFetcher to be customized.
+type BindingFetcherOption func(*BindingFetcher)
+
+// WithCompatibility sets the binding API versions the BindingFetcher uses.
+// By default only the current version is used.
+func WithCompatibility(c binding.Compatibility) BindingFetcherOption {
+	return func(f *BindingFetcher) {
+		f.compatibility = c
+	}
 }
 
 // NewBindingFetcher returns a new BindingFetcher
-func NewBindingFetcher(limit int, g Getter, m Metrics, logger *log.Logger) *BindingFetcher {
+func NewBindingFetcher(limit int, g Getter, m Metrics, logger *log.Logger, opts ...BindingFetcherOption) *BindingFetcher {
 	refreshCount := m.NewCounter(
 		"binding_refresh_count",
 		"Total number of binding refresh attempts made to the binding provider.",
@@ -47,13 +61,19 @@ func NewBindingFetcher(limit int, g Getter, m Metrics, logger *log.Logger) *Bind
 		"Latency in milliseconds of the last binding fetch made to the binding provider.",
 		metrics.WithMetricLabels(map[string]string{"unit": "ms"}),
 	)
-	return &BindingFetcher{
+	f := &BindingFetcher{
 		limit:        limit,
 		getter:       g,
 		refreshCount: refreshCount,
 		maxLatency:   maxLatency,
 		logger:       logger,
 	}
+	for _, o := range opts {
+		o(f)
+	}
+
+	f.fallbacks = newFallbackCounter(m, f.compatibility, "bindings")
+	return f
 }
 
 // FetchBindings reaches out to the syslog drain binding provider via the Getter and decodes
@@ -66,11 +86,32 @@ func (f *BindingFetcher) FetchBindings() ([]syslog.Binding, error) {
 	}()
 
 	start := time.Now()
-	bindings, err := f.getter.Get()
-	if err != nil {
-		f.logger.Printf("fetching v2/bindings failed: %s . Falling back to /bindings endpoint", err)
-		var legacySyslogBindings []binding.LegacyBinding
-		legacySyslogBindings, err = f.getter.LegacyGet()
+	versions := f.compatibility.Versions()
+	for i, v := range versions {
+		bindings, err := f.fetch(v)
+		if err == nil {
+			latency = time.Since(start).Nanoseconds()
+			return bindings, nil
+		}
+		if i == len(versions)-1 {
+			return nil, err
+		}
+		f.logger.Printf("fetching %s bindings failed: %s . Falling back to %s bindings", v, err, versions[i+1])
+		f.fallbacks.Add(1)
+	}
+	return nil, errors.New("no binding API version configured")
+}
+
+func (f *BindingFetcher) fetch(v binding.APIVersion) ([]syslog.Binding, error) {
+	switch v {
+	case binding.APIVersionV2:
+		bindings, err := f.getter.Get()
+		if err != nil {
+			return nil, err
+		}
+		return f.toSyslogBindings(bindings, f.limit), nil
+	case binding.APIVersionLegacy:
+		legacySyslogBindings, err := f.getter.LegacyGet()
 		if err != nil {
 			return nil, err
 		}
@@ -80,11 +121,10 @@ func (f *BindingFetcher) FetchBindings() ([]syslog.Binding, error) {
 		if legacySyslogBindings[0].V2Available {
 			return nil, errors.New("legacy endpoint is deprecated: skipping result parsing")
 		}
-		latency = time.Since(start).Nanoseconds()
 		return f.legacyToSyslogBindings(legacySyslogBindings, f.limit), nil
+	default:
+		return nil, fmt.Errorf("unsupported binding API version %q", v)
 	}
-	latency = time.Since(start).Nanoseconds()
-	return f.toSyslogBindings(bindings, f.limit), nil
 }
 
 func (f *BindingFetcher) DrainLimit() int {
@@ -170,6 +210,24 @@ func (f *BindingFetcher) legacyToSyslogBindings(bs []binding.LegacyBinding, perA
 	return bindings
 }
 
+// newFallbackCounter returns the counter of fetches that fell back to an
+// older binding API version of the given endpoint. It returns nil if the
+// compatibility mode never falls back.
+func newFallbackCounter(m Metrics, c binding.Compatibility, endpoint string) metrics.Counter {
+	versions := c.Versions()
+	if len(versions) < 2 {
+		return nil
+	}
+	return m.NewCounter(
+		"binding_api_fallback",
+		"Total number of binding fetches that fell back to an older binding API version.",
+		metrics.WithMetricLabels(map[string]string{
+			"version":  string(versions[1]),
+			"endpoint": endpoint,
+		}),
+	)
+}
+
 // toMilliseconds truncates the calculated milliseconds float to microsecond
 // precision.
 func toMilliseconds(num int64) float64 {
@@ -145,7 +145,7 @@ var _ = Describe("BindingFetcher", func() {
 		getter.err = errors.New("getter error occurred")
 		getter.legacyBindings[0].V2Available = false
 		getter.legacyBindings[1].V2Available = false
-		fetcher = bindings.NewBindingFetcher(maxDrains, getter, metrics, logger)
+		fetcher = bindings.NewBindingFetcher(maxDrains, getter, metrics, logger, bindings.WithCompatibility(binding.CompatibilityLegacyFallback))
 		fetchedBindings, err := fetcher.FetchBindings()
 		Expect(err).ToNot(HaveOccurred())
 
@@ -188,7 +188,7 @@ var _ = Describe("BindingFetcher", func() {
 	It("returns an empty array of syslog bindings if v1 endpoint sends no results", func() {
 		getter.err = errors.New("getter error occurred")
 		getter.legacyBindings = []binding.LegacyBinding{}
-		fetcher = bindings.NewBindingFetcher(maxDrains, getter, metrics, logger)
+		fetcher = bindings.NewBindingFetcher(maxDrains, getter, metrics, logger, bindings.WithCompatibility(binding.CompatibilityLegacyFallback))
 		fetchedBindings, err := fetcher.FetchBindings()
 		Expect(err).To(Not(HaveOccurred()))
 
@@ -235,10 +235,34 @@ var _ = Describe("BindingFetcher", func() {
 		}))
 	})
 
+	It("does not fall back to the legacy endpoint by default", func() {
+		getter.err = errors.New("boom")
+		getter.legacyBindings[0].V2Available = false
+		getter.legacyBindings[1].V2Available = false
+
+		_, err := fetcher.FetchBindings()
+		Expect(err).To(MatchError("boom"))
+	})
+
+	It("counts the fallbacks to the legacy endpoint", func() {
+		getter.err = errors.New("boom")
+		getter.legacyBindings[0].V2Available = false
+		getter.legacyBindings[1].V2Available = false
+		fetcher = bindings.NewBindingFetcher(maxDrains, getter, metrics, logger, bindings.WithCompatibility(binding.CompatibilityLegacyFallback))
+
+		_, err := fetcher.FetchBindings()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(metrics.GetMetricValue("binding_api_fallback", map[string]string{
+			"version":  "legacy",
+			"endpoint": "bindings",
+		})).To(Equal(1.0))
+	})
+
 	It("returns an error if the Getter returns an error", func() {
 		getter.err = errors.New("boom")
 		getter.legacyError = errors.New("boom-legacy")
 
+		fetcher = bindings.NewBindingFetcher(maxDrains, getter, metrics, logger, bindings.WithCompatibility(binding.CompatibilityLegacyFallback))
 		_, err := fetcher.FetchBindings()
 		Expect(err).To(MatchError("boom-legacy"))
 	})
@@ -246,6 +270,7 @@ var _ = Describe("BindingFetcher", func() {
 	It("returns an error if the Getter returns an error and V2 is available", func() {
 		getter.err = errors.New("boom")
 
+		fetcher = bindings.NewBindingFetcher(maxDrains, getter, metrics, logger, bindings.WithCompatibility(binding.CompatibilityLegacyFallback))
 		_, err := fetcher.FetchBindings()
 		Expect(err).To(MatchError("legacy endpoint is deprecated: skipping result parsing"))
 	})
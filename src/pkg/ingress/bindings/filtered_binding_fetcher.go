@@ -23,6 +23,12 @@ type metricsClient interface {
 	NewGauge(name, helpText string, opts ...metrics.MetricOption) metrics.Gauge
 }
 
+// RejectionReporter is informed about the bindings rejected in the last
+// binding fetch along with the reason they were rejected.
+type RejectionReporter interface {
+	SetRejected(map[syslog.Binding]string)
+}
+
 type FilteredBindingFetcher struct {
 	ipChecker         IPChecker
 	br                binding.Fetcher
@@ -30,9 +36,22 @@ type FilteredBindingFetcher struct {
 	logger            *log.Logger
 	invalidDrains     metrics.Gauge
 	blacklistedDrains metrics.Gauge
+	rejectionReporter RejectionReporter
+}
+
+// FilteredBindingFetcherOption allows a FilteredBindingFetcher to be
+// customized.
+type FilteredBindingFetcherOption func(*FilteredBindingFetcher)
+
+// WithRejectionReporter returns a FilteredBindingFetcherOption that reports
+// rejected bindings to the given RejectionReporter.
+func WithRejectionReporter(r RejectionReporter) FilteredBindingFetcherOption {
+	return func(f *FilteredBindingFetcher) {
+		f.rejectionReporter = r
+	}
 }
 
-func NewFilteredBindingFetcher(c IPChecker, b binding.Fetcher, m metricsClient, warn bool, lc *log.Logger) *FilteredBindingFetcher {
+func NewFilteredBindingFetcher(c IPChecker, b binding.Fetcher, m metricsClient, warn bool, lc *log.Logger, opts ...FilteredBindingFetcherOption) *FilteredBindingFetcher {
 	opt := metrics.WithMetricLabels(map[string]string{"unit": "total"})
 
 	invalidDrains := m.NewGauge(
@@ -45,7 +64,7 @@ func NewFilteredBindingFetcher(c IPChecker, b binding.Fetcher, m metricsClient,
 		"Count of blacklisted drains encountered in last binding fetch.",
 		opt,
 	)
-	return &FilteredBindingFetcher{
+	f := &FilteredBindingFetcher{
 		ipChecker:         c,
 		br:                b,
 		warn:              warn,
@@ -53,6 +72,10 @@ func NewFilteredBindingFetcher(c IPChecker, b binding.Fetcher, m metricsClient,
 		invalidDrains:     invalidDrains,
 		blacklistedDrains: blacklistedDrains,
 	}
+	for _, o := range opts {
+		o(f)
+	}
+	return f
 }
 
 func (f FilteredBindingFetcher) DrainLimit() int {
@@ -65,6 +88,7 @@ func (f *FilteredBindingFetcher) FetchBindings() ([]syslog.Binding, error) {
 		return nil, err
 	}
 	newBindings := []syslog.Binding{}
+	rejected := make(map[syslog.Binding]string)
 
 	var invalidDrains float64
 	var blacklistedDrains float64
@@ -72,6 +96,7 @@ func (f *FilteredBindingFetcher) FetchBindings() ([]syslog.Binding, error) {
 		u, err := url.Parse(b.Drain.Url)
 		if err != nil {
 			invalidDrains += 1
+			rejected[b] = syslog.ErrorClassInvalid
 			f.printWarning("Cannot parse syslog drain url for application %s", b.AppId)
 			continue
 		}
@@ -81,12 +106,14 @@ func (f *FilteredBindingFetcher) FetchBindings() ([]syslog.Binding, error) {
 		anonymousUrl.RawQuery = ""
 
 		if invalidScheme(u.Scheme) {
+			rejected[b] = syslog.ErrorClassInvalid
 			f.printWarning("Invalid scheme %s in syslog drain url %s for application %s", u.Scheme, anonymousUrl.String(), b.AppId)
 			continue
 		}
 
 		if len(u.Host) == 0 {
 			invalidDrains += 1
+			rejected[b] = syslog.ErrorClassInvalid
 			f.printWarning("No hostname found in syslog drain url %s for application %s", anonymousUrl.String(), b.AppId)
 			continue
 		}
@@ -94,6 +121,7 @@ func (f *FilteredBindingFetcher) FetchBindings() ([]syslog.Binding, error) {
 		ip, err := f.ipChecker.ResolveAddr(u.Host)
 		if err != nil {
 			invalidDrains += 1
+			rejected[b] = syslog.ErrorClassDNS
 			f.printWarning("Cannot resolve ip address for syslog drain with url %s for application %s", anonymousUrl.String(), b.AppId)
 			continue
 		}
@@ -102,6 +130,7 @@ func (f *FilteredBindingFetcher) FetchBindings() ([]syslog.Binding, error) {
 		if err != nil {
 			invalidDrains += 1
 			blacklistedDrains += 1
+			rejected[b] = syslog.ErrorClassBlacklisted
 			f.printWarning("Resolved ip address for syslog drain with url %s for application %s is blacklisted", anonymousUrl.String(), b.AppId)
 			continue
 		}
@@ -111,6 +140,9 @@ func (f *FilteredBindingFetcher) FetchBindings() ([]syslog.Binding, error) {
 
 	f.blacklistedDrains.Set(blacklistedDrains)
 	f.invalidDrains.Set(invalidDrains)
+	if f.rejectionReporter != nil {
+		f.rejectionReporter.SetRejected(rejected)
+	}
 	return newBindings, nil
 }
 
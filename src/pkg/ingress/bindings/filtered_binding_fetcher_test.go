@@ -287,8 +287,63 @@ var _ = Describe("FilteredBindingFetcher", func() {
 			})
 		})
 	})
+
+	It("reports rejected bindings", func() {
+		input := []syslog.Binding{
+			{AppId: "app-id", Drain: syslog.Drain{Url: "syslog://10.10.10.10"}},
+			{AppId: "app-id", Drain: syslog.Drain{Url: "://"}},
+			{AppId: "app-id", Drain: syslog.Drain{Url: "bad://10.10.10.10"}},
+		}
+		reporter := &spyRejectionReporter{}
+
+		filter = bindings.NewFilteredBindingFetcher(
+			&spyIPChecker{},
+			&SpyBindingReader{bindings: input},
+			metrics,
+			false,
+			log,
+			bindings.WithRejectionReporter(reporter),
+		)
+		_, err := filter.FetchBindings()
+
+		Expect(err).ToNot(HaveOccurred())
+		Expect(reporter.rejected).To(Equal(map[syslog.Binding]string{
+			input[1]: syslog.ErrorClassInvalid,
+			input[2]: syslog.ErrorClassInvalid,
+		}))
+	})
+
+	It("reports blacklisted bindings", func() {
+		input := []syslog.Binding{
+			{AppId: "app-id", Drain: syslog.Drain{Url: "syslog://10.10.10.10"}},
+		}
+		reporter := &spyRejectionReporter{}
+
+		filter = bindings.NewFilteredBindingFetcher(
+			&spyIPChecker{checkBlacklistError: errors.New("blacklist error")},
+			&SpyBindingReader{bindings: input},
+			metrics,
+			false,
+			log,
+			bindings.WithRejectionReporter(reporter),
+		)
+		_, err := filter.FetchBindings()
+
+		Expect(err).ToNot(HaveOccurred())
+		Expect(reporter.rejected).To(Equal(map[syslog.Binding]string{
+			input[0]: syslog.ErrorClassBlacklisted,
+		}))
+	})
 })
 
+type spyRejectionReporter struct {
+	rejected map[syslog.Binding]string
+}
+
+func (s *spyRejectionReporter) SetRejected(rejected map[syslog.Binding]string) {
+	s.rejected = rejected
+}
+
 type spyIPChecker struct {
 	checkBlacklistError error
 	resolveAddrError    error
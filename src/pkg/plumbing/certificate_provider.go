@@ -0,0 +1,485 @@
+package plumbing
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	metrics "code.cloudfoundry.org/go-metric-registry"
+	"code.cloudfoundry.org/tlsconfig"
+	"google.golang.org/grpc/credentials"
+)
+
+const defaultReloadInterval = time.Minute
+
+type expiryMetricClient interface {
+	NewGauge(name, helpText string, o ...metrics.MetricOption) metrics.Gauge
+	RemoveGauge(g metrics.Gauge)
+}
+
+type expiryKey struct {
+	purpose     string
+	fingerprint string
+}
+
+type fileStat struct {
+	modTime time.Time
+	size    int64
+}
+
+// CertificateProvider holds a certificate, key and CA loaded from files. It
+// watches the files and reloads them when they change so that certificates
+// can be rotated without restarting the process. TLS configs built by the
+// provider always use the latest certificates for new handshakes.
+type CertificateProvider struct {
+	certFile   string
+	keyFile    string
+	caFile     string
+	systemPool bool
+	optionalCA bool
+	interval   time.Duration
+
+	m        expiryMetricClient
+	purpose  string
+	expiries map[expiryKey]metrics.Gauge
+
+	mu      sync.RWMutex
+	cert    *tls.Certificate
+	pool    *x509.CertPool
+	caCerts []*x509.Certificate
+	stats   map[string]fileStat
+
+	done     chan struct{}
+	stopOnce sync.Once
+}
+
+// CertificateProviderOption allows a CertificateProvider to be customized.
+type CertificateProviderOption func(*CertificateProvider)
+
+// WithReloadInterval sets how often the files are checked for changes.
+// Defaults to one minute.
+func WithReloadInterval(d time.Duration) CertificateProviderOption {
+	return func(p *CertificateProvider) {
+		p.interval = d
+	}
+}
+
+// WithSystemCertPool adds the CA file to the system cert pool instead of an
+// empty pool.
+func WithSystemCertPool() CertificateProviderOption {
+	return func(p *CertificateProvider) {
+		p.systemPool = true
+	}
+}
+
+// WithOptionalCA allows the CA file to be missing or invalid. Until it can
+// be loaded the pool only holds the system roots if WithSystemCertPool is
+// given. The file is still watched and added to the pool once it loads.
+func WithOptionalCA() CertificateProviderOption {
+	return func(p *CertificateProvider) {
+		p.optionalCA = true
+	}
+}
+
+// WithExpiryMetrics exports the number of seconds until the certificate and
+// the certificates of the CA file expire as cert_expiry_seconds gauges
+// labeled by purpose, subject and fingerprint. The purpose of CA
+// certificates is suffixed with "_ca". The gauges of certificates that are
+// gone after a reload are removed.
+func WithExpiryMetrics(m expiryMetricClient, purpose string) CertificateProviderOption {
+	return func(p *CertificateProvider) {
+		p.m = m
+		p.purpose = purpose
+	}
+}
+
+// NewCertificateProvider loads the certificate, key and CA and starts
+// watching them for changes. The certificate and key or the CA may be empty
+// if the provider is only used for one of them.
+func NewCertificateProvider(
+	certFile string,
+	keyFile string,
+	caFile string,
+	opts ...CertificateProviderOption,
+) (*CertificateProvider, error) {
+	p := &CertificateProvider{
+		certFile: certFile,
+		keyFile:  keyFile,
+		caFile:   caFile,
+		interval: defaultReloadInterval,
+		expiries: make(map[expiryKey]metrics.Gauge),
+		done:     make(chan struct{}),
+	}
+	for _, o := range opts {
+		o(p)
+	}
+
+	if err := p.load(); err != nil {
+		return nil, err
+	}
+	p.updateExpiry()
+
+	if p.interval > 0 {
+		go p.watch()
+	}
+
+	return p, nil
+}
+
+// Stop stops watching the files for changes.
+func (p *CertificateProvider) Stop() {
+	p.stopOnce.Do(func() {
+		close(p.done)
+	})
+}
+
+// CertPool returns the current CA pool.
+func (p *CertificateProvider) CertPool() *x509.CertPool {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.pool
+}
+
+// GetCertificate returns the current certificate. It is meant to be used as
+// tls.Config.GetCertificate.
+func (p *CertificateProvider) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	return p.certificate()
+}
+
+// GetClientCertificate returns the current certificate. It is meant to be
+// used as tls.Config.GetClientCertificate.
+func (p *CertificateProvider) GetClientCertificate(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+	return p.certificate()
+}
+
+// VerifyPeerCertificate returns a function that verifies the peer's
+// certificate chain against the current CA pool. If serverName is not empty
+// the certificate must also be valid for it. It is meant to be used as
+// tls.Config.VerifyPeerCertificate when the standard verification is
+// disabled. It is not called for resumed sessions, which is why ServerConfig
+// and ClientConfig verify the peer in VerifyConnection instead.
+func (p *CertificateProvider) VerifyPeerCertificate(
+	serverName string,
+	usage x509.ExtKeyUsage,
+) func([][]byte, [][]*x509.Certificate) error {
+	return func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+		certs := make([]*x509.Certificate, 0, len(rawCerts))
+		for _, raw := range rawCerts {
+			c, err := x509.ParseCertificate(raw)
+			if err != nil {
+				return err
+			}
+			certs = append(certs, c)
+		}
+
+		return p.verify(certs, serverName, usage)
+	}
+}
+
+// ServerConfig returns a server tls.Config that serves the current
+// certificate and, if a CA is configured, requires client certificates
+// signed by the current CA.
+func (p *CertificateProvider) ServerConfig(opts ...ConfigOption) (*tls.Config, error) {
+	tlsConfig, err := tlsconfig.Build(
+		tlsconfig.WithInternalServiceDefaults(),
+	).Server()
+	if err != nil {
+		return nil, err
+	}
+
+	tlsConfig.GetCertificate = p.GetCertificate
+	if p.caFile != "" {
+		// The standard verification is replaced so that it uses the current
+		// CA pool. VerifyConnection is also called for resumed sessions.
+		tlsConfig.ClientAuth = tls.RequireAnyClientCert
+		tlsConfig.VerifyConnection = func(cs tls.ConnectionState) error {
+			return p.verify(cs.PeerCertificates, "", x509.ExtKeyUsageClientAuth)
+		}
+	}
+
+	for _, opt := range opts {
+		opt(tlsConfig)
+	}
+
+	return tlsConfig, nil
+}
+
+// ClientConfig returns a client tls.Config that presents the current
+// certificate and verifies the server against the current CA.
+func (p *CertificateProvider) ClientConfig(serverName string) (*tls.Config, error) {
+	tlsConfig, err := tlsconfig.Build(
+		tlsconfig.WithInternalServiceDefaults(),
+	).Client(
+		tlsconfig.WithServerName(serverName),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if p.certFile != "" {
+		tlsConfig.GetClientCertificate = p.GetClientCertificate
+	}
+	if p.caFile != "" {
+		// The standard verification is replaced so that it uses the current
+		// CA pool. VerifyConnection is also called for resumed sessions.
+		tlsConfig.InsecureSkipVerify = true //nolint:gosec
+		tlsConfig.VerifyConnection = func(cs tls.ConnectionState) error {
+			return p.verify(cs.PeerCertificates, cs.ServerName, x509.ExtKeyUsageServerAuth)
+		}
+	}
+
+	return tlsConfig, nil
+}
+
+// ServerCredentials returns gRPC credentials for a server.
+func (p *CertificateProvider) ServerCredentials(opts ...ConfigOption) (credentials.TransportCredentials, error) {
+	tlsConfig, err := p.ServerConfig(opts...)
+	if err != nil {
+		return nil, err
+	}
+	return credentials.NewTLS(tlsConfig), nil
+}
+
+// ClientCredentials returns gRPC credentials for dialing.
+func (p *CertificateProvider) ClientCredentials(serverName string) (credentials.TransportCredentials, error) {
+	tlsConfig, err := p.ClientConfig(serverName)
+	if err != nil {
+		return nil, err
+	}
+	return credentials.NewTLS(tlsConfig), nil
+}
+
+func (p *CertificateProvider) verify(certs []*x509.Certificate, serverName string, usage x509.ExtKeyUsage) error {
+	if len(certs) == 0 {
+		return errors.New("no peer certificate provided")
+	}
+
+	intermediates := x509.NewCertPool()
+	for _, c := range certs[1:] {
+		intermediates.AddCert(c)
+	}
+
+	_, err := certs[0].Verify(x509.VerifyOptions{
+		DNSName:       serverName,
+		Roots:         p.CertPool(),
+		Intermediates: intermediates,
+		KeyUsages:     []x509.ExtKeyUsage{usage},
+	})
+	return err
+}
+
+func (p *CertificateProvider) certificate() (*tls.Certificate, error) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	if p.cert == nil {
+		return nil, errors.New("no certificate configured")
+	}
+	return p.cert, nil
+}
+
+func (p *CertificateProvider) watch() {
+	t := time.NewTicker(p.interval)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-p.done:
+			return
+		case <-t.C:
+			if p.changed() {
+				if err := p.load(); err != nil {
+					log.Printf("failed to reload certificates, keeping the previous ones: %s", err)
+				}
+			}
+			p.updateExpiry()
+		}
+	}
+}
+
+func (p *CertificateProvider) files() []string {
+	var files []string
+	for _, f := range []string{p.certFile, p.keyFile, p.caFile} {
+		if f != "" {
+			files = append(files, f)
+		}
+	}
+	return files
+}
+
+func (p *CertificateProvider) changed() bool {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	for _, f := range p.files() {
+		fi, err := os.Stat(f)
+		if err != nil {
+			continue
+		}
+		if p.stats[f] != (fileStat{modTime: fi.ModTime(), size: fi.Size()}) {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *CertificateProvider) load() error {
+	stats := make(map[string]fileStat)
+	for _, f := range p.files() {
+		// Missing files are reported when they are read below.
+		if fi, err := os.Stat(f); err == nil {
+			stats[f] = fileStat{modTime: fi.ModTime(), size: fi.Size()}
+		}
+	}
+
+	var cert *tls.Certificate
+	if p.certFile != "" {
+		c, err := tls.LoadX509KeyPair(p.certFile, p.keyFile)
+		if err != nil {
+			return fmt.Errorf("failed to load keypair: %s", err)
+		}
+		c.Leaf, err = x509.ParseCertificate(c.Certificate[0])
+		if err != nil {
+			return fmt.Errorf("failed to parse certificate: %s", err)
+		}
+		cert = &c
+	}
+
+	pool, caCerts, err := p.loadPool()
+	if err != nil && p.optionalCA && !p.hasCA() {
+		log.Printf("unable to load CA file %s, using the default pool until it loads: %s", p.caFile, err)
+		pool, caCerts, err = p.basePool(), nil, nil
+	}
+	if err != nil {
+		return err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.cert = cert
+	p.pool = pool
+	p.caCerts = caCerts
+	p.stats = stats
+
+	return nil
+}
+
+func (p *CertificateProvider) hasCA() bool {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return len(p.caCerts) > 0
+}
+
+// basePool returns the pool the CA certificates are added to.
+func (p *CertificateProvider) basePool() *x509.CertPool {
+	if p.systemPool {
+		sp, err := x509.SystemCertPool()
+		if err == nil {
+			return sp
+		}
+	}
+	return x509.NewCertPool()
+}
+
+func (p *CertificateProvider) loadPool() (*x509.CertPool, []*x509.Certificate, error) {
+	pool := p.basePool()
+
+	if p.caFile == "" {
+		return pool, nil, nil
+	}
+
+	caPEM, err := os.ReadFile(p.caFile)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read CA file: %s", err)
+	}
+
+	var caCerts []*x509.Certificate
+	for {
+		var block *pem.Block
+		block, caPEM = pem.Decode(caPEM)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+
+		c, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			continue
+		}
+		pool.AddCert(c)
+		caCerts = append(caCerts, c)
+	}
+	if len(caCerts) == 0 {
+		return nil, nil, errors.New("unable to load CA certificate")
+	}
+
+	return pool, caCerts, nil
+}
+
+func (p *CertificateProvider) updateExpiry() {
+	if p.m == nil {
+		return
+	}
+
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	current := make(map[expiryKey]bool)
+	if p.cert != nil {
+		current[p.setExpiry(p.purpose, p.cert.Leaf)] = true
+	}
+	for _, c := range p.caCerts {
+		current[p.setExpiry(p.purpose+"_ca", c)] = true
+	}
+
+	for k, g := range p.expiries {
+		if !current[k] {
+			p.m.RemoveGauge(g)
+			delete(p.expiries, k)
+		}
+	}
+}
+
+func (p *CertificateProvider) setExpiry(purpose string, c *x509.Certificate) expiryKey {
+	k := expiryKey{purpose: purpose, fingerprint: CertificateFingerprint(c)}
+	g, ok := p.expiries[k]
+	if !ok {
+		g = p.m.NewGauge(
+			"cert_expiry_seconds",
+			"Number of seconds until the certificate expires.",
+			metrics.WithMetricLabels(map[string]string{
+				"purpose":     k.purpose,
+				"subject":     CertificateSubject(c),
+				"fingerprint": k.fingerprint,
+			}),
+		)
+		p.expiries[k] = g
+	}
+	g.Set(time.Until(c.NotAfter).Seconds())
+	return k
+}
+
+// CertificateFingerprint returns the hex encoded SHA-256 fingerprint of the
+// certificate.
+func CertificateFingerprint(c *x509.Certificate) string {
+	sum := sha256.Sum256(c.Raw)
+	return hex.EncodeToString(sum[:])
+}
+
+// CertificateSubject returns the common name of the certificate's subject or
+// the full subject if it has no common name.
+func CertificateSubject(c *x509.Certificate) string {
+	if c.Subject.CommonName != "" {
+		return c.Subject.CommonName
+	}
+	return c.Subject.String()
+}
@@ -0,0 +1,393 @@
+package plumbing_test
+
+import (
+	"crypto/tls"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	metrics "code.cloudfoundry.org/go-metric-registry"
+	metricsHelpers "code.cloudfoundry.org/go-metric-registry/testhelpers"
+	"code.cloudfoundry.org/tlsconfig/certtest"
+
+	"code.cloudfoundry.org/loggregator-agent-release/src/pkg/plumbing"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("CertificateProvider", func() {
+	var (
+		dir      string
+		certFile string
+		keyFile  string
+		caFile   string
+		ca       *certtest.Authority
+		provider *plumbing.CertificateProvider
+	)
+
+	writeCert := func(ca *certtest.Authority, name string) {
+		cert, err := ca.BuildSignedCertificate(name, certtest.WithDomains(name))
+		Expect(err).ToNot(HaveOccurred())
+		certPEM, keyPEM, err := cert.CertificatePEMAndPrivateKey()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(os.WriteFile(certFile, certPEM, 0600)).To(Succeed())
+		Expect(os.WriteFile(keyFile, keyPEM, 0600)).To(Succeed())
+	}
+
+	writeCA := func(ca *certtest.Authority) {
+		caPEM, err := ca.CertificatePEM()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(os.WriteFile(caFile, caPEM, 0600)).To(Succeed())
+	}
+
+	leafName := func() string {
+		c, err := provider.GetCertificate(nil)
+		Expect(err).ToNot(HaveOccurred())
+		return c.Leaf.Subject.CommonName
+	}
+
+	BeforeEach(func() {
+		dir = GinkgoT().TempDir()
+		certFile = filepath.Join(dir, "cert.crt")
+		keyFile = filepath.Join(dir, "cert.key")
+		caFile = filepath.Join(dir, "ca.crt")
+
+		var err error
+		ca, err = certtest.BuildCA("ca")
+		Expect(err).ToNot(HaveOccurred())
+		writeCA(ca)
+		writeCert(ca, "first")
+
+		provider, err = plumbing.NewCertificateProvider(
+			certFile,
+			keyFile,
+			caFile,
+			plumbing.WithReloadInterval(10*time.Millisecond),
+		)
+		Expect(err).ToNot(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		provider.Stop()
+	})
+
+	It("reloads the certificate when the files change", func() {
+		Expect(leafName()).To(Equal("first"))
+
+		writeCert(ca, "second")
+
+		Eventually(leafName).Should(Equal("second"))
+	})
+
+	It("keeps the previous certificate when the files are invalid", func() {
+		Expect(os.WriteFile(keyFile, []byte("invalid"), 0600)).To(Succeed())
+
+		Consistently(leafName, 50*time.Millisecond).Should(Equal("first"))
+	})
+
+	It("reloads the CA when the file changes", func() {
+		otherCA, err := certtest.BuildCA("other-ca")
+		Expect(err).ToNot(HaveOccurred())
+		cert, err := otherCA.BuildSignedCertificate("server", certtest.WithDomains("server"))
+		Expect(err).ToNot(HaveOccurred())
+		tlsCert, err := cert.TLSCertificate()
+		Expect(err).ToNot(HaveOccurred())
+
+		verify := provider.VerifyPeerCertificate("server", 0)
+		Expect(verify(tlsCert.Certificate, nil)).ToNot(Succeed())
+
+		writeCA(otherCA)
+
+		Eventually(func() error {
+			return verify(tlsCert.Certificate, nil)
+		}).Should(Succeed())
+	})
+
+	It("verifies the server name", func() {
+		cert, err := ca.BuildSignedCertificate("server", certtest.WithDomains("server"))
+		Expect(err).ToNot(HaveOccurred())
+		tlsCert, err := cert.TLSCertificate()
+		Expect(err).ToNot(HaveOccurred())
+
+		Expect(provider.VerifyPeerCertificate("server", 0)(tlsCert.Certificate, nil)).To(Succeed())
+		Expect(provider.VerifyPeerCertificate("other", 0)(tlsCert.Certificate, nil)).ToNot(Succeed())
+	})
+
+	It("returns an error if the files cannot be loaded", func() {
+		_, err := plumbing.NewCertificateProvider(certFile, keyFile, keyFile)
+		Expect(err).To(HaveOccurred())
+
+		_, err = plumbing.NewCertificateProvider(certFile, caFile, caFile)
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("exports the certificate expiry", func() {
+		sm := metricsHelpers.NewMetricsRegistry()
+		p, err := plumbing.NewCertificateProvider(
+			certFile,
+			keyFile,
+			caFile,
+			plumbing.WithExpiryMetrics(sm, "grpc"),
+		)
+		Expect(err).ToNot(HaveOccurred())
+		defer p.Stop()
+
+		c, err := p.GetCertificate(nil)
+		Expect(err).ToNot(HaveOccurred())
+		expiry := sm.GetMetricValue("cert_expiry_seconds", map[string]string{
+			"purpose":     "grpc",
+			"subject":     "first",
+			"fingerprint": plumbing.CertificateFingerprint(c.Leaf),
+		})
+		Expect(expiry).To(BeNumerically("~", time.Until(c.Leaf.NotAfter).Seconds(), 5))
+
+		caCert, err := ca.Certificate()
+		Expect(err).ToNot(HaveOccurred())
+		caExpiry := sm.GetMetricValue("cert_expiry_seconds", map[string]string{
+			"purpose":     "grpc_ca",
+			"subject":     "ca",
+			"fingerprint": plumbing.CertificateFingerprint(caCert),
+		})
+		Expect(caExpiry).To(BeNumerically("~", time.Until(caCert.NotAfter).Seconds(), 5))
+	})
+
+	It("exports the expiry of the CA certificates only if no certificate is configured", func() {
+		sm := metricsHelpers.NewMetricsRegistry()
+		p, err := plumbing.NewCertificateProvider(
+			"",
+			"",
+			caFile,
+			plumbing.WithSystemCertPool(),
+			plumbing.WithExpiryMetrics(sm, "drain"),
+		)
+		Expect(err).ToNot(HaveOccurred())
+		defer p.Stop()
+
+		caCert, err := ca.Certificate()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(sm.HasMetric("cert_expiry_seconds", map[string]string{
+			"purpose":     "drain_ca",
+			"subject":     "ca",
+			"fingerprint": plumbing.CertificateFingerprint(caCert),
+		})).To(BeTrue())
+		c, err := provider.GetCertificate(nil)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(sm.HasMetric("cert_expiry_seconds", map[string]string{
+			"purpose":     "drain",
+			"subject":     "first",
+			"fingerprint": plumbing.CertificateFingerprint(c.Leaf),
+		})).To(BeFalse())
+	})
+
+	It("exports the expiry of each CA with the same subject", func() {
+		rotatedCA, err := certtest.BuildCA("ca")
+		Expect(err).ToNot(HaveOccurred())
+		oldPEM, err := ca.CertificatePEM()
+		Expect(err).ToNot(HaveOccurred())
+		newPEM, err := rotatedCA.CertificatePEM()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(os.WriteFile(caFile, append(oldPEM, newPEM...), 0600)).To(Succeed())
+
+		sm := metricsHelpers.NewMetricsRegistry()
+		p, err := plumbing.NewCertificateProvider("", "", caFile, plumbing.WithExpiryMetrics(sm, "grpc"))
+		Expect(err).ToNot(HaveOccurred())
+		defer p.Stop()
+
+		for _, a := range []*certtest.Authority{ca, rotatedCA} {
+			c, err := a.Certificate()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(sm.HasMetric("cert_expiry_seconds", map[string]string{
+				"purpose":     "grpc_ca",
+				"subject":     "ca",
+				"fingerprint": plumbing.CertificateFingerprint(c),
+			})).To(BeTrue())
+		}
+	})
+
+	It("removes the expiry of certificates that are gone after a reload", func() {
+		sm := &lockedMetricsRegistry{SpyMetricsRegistry: metricsHelpers.NewMetricsRegistry()}
+		p, err := plumbing.NewCertificateProvider(
+			certFile,
+			keyFile,
+			caFile,
+			plumbing.WithExpiryMetrics(sm, "grpc"),
+			plumbing.WithReloadInterval(10*time.Millisecond),
+		)
+		Expect(err).ToNot(HaveOccurred())
+		defer p.Stop()
+
+		c, err := p.GetCertificate(nil)
+		Expect(err).ToNot(HaveOccurred())
+		labels := map[string]string{
+			"purpose":     "grpc",
+			"subject":     "first",
+			"fingerprint": plumbing.CertificateFingerprint(c.Leaf),
+		}
+		Expect(sm.hasMetric("cert_expiry_seconds", labels)).To(BeTrue())
+
+		writeCert(ca, "second")
+
+		Eventually(func() bool {
+			return sm.hasMetric("cert_expiry_seconds", labels)
+		}).Should(BeFalse())
+	})
+
+	It("watches an optional CA that cannot be loaded yet", func() {
+		Expect(os.Remove(caFile)).To(Succeed())
+		p, err := plumbing.NewCertificateProvider(
+			"",
+			"",
+			caFile,
+			plumbing.WithOptionalCA(),
+			plumbing.WithReloadInterval(10*time.Millisecond),
+		)
+		Expect(err).ToNot(HaveOccurred())
+		defer p.Stop()
+
+		cert, err := ca.BuildSignedCertificate("server", certtest.WithDomains("server"))
+		Expect(err).ToNot(HaveOccurred())
+		tlsCert, err := cert.TLSCertificate()
+		Expect(err).ToNot(HaveOccurred())
+
+		verify := p.VerifyPeerCertificate("server", 0)
+		Expect(verify(tlsCert.Certificate, nil)).ToNot(Succeed())
+
+		writeCA(ca)
+
+		Eventually(func() error {
+			return verify(tlsCert.Certificate, nil)
+		}).Should(Succeed())
+	})
+
+	It("shares providers for the same files", func() {
+		p1, err := plumbing.SharedCertificateProvider(certFile, keyFile, caFile)
+		Expect(err).ToNot(HaveOccurred())
+		p2, err := plumbing.SharedCertificateProvider(certFile, keyFile, caFile)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(p2).To(BeIdenticalTo(p1))
+
+		p3, err := plumbing.SharedCertificateProvider(certFile, keyFile, "")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(p3).ToNot(BeIdenticalTo(p1))
+	})
+
+	Context("with mutual TLS", func() {
+		var (
+			server *httptest.Server
+			client *http.Client
+		)
+
+		BeforeEach(func() {
+			server = httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+				w.WriteHeader(http.StatusOK)
+			}))
+			tlsConfig, err := provider.ServerConfig()
+			Expect(err).ToNot(HaveOccurred())
+			server.TLS = tlsConfig
+			server.StartTLS()
+
+			client = plumbing.NewTLSHTTPClientFromProvider(provider, "first", true)
+		})
+
+		AfterEach(func() {
+			server.Close()
+		})
+
+		It("connects with the current certificates", func() {
+			resp, err := client.Get(server.URL)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(resp.StatusCode).To(Equal(http.StatusOK))
+		})
+
+		It("picks up rotated certificates for new connections", func() {
+			newCA, err := certtest.BuildCA("new-ca")
+			Expect(err).ToNot(HaveOccurred())
+			writeCA(newCA)
+			writeCert(newCA, "first")
+
+			Eventually(func() string {
+				c, err := provider.GetCertificate(nil)
+				Expect(err).ToNot(HaveOccurred())
+				return c.Leaf.Issuer.CommonName
+			}).Should(Equal("new-ca"))
+
+			resp, err := client.Get(server.URL)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(resp.StatusCode).To(Equal(http.StatusOK))
+		})
+
+		It("rejects resumed sessions of clients signed by a rotated CA", func() {
+			clientCert, err := tls.LoadX509KeyPair(certFile, keyFile)
+			Expect(err).ToNot(HaveOccurred())
+			c := &http.Client{Transport: &http.Transport{
+				DisableKeepAlives: true,
+				TLSClientConfig: &tls.Config{
+					Certificates:       []tls.Certificate{clientCert},
+					ClientSessionCache: tls.NewLRUClientSessionCache(1),
+					InsecureSkipVerify: true, //nolint:gosec
+				},
+			}}
+
+			for i := 0; i < 2; i++ {
+				resp, err := c.Get(server.URL)
+				Expect(err).ToNot(HaveOccurred())
+				_, _ = io.Copy(io.Discard, resp.Body)
+				resp.Body.Close()
+				Expect(resp.TLS.DidResume).To(Equal(i > 0))
+			}
+
+			newCA, err := certtest.BuildCA("new-ca")
+			Expect(err).ToNot(HaveOccurred())
+			writeCA(newCA)
+			Eventually(func() error {
+				return provider.VerifyPeerCertificate("", 0)(clientCert.Certificate, nil)
+			}).ShouldNot(Succeed())
+
+			_, err = c.Get(server.URL)
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("rejects clients without a certificate", func() {
+			tlsConfig, err := provider.ClientConfig("first")
+			Expect(err).ToNot(HaveOccurred())
+			tlsConfig.GetClientCertificate = func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+				return &tls.Certificate{}, nil
+			}
+			c := &http.Client{Transport: &http.Transport{TLSClientConfig: tlsConfig}}
+
+			_, err = c.Get(server.URL)
+			Expect(err).To(HaveOccurred())
+		})
+	})
+})
+
+// lockedMetricsRegistry guards the spy registry, whose RemoveGauge is not
+// locked, against the watch goroutine of the provider.
+type lockedMetricsRegistry struct {
+	*metricsHelpers.SpyMetricsRegistry
+
+	mu sync.Mutex
+}
+
+func (r *lockedMetricsRegistry) NewGauge(name, helpText string, opts ...metrics.MetricOption) metrics.Gauge {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.SpyMetricsRegistry.NewGauge(name, helpText, opts...)
+}
+
+func (r *lockedMetricsRegistry) RemoveGauge(g metrics.Gauge) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.SpyMetricsRegistry.RemoveGauge(g)
+}
+
+func (r *lockedMetricsRegistry) hasMetric(name string, tags map[string]string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.SpyMetricsRegistry.HasMetric(name, tags)
+}
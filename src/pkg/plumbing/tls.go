@@ -5,9 +5,9 @@ import (
 	"log"
 	"net"
 	"net/http"
+	"sync"
 	"time"
 
-	"code.cloudfoundry.org/tlsconfig"
 	"google.golang.org/grpc/credentials"
 )
 
@@ -37,62 +37,78 @@ func WithCipherSuites(ciphers []string) ConfigOption {
 	}
 }
 
-// NewClientCredentials returns gRPC credentials for dialing.
+var sharedProviders = struct {
+	sync.Mutex
+	m map[[3]string]*CertificateProvider
+}{m: make(map[[3]string]*CertificateProvider)}
+
+// SharedCertificateProvider returns a CertificateProvider for the files that
+// is shared by all callers, so that only one goroutine watches the files no
+// matter how often it is called. The provider must not be stopped.
+func SharedCertificateProvider(certFile, keyFile, caFile string) (*CertificateProvider, error) {
+	sharedProviders.Lock()
+	defer sharedProviders.Unlock()
+
+	k := [3]string{certFile, keyFile, caFile}
+	if p, ok := sharedProviders.m[k]; ok {
+		return p, nil
+	}
+
+	p, err := NewCertificateProvider(certFile, keyFile, caFile)
+	if err != nil {
+		return nil, err
+	}
+	sharedProviders.m[k] = p
+	return p, nil
+}
+
+// NewClientCredentials returns gRPC credentials for dialing. The
+// certificates are reloaded when they change on disk.
 func NewClientCredentials(
 	certFile string,
 	keyFile string,
 	caCertFile string,
 	serverName string,
 ) (credentials.TransportCredentials, error) {
-	tlsConfig, err := tlsconfig.Build(
-		tlsconfig.WithInternalServiceDefaults(),
-		tlsconfig.WithIdentityFromFile(certFile, keyFile),
-	).Client(
-		tlsconfig.WithAuthorityFromFile(caCertFile),
-		tlsconfig.WithServerName(serverName),
-	)
-
+	p, err := SharedCertificateProvider(certFile, keyFile, caCertFile)
 	if err != nil {
 		return nil, err
 	}
 
-	return credentials.NewTLS(tlsConfig), nil
+	return p.ClientCredentials(serverName)
 }
 
-// NewServerCredentials returns gRPC credentials for a server.
+// NewServerCredentials returns gRPC credentials for a server. The
+// certificates are reloaded when they change on disk.
 func NewServerCredentials(
 	certFile string,
 	keyFile string,
 	caCertFile string,
 	opts ...ConfigOption,
 ) (credentials.TransportCredentials, error) {
-	tlsConfig, err := tlsconfig.Build(
-		tlsconfig.WithInternalServiceDefaults(),
-		tlsconfig.WithIdentityFromFile(certFile, keyFile),
-	).Server(
-		tlsconfig.WithClientAuthenticationFromFile(caCertFile),
-	)
-
-	for _, opt := range opts {
-		opt(tlsConfig)
-	}
-
+	p, err := SharedCertificateProvider(certFile, keyFile, caCertFile)
 	if err != nil {
 		return nil, err
 	}
 
-	return credentials.NewTLS(tlsConfig), nil
+	return p.ServerCredentials(opts...)
 }
 
+// NewTLSHTTPClient returns an HTTP client using mutual TLS. The certificates
+// are reloaded when they change on disk.
 func NewTLSHTTPClient(cert, key, ca, commonName string, disableKeepAlives bool) *http.Client {
-	tlsConfig, err := tlsconfig.Build(
-		tlsconfig.WithInternalServiceDefaults(),
-		tlsconfig.WithIdentityFromFile(cert, key),
-	).Client(
-		tlsconfig.WithAuthorityFromFile(ca),
-		tlsconfig.WithServerName(commonName),
-	)
+	p, err := SharedCertificateProvider(cert, key, ca)
+	if err != nil {
+		log.Panicf("failed to load API client certificates: %s", err)
+	}
+
+	return NewTLSHTTPClientFromProvider(p, commonName, disableKeepAlives)
+}
 
+// NewTLSHTTPClientFromProvider returns an HTTP client using mutual TLS with
+// the certificates of the given provider.
+func NewTLSHTTPClientFromProvider(p *CertificateProvider, commonName string, disableKeepAlives bool) *http.Client {
+	tlsConfig, err := p.ClientConfig(commonName)
 	if err != nil {
 		log.Panicf("failed to load API client certificates: %s", err)
 	}
@@ -0,0 +1,136 @@
+package scraper
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+
+	"google.golang.org/protobuf/proto"
+
+	"code.cloudfoundry.org/go-loggregator/v9"
+	"code.cloudfoundry.org/go-loggregator/v9/rpc/loggregator_v2"
+)
+
+// WithBatchedGauges emits the gauges of a scrape that share a source ID,
+// instance ID, tags and timestamp as a single gauge envelope holding all of
+// their values instead of one envelope per value.
+func WithBatchedGauges(batched bool) ScrapeOption {
+	return func(s *Scraper) {
+		s.batchGauges = batched
+	}
+}
+
+// WithHonorTimestamps sets the timestamp of the envelopes of metrics with an
+// explicit timestamp in the exposition to that timestamp instead of the time
+// of the scrape.
+func WithHonorTimestamps(honor bool) ScrapeOption {
+	return func(s *Scraper) {
+		s.honorTimestamps = honor
+	}
+}
+
+// withTimestamp sets the timestamp of an envelope in nanoseconds.
+func withTimestamp(timestamp int64) func(proto.Message) {
+	return func(m proto.Message) {
+		if e, ok := m.(*loggregator_v2.Envelope); ok {
+			e.Timestamp = timestamp
+		}
+	}
+}
+
+// timestampEmitter sets the timestamp of the envelopes it emits.
+type timestampEmitter struct {
+	MetricsEmitter
+	timestamp int64
+}
+
+func (e timestampEmitter) EmitGauge(opts ...loggregator.EmitGaugeOption) {
+	e.MetricsEmitter.EmitGauge(append(opts, withTimestamp(e.timestamp))...)
+}
+
+func (e timestampEmitter) EmitCounter(name string, opts ...loggregator.EmitCounterOption) {
+	e.MetricsEmitter.EmitCounter(name, append(opts, withTimestamp(e.timestamp))...)
+}
+
+// gaugeBatcher merges the gauges emitted during a scrape by their source ID,
+// instance ID, tags and timestamp. Counters are emitted right away. The
+// merged gauges are emitted by flush.
+type gaugeBatcher struct {
+	MetricsEmitter
+
+	keys    []string
+	batches map[string]*loggregator_v2.Envelope
+}
+
+func newGaugeBatcher(e MetricsEmitter) *gaugeBatcher {
+	return &gaugeBatcher{
+		MetricsEmitter: e,
+		batches:        make(map[string]*loggregator_v2.Envelope),
+	}
+}
+
+func (b *gaugeBatcher) EmitGauge(opts ...loggregator.EmitGaugeOption) {
+	e := &loggregator_v2.Envelope{
+		Message: &loggregator_v2.Envelope_Gauge{
+			Gauge: &loggregator_v2.Gauge{
+				Metrics: make(map[string]*loggregator_v2.GaugeValue),
+			},
+		},
+		Tags: make(map[string]string),
+	}
+	for _, o := range opts {
+		o(e)
+	}
+
+	key := batchKey(e)
+	batch, ok := b.batches[key]
+	if !ok {
+		b.keys = append(b.keys, key)
+		b.batches[key] = e
+		return
+	}
+	for name, value := range e.GetGauge().GetMetrics() {
+		batch.GetGauge().Metrics[name] = value
+	}
+}
+
+// flush emits the merged gauges in the order of their first value.
+func (b *gaugeBatcher) flush() {
+	for _, key := range b.keys {
+		e := b.batches[key]
+
+		opts := []loggregator.EmitGaugeOption{
+			loggregator.WithGaugeSourceInfo(e.GetSourceId(), e.GetInstanceId()),
+			loggregator.WithEnvelopeTags(e.GetTags()),
+		}
+		for name, value := range e.GetGauge().GetMetrics() {
+			opts = append(opts, loggregator.WithGaugeValue(name, value.GetValue(), value.GetUnit()))
+		}
+		if e.GetTimestamp() != 0 {
+			opts = append(opts, withTimestamp(e.GetTimestamp()))
+		}
+
+		b.MetricsEmitter.EmitGauge(opts...)
+	}
+
+	b.keys = nil
+	b.batches = make(map[string]*loggregator_v2.Envelope)
+}
+
+func batchKey(e *loggregator_v2.Envelope) string {
+	names := make([]string, 0, len(e.GetTags()))
+	for name := range e.GetTags() {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var key strings.Builder
+	key.WriteString(strconv.Quote(e.GetSourceId()))
+	key.WriteString(strconv.Quote(e.GetInstanceId()))
+	key.WriteString(strconv.FormatInt(e.GetTimestamp(), 10))
+	for _, name := range names {
+		key.WriteString(strconv.Quote(name))
+		key.WriteString(strconv.Quote(e.GetTags()[name]))
+	}
+	return key.String()
+}
@@ -24,6 +24,44 @@ type PromScraperConfig struct {
 	ClientKeyPath  string            `yaml:"client_key_path"`
 	ClientCertPath string            `yaml:"client_cert_path"`
 	ScrapeInterval time.Duration     `yaml:"scrape_interval"`
+	ScrapeTimeout  time.Duration     `yaml:"scrape_timeout"`
+
+	MetricRelabelConfigs []RelabelConfig `yaml:"metric_relabel_configs"`
+	SampleLimit          int             `yaml:"sample_limit"`
+
+	// FileSDConfigs and DNSSDConfigs discover the targets of the config.
+	// The port is not used if any of them are given.
+	FileSDConfigs []FileSDConfig `yaml:"file_sd_configs"`
+	DNSSDConfigs  []DNSSDConfig  `yaml:"dns_sd_configs"`
+
+	// BearerTokenFile, BasicAuth and OAuth2 set the Authorization header of
+	// scrape requests. At most one of them may be given.
+	BearerTokenFile string       `yaml:"bearer_token_file"`
+	BasicAuth       BasicAuth    `yaml:"basic_auth"`
+	OAuth2          OAuth2Config `yaml:"oauth2"`
+}
+
+// BasicAuth holds the basic auth credentials of scrape requests. The
+// password file is read on each scrape.
+type BasicAuth struct {
+	Username     string `yaml:"username"`
+	Password     string `yaml:"password"`
+	PasswordFile string `yaml:"password_file"`
+}
+
+// OAuth2Config fetches access tokens for scrape requests with the OAuth2
+// client credentials grant.
+type OAuth2Config struct {
+	ClientID         string            `yaml:"client_id"`
+	ClientSecret     string            `yaml:"client_secret"`
+	ClientSecretFile string            `yaml:"client_secret_file"`
+	TokenURL         string            `yaml:"token_url"`
+	Scopes           []string          `yaml:"scopes"`
+	EndpointParams   map[string]string `yaml:"endpoint_params"`
+
+	// CaPath is a CA that is trusted for the token endpoint in addition to
+	// the system roots.
+	CaPath string `yaml:"ca_path"`
 }
 
 type ConfigProvider struct {
@@ -50,11 +88,16 @@ func (p *ConfigProvider) Configs() ([]PromScraperConfig, error) {
 			return nil, err
 		}
 		portInt, err := strconv.Atoi(scraperConfig.Port)
-		if err != nil || portInt <= 0 || portInt > 65536 {
+		discovered := len(scraperConfig.FileSDConfigs) > 0 || len(scraperConfig.DNSSDConfigs) > 0
+		if !discovered && (err != nil || portInt <= 0 || portInt > 65536) {
 			p.log.Printf("Prom scraper config at %s does not have a valid port - skipping this config file\n", f)
-		} else {
-			targets = append(targets, scraperConfig)
+			continue
+		}
+		if _, err := NewRelabeler(scraperConfig.MetricRelabelConfigs); err != nil {
+			p.log.Printf("Prom scraper config at %s has invalid metric_relabel_configs: %s - skipping this config file\n", f, err)
+			continue
 		}
+		targets = append(targets, scraperConfig)
 	}
 
 	return targets, nil
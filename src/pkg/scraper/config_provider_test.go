@@ -63,6 +63,7 @@ var _ = Describe("PromScraper", func() {
 					"label": "value",
 				},
 				ScrapeInterval: 10 * time.Second,
+				ScrapeTimeout:  5 * time.Second,
 			},
 		))
 	})
@@ -129,6 +130,89 @@ var _ = Describe("PromScraper", func() {
 		Expect(buffer.String()).To(MatchRegexp("Prom scraper config at /.*/prom_scraper_config.yml[0-9]* does not have a valid port - skipping this config file"))
 	})
 
+	It("parses metric relabel configs with defaults", func() {
+		writeScrapeConfigFile(metricConfigDir, metricConfigWithRelabelConfigs, "prom_scraper_config.yml")
+
+		ps, err := scraper.NewConfigProvider([]string{configGlobs}, defaultScrapeInterval, testLogger).Configs()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(ps).To(HaveLen(1))
+		Expect(ps[0].SampleLimit).To(Equal(1000))
+		Expect(ps[0].MetricRelabelConfigs).To(Equal([]scraper.RelabelConfig{
+			{
+				SourceLabels: []string{"__name__"},
+				Separator:    ";",
+				Regex:        "go_.*",
+				Replacement:  "$1",
+				Action:       "drop",
+			},
+			{
+				SourceLabels: []string{"path"},
+				Separator:    ";",
+				TargetLabel:  "shard",
+				Regex:        "(.*)",
+				Modulus:      4,
+				Replacement:  "$1",
+				Action:       "hashmod",
+			},
+		}))
+	})
+
+	It("skips configs with invalid metric relabel configs", func() {
+		writeScrapeConfigFile(metricConfigDir, metricConfigWithInvalidRelabelConfigs, "prom_scraper_config.yml")
+
+		var buffer bytes.Buffer
+		assertableLogger := log.New(&buffer, "", log.LstdFlags)
+		ps, err := scraper.NewConfigProvider([]string{configGlobs}, defaultScrapeInterval, assertableLogger).Configs()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(ps).To(HaveLen(0))
+		Expect(buffer.String()).To(MatchRegexp("Prom scraper config at /.*/prom_scraper_config.yml[0-9]* has invalid metric_relabel_configs: .* - skipping this config file"))
+	})
+
+	It("parses file_sd_configs and does not require a port", func() {
+		writeScrapeConfigFile(metricConfigDir, metricConfigWithFileSDConfigs, "prom_scraper_config.yml")
+
+		ps, err := scraper.NewConfigProvider([]string{configGlobs}, defaultScrapeInterval, testLogger).Configs()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(ps).To(HaveLen(1))
+		Expect(ps[0].FileSDConfigs).To(Equal([]scraper.FileSDConfig{
+			{
+				Files:           []string{"/var/vcap/data/targets/*.json"},
+				RefreshInterval: time.Minute,
+			},
+		}))
+	})
+
+	It("parses dns_sd_configs and does not require a port", func() {
+		writeScrapeConfigFile(metricConfigDir, metricConfigWithDNSSDConfigs, "prom_scraper_config.yml")
+
+		ps, err := scraper.NewConfigProvider([]string{configGlobs}, defaultScrapeInterval, testLogger).Configs()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(ps).To(HaveLen(1))
+		Expect(ps[0].DNSSDConfigs).To(Equal([]scraper.DNSSDConfig{
+			{Names: []string{"_metrics._tcp.q-s0.web.default.cf.bosh"}},
+			{RecordsFile: "/var/vcap/instance/dns/records.json", Port: 9100},
+		}))
+	})
+
+	It("parses authentication settings", func() {
+		writeScrapeConfigFile(metricConfigDir, metricConfigWithAuth, "prom_scraper_config.yml")
+
+		ps, err := scraper.NewConfigProvider([]string{configGlobs}, defaultScrapeInterval, testLogger).Configs()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(ps).To(HaveLen(1))
+		Expect(ps[0].BasicAuth).To(Equal(scraper.BasicAuth{
+			Username:     "user",
+			PasswordFile: "/var/vcap/jobs/my-job/config/password",
+		}))
+		Expect(ps[0].OAuth2).To(Equal(scraper.OAuth2Config{
+			ClientID:         "client",
+			ClientSecretFile: "/var/vcap/jobs/my-job/config/client_secret",
+			TokenURL:         "https://uaa.example.com/oauth/token",
+			Scopes:           []string{"metrics.read"},
+			EndpointParams:   map[string]string{"audience": "exporter"},
+		}))
+	})
+
 	It("returns a error if port is not a number", func() {
 		writeScrapeConfigFile(metricConfigDir, metricConfigPortNotANumber, "prom_scraper_config.yml")
 
@@ -155,11 +239,57 @@ port: 65537 `
 	metricConfigPortNotANumber = `---
 port: foo`
 
+	metricConfigWithRelabelConfigs = `---
+port: 8080
+sample_limit: 1000
+metric_relabel_configs:
+- source_labels: [__name__]
+  regex: go_.*
+  action: drop
+- source_labels: [path]
+  target_label: shard
+  modulus: 4
+  action: hashmod`
+
+	metricConfigWithFileSDConfigs = `---
+source_id: some-id
+file_sd_configs:
+- files: [/var/vcap/data/targets/*.json]
+  refresh_interval: 1m`
+
+	metricConfigWithDNSSDConfigs = `---
+source_id: some-id
+dns_sd_configs:
+- names: [_metrics._tcp.q-s0.web.default.cf.bosh]
+- records_file: /var/vcap/instance/dns/records.json
+  port: 9100`
+
+	metricConfigWithAuth = `---
+port: 8080
+basic_auth:
+  username: user
+  password_file: /var/vcap/jobs/my-job/config/password
+oauth2:
+  client_id: client
+  client_secret_file: /var/vcap/jobs/my-job/config/client_secret
+  token_url: https://uaa.example.com/oauth/token
+  scopes: [metrics.read]
+  endpoint_params:
+    audience: exporter`
+
+	metricConfigWithInvalidRelabelConfigs = `---
+port: 8080
+metric_relabel_configs:
+- source_labels: [__name__]
+  regex: "go_(.*"
+  action: drop`
+
 	metricConfigWithAllFieldsSpecifiedTemplate = `---
 port: 8081
 source_id: some-id
 instance_id: some-instance-id
 scrape_interval: 10s
+scrape_timeout: 5s
 path: /other
 scheme: https
 server_name: some-server
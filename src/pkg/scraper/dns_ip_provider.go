@@ -1,42 +1,117 @@
 package scraper
 
 import (
+	"context"
 	"encoding/json"
-	"fmt"
-	"log"
+	"net"
 	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
 )
 
+// DNSSDConfig discovers targets by DNS. The targets are either the SRV
+// records of the names or the IPs of a BOSH DNS records file on the port.
+type DNSSDConfig struct {
+	Names       []string `yaml:"names"`
+	RecordsFile string   `yaml:"records_file"`
+	Port        int      `yaml:"port"`
+}
+
 type record []string
 
 type dns struct {
 	Records []record
 }
 
-func NewDNSScrapeTargetProvider(sourceID, dnsFile string, port int) TargetProvider {
+// NewDNSScrapeTargetProvider returns a target for each IP in a BOSH DNS
+// records file. If the file cannot be read, the targets of the last
+// successful read are returned. The instance ID of the targets defaults to
+// their address.
+func NewDNSScrapeTargetProvider(sourceID, dnsFile string, port int, opts ...ProviderOption) TargetProvider {
+	c := newProviderConfig(opts)
+	c.defaults.ID = sourceID
+
+	var (
+		mu          sync.Mutex
+		lastTargets []Target
+	)
 	return func() []Target {
-		file, err := os.Open(dnsFile)
-		if err != nil {
-			log.Fatal(err)
-		}
-		defer file.Close()
+		mu.Lock()
+		defer mu.Unlock()
 
-		var d dns
-		err = json.NewDecoder(file).Decode(&d)
+		d, err := readDNSRecords(dnsFile)
 		if err != nil {
-			panic(err)
+			c.log.Printf("failed to read DNS records from %s: %s", dnsFile, err)
+			return lastTargets
 		}
 
 		var targets []Target
 		for _, r := range d.Records {
-			ip := r[0]
+			if len(r) == 0 || net.ParseIP(r[0]) == nil {
+				continue
+			}
+			targets = append(targets, discoveredTarget(c, net.JoinHostPort(r[0], strconv.Itoa(port))))
+		}
+
+		lastTargets = targets
+		return targets
+	}
+}
+
+func readDNSRecords(dnsFile string) (dns, error) {
+	var d dns
+
+	file, err := os.Open(dnsFile)
+	if err != nil {
+		return d, err
+	}
+	defer file.Close()
 
-			targets = append(targets, Target{
-				ID:        sourceID,
-				MetricURL: fmt.Sprintf("https://%s:%d/metrics", ip, port),
-			})
+	err = json.NewDecoder(file).Decode(&d)
+	return d, err
+}
+
+// NewSRVScrapeTargetProvider returns a target for each SRV record of the
+// name, for example _metrics._tcp.q-s0.web.default.cf.bosh. If the lookup
+// fails, the targets of the last successful lookup are returned. The
+// instance ID of the targets defaults to their address.
+func NewSRVScrapeTargetProvider(sourceID, name string, opts ...ProviderOption) TargetProvider {
+	c := newProviderConfig(opts)
+	c.defaults.ID = sourceID
+
+	var (
+		mu          sync.Mutex
+		lastTargets []Target
+	)
+	return func() []Target {
+		mu.Lock()
+		defer mu.Unlock()
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		_, srvs, err := c.resolver.LookupSRV(ctx, "", "", name)
+		if err != nil {
+			c.log.Printf("failed to look up SRV records of %s: %s", name, err)
+			return lastTargets
 		}
 
+		var targets []Target
+		for _, srv := range srvs {
+			host := strings.TrimSuffix(srv.Target, ".")
+			targets = append(targets, discoveredTarget(c, net.JoinHostPort(host, strconv.Itoa(int(srv.Port)))))
+		}
+
+		lastTargets = targets
 		return targets
 	}
 }
+
+func discoveredTarget(c providerConfig, hostPort string) Target {
+	t := c.target(hostPort)
+	if t.InstanceID == "" {
+		t.InstanceID = hostPort
+	}
+	return t
+}
@@ -1,6 +1,10 @@
 package scraper_test
 
 import (
+	"context"
+	"errors"
+	"log"
+	"net"
 	"os"
 
 	"code.cloudfoundry.org/loggregator-agent-release/src/pkg/scraper"
@@ -24,8 +28,115 @@ var _ = Describe("DnsIpProvider", func() {
 			"https://10.0.16.27:9100/metrics",
 		))
 	})
+
+	It("uses the given scheme and path", func() {
+		dnsFile := writeScrapeConfig(genericConfig)
+		targets := scraper.NewDNSScrapeTargetProvider(
+			"default-source",
+			dnsFile,
+			9100,
+			scraper.WithScheme("http"),
+			scraper.WithMetricsPath("prometheus"),
+		)()
+
+		Expect(metricURLs(targets)).To(ConsistOf(
+			"http://10.0.16.26:9100/prometheus",
+			"http://10.0.16.27:9100/prometheus",
+		))
+		Expect(targets[0].ID).To(Equal("default-source"))
+	})
+
+	It("defaults the instance ID to the address of the target", func() {
+		dnsFile := writeScrapeConfig(genericConfig)
+		targets := scraper.NewDNSScrapeTargetProvider(
+			"default-source",
+			dnsFile,
+			9100,
+			scraper.WithTargetDefaults(scraper.Target{InstanceID: "some-instance"}),
+		)()
+		Expect(targets[0].InstanceID).To(Equal("some-instance"))
+
+		targets = scraper.NewDNSScrapeTargetProvider("default-source", dnsFile, 9100)()
+		Expect(targets).To(ConsistOf(
+			HaveField("InstanceID", "10.0.16.26:9100"),
+			HaveField("InstanceID", "10.0.16.27:9100"),
+		))
+	})
+
+	It("keeps the last targets if the records cannot be read", func() {
+		dnsFile := writeScrapeConfig(genericConfig)
+		provider := scraper.NewDNSScrapeTargetProvider(
+			"default-source",
+			dnsFile,
+			9100,
+			scraper.WithProviderLogger(log.New(GinkgoWriter, "", 0)),
+		)
+		Expect(provider()).To(HaveLen(2))
+
+		Expect(os.WriteFile(dnsFile, []byte("{invalid"), 0600)).To(Succeed())
+		Expect(provider()).To(HaveLen(2))
+
+		Expect(os.Remove(dnsFile)).To(Succeed())
+		Expect(provider()).To(HaveLen(2))
+	})
+
+	It("does not panic if the records file never existed", func() {
+		provider := scraper.NewDNSScrapeTargetProvider(
+			"default-source",
+			"/does/not/exist",
+			9100,
+			scraper.WithProviderLogger(log.New(GinkgoWriter, "", 0)),
+		)
+
+		Expect(provider()).To(BeEmpty())
+	})
+})
+
+var _ = Describe("SRVScrapeTargetProvider", func() {
+	It("returns metrics urls from the SRV records", func() {
+		resolver := &stubSRVResolver{srvs: []*net.SRV{
+			{Target: "q-0.web.bosh.", Port: 9100},
+			{Target: "q-1.web.bosh.", Port: 9101},
+		}}
+		targets := scraper.NewSRVScrapeTargetProvider(
+			"default-source",
+			"_metrics._tcp.web.bosh",
+			scraper.WithSRVResolver(resolver),
+		)()
+
+		Expect(resolver.name).To(Equal("_metrics._tcp.web.bosh"))
+		Expect(metricURLs(targets)).To(Equal([]string{
+			"https://q-0.web.bosh:9100/metrics",
+			"https://q-1.web.bosh:9101/metrics",
+		}))
+	})
+
+	It("keeps the last targets if the lookup fails", func() {
+		resolver := &stubSRVResolver{srvs: []*net.SRV{{Target: "q-0.web.bosh.", Port: 9100}}}
+		provider := scraper.NewSRVScrapeTargetProvider(
+			"default-source",
+			"_metrics._tcp.web.bosh",
+			scraper.WithSRVResolver(resolver),
+			scraper.WithProviderLogger(log.New(GinkgoWriter, "", 0)),
+		)
+		Expect(provider()).To(HaveLen(1))
+
+		resolver.err = errors.New("no such host")
+		Expect(metricURLs(provider())).To(Equal([]string{"https://q-0.web.bosh:9100/metrics"}))
+	})
 })
 
+type stubSRVResolver struct {
+	name string
+	srvs []*net.SRV
+	err  error
+}
+
+func (r *stubSRVResolver) LookupSRV(_ context.Context, _, _, name string) (string, []*net.SRV, error) {
+	r.name = name
+	return "", r.srvs, r.err
+}
+
 func writeScrapeConfig(config string) string {
 	f, err := os.CreateTemp("", "records.json")
 	Expect(err).ToNot(HaveOccurred())
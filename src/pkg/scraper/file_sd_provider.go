@@ -0,0 +1,173 @@
+package scraper
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"gopkg.in/yaml.v2"
+)
+
+// FileSDConfig is a Prometheus style file based service discovery config.
+type FileSDConfig struct {
+	Files           []string      `yaml:"files"`
+	RefreshInterval time.Duration `yaml:"refresh_interval"`
+}
+
+// fileSDGroup is a target group in the Prometheus file_sd format.
+type fileSDGroup struct {
+	Targets []string          `json:"targets" yaml:"targets"`
+	Labels  map[string]string `json:"labels" yaml:"labels"`
+}
+
+type fileSDProvider struct {
+	files []string
+	c     providerConfig
+
+	mu          sync.Mutex
+	lastRefresh time.Time
+	groups      map[string][]fileSDGroup
+}
+
+// NewFileSDTargetProvider returns the targets of the files matching the
+// globs. The files hold a list of target groups in the Prometheus file_sd
+// JSON or YAML format:
+//
+//	[{"targets": ["10.0.0.1:9100", "10.0.0.2:9100"], "labels": {"az": "z1"}}]
+//
+// The files are reread at most once per refresh interval. If a file cannot
+// be read, its last targets are kept. The labels of a group are added to the
+// default tags of its targets except for
+//
+//	__scheme__        the scheme of the metric URLs
+//	__metrics_path__  the path of the metric URLs
+//	source_id         the source ID of the targets
+//	instance_id       the instance ID of the targets, which defaults to the
+//	                  address of the target
+//
+// Other labels starting with __ are ignored.
+func NewFileSDTargetProvider(files []string, opts ...ProviderOption) TargetProvider {
+	p := &fileSDProvider{
+		files:  files,
+		c:      newProviderConfig(opts),
+		groups: make(map[string][]fileSDGroup),
+	}
+	return p.targets
+}
+
+func (p *fileSDProvider) targets() []Target {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.lastRefresh.IsZero() || time.Since(p.lastRefresh) >= p.c.refreshInterval {
+		p.refresh()
+		p.lastRefresh = time.Now()
+	}
+
+	files := make([]string, 0, len(p.groups))
+	for f := range p.groups {
+		files = append(files, f)
+	}
+	sort.Strings(files)
+
+	var targets []Target
+	for _, f := range files {
+		for _, g := range p.groups[f] {
+			for _, hostPort := range g.Targets {
+				targets = append(targets, p.target(hostPort, g.Labels))
+			}
+		}
+	}
+	return targets
+}
+
+func (p *fileSDProvider) refresh() {
+	matched := make(map[string]bool)
+	for _, glob := range p.files {
+		files, err := filepath.Glob(glob)
+		if err != nil {
+			p.c.log.Printf("invalid file_sd glob %s: %s", glob, err)
+			continue
+		}
+		for _, f := range files {
+			matched[f] = true
+		}
+	}
+
+	for f := range p.groups {
+		if !matched[f] {
+			delete(p.groups, f)
+		}
+	}
+
+	for f := range matched {
+		groups, err := readFileSDGroups(f)
+		if err != nil {
+			p.c.log.Printf("failed to read file_sd targets from %s: %s", f, err)
+			continue
+		}
+		p.groups[f] = groups
+	}
+}
+
+func readFileSDGroups(file string) ([]fileSDGroup, error) {
+	content, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	var groups []fileSDGroup
+	switch strings.ToLower(filepath.Ext(file)) {
+	case ".json":
+		err = json.Unmarshal(content, &groups)
+	case ".yml", ".yaml":
+		err = yaml.Unmarshal(content, &groups)
+	default:
+		return nil, fmt.Errorf("unsupported file extension %q", filepath.Ext(file))
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	for _, g := range groups {
+		for _, t := range g.Targets {
+			if t == "" || strings.Contains(t, "/") {
+				return nil, fmt.Errorf("invalid target %q", t)
+			}
+		}
+	}
+	return groups, nil
+}
+
+func (p *fileSDProvider) target(hostPort string, labels map[string]string) Target {
+	t := p.c.target(hostPort)
+	t.InstanceID = hostPort
+
+	scheme, path := p.c.scheme, p.c.path
+	for name, value := range labels {
+		switch {
+		case name == "__scheme__":
+			scheme = value
+		case name == "__metrics_path__":
+			path = value
+		case name == "source_id":
+			t.ID = value
+		case name == "instance_id":
+			t.InstanceID = value
+		case strings.HasPrefix(name, "__"):
+		default:
+			if t.DefaultTags == nil {
+				t.DefaultTags = make(map[string]string)
+			}
+			t.DefaultTags[name] = value
+		}
+	}
+	t.MetricURL = p.c.metricURL(scheme, hostPort, path)
+
+	return t
+}
@@ -0,0 +1,131 @@
+package scraper_test
+
+import (
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"code.cloudfoundry.org/loggregator-agent-release/src/pkg/scraper"
+)
+
+var _ = Describe("FileSDTargetProvider", func() {
+	var (
+		dir    string
+		logger *log.Logger
+	)
+
+	BeforeEach(func() {
+		var err error
+		dir, err = os.MkdirTemp("", "")
+		Expect(err).ToNot(HaveOccurred())
+		logger = log.New(GinkgoWriter, "", 0)
+	})
+
+	AfterEach(func() {
+		Expect(os.RemoveAll(dir)).To(Succeed())
+	})
+
+	writeFile := func(name, content string) {
+		Expect(os.WriteFile(filepath.Join(dir, name), []byte(content), 0600)).To(Succeed())
+	}
+
+	It("returns the targets of JSON and YAML files", func() {
+		writeFile("a.json", `[
+			{"targets": ["10.0.0.1:9100"], "labels": {"az": "z1", "source_id": "node", "__meta_ignored": "x"}},
+			{"targets": ["10.0.0.2:8443"], "labels": {"__scheme__": "http", "__metrics_path__": "/prometheus", "instance_id": "vm-2"}}
+		]`)
+		writeFile("b.yml", `---
+- targets: ["10.0.0.3:9100"]
+`)
+		writeFile("c.txt", `[{"targets": ["10.0.0.4:9100"]}]`)
+
+		targets := scraper.NewFileSDTargetProvider(
+			[]string{filepath.Join(dir, "*")},
+			scraper.WithProviderLogger(logger),
+			scraper.WithTargetDefaults(scraper.Target{
+				ID:          "default-source",
+				Headers:     map[string]string{"Authorization": "token"},
+				DefaultTags: map[string]string{"bosh_job": "web"},
+			}),
+		)()
+
+		Expect(targets).To(Equal([]scraper.Target{
+			{
+				ID:          "node",
+				InstanceID:  "10.0.0.1:9100",
+				MetricURL:   "https://10.0.0.1:9100/metrics",
+				Headers:     map[string]string{"Authorization": "token"},
+				DefaultTags: map[string]string{"bosh_job": "web", "az": "z1"},
+			},
+			{
+				ID:          "default-source",
+				InstanceID:  "vm-2",
+				MetricURL:   "http://10.0.0.2:8443/prometheus",
+				Headers:     map[string]string{"Authorization": "token"},
+				DefaultTags: map[string]string{"bosh_job": "web"},
+			},
+			{
+				ID:          "default-source",
+				InstanceID:  "10.0.0.3:9100",
+				MetricURL:   "https://10.0.0.3:9100/metrics",
+				Headers:     map[string]string{"Authorization": "token"},
+				DefaultTags: map[string]string{"bosh_job": "web"},
+			},
+		}))
+	})
+
+	It("rereads the files after the refresh interval", func() {
+		writeFile("a.json", `[{"targets": ["10.0.0.1:9100"]}]`)
+		provider := scraper.NewFileSDTargetProvider(
+			[]string{filepath.Join(dir, "*.json")},
+			scraper.WithProviderLogger(logger),
+			scraper.WithRefreshInterval(50*time.Millisecond),
+		)
+		Expect(metricURLs(provider())).To(Equal([]string{"https://10.0.0.1:9100/metrics"}))
+
+		writeFile("a.json", `[{"targets": ["10.0.0.1:9100", "10.0.0.5:9100"]}]`)
+		writeFile("b.json", `[{"targets": ["10.0.0.6:9100"]}]`)
+		Expect(metricURLs(provider())).To(Equal([]string{"https://10.0.0.1:9100/metrics"}))
+
+		Eventually(func() []string {
+			return metricURLs(provider())
+		}).Should(Equal([]string{
+			"https://10.0.0.1:9100/metrics",
+			"https://10.0.0.5:9100/metrics",
+			"https://10.0.0.6:9100/metrics",
+		}))
+
+		Expect(os.Remove(filepath.Join(dir, "a.json"))).To(Succeed())
+		Eventually(func() []string {
+			return metricURLs(provider())
+		}).Should(Equal([]string{"https://10.0.0.6:9100/metrics"}))
+	})
+
+	It("keeps the targets of files that become invalid", func() {
+		writeFile("a.json", `[{"targets": ["10.0.0.1:9100"]}]`)
+		provider := scraper.NewFileSDTargetProvider(
+			[]string{filepath.Join(dir, "*.json")},
+			scraper.WithProviderLogger(logger),
+			scraper.WithRefreshInterval(10*time.Millisecond),
+		)
+		Expect(metricURLs(provider())).To(HaveLen(1))
+
+		writeFile("a.json", `[{"targets": ["http://10.0.0.1:9100"]}]`)
+
+		Consistently(func() []string {
+			return metricURLs(provider())
+		}, 100*time.Millisecond).Should(Equal([]string{"https://10.0.0.1:9100/metrics"}))
+	})
+})
+
+func metricURLs(targets []scraper.Target) []string {
+	var urls []string
+	for _, t := range targets {
+		urls = append(urls, t.MetricURL)
+	}
+	return urls
+}
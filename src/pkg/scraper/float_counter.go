@@ -0,0 +1,182 @@
+package scraper
+
+import (
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	io_prometheus_client "github.com/prometheus/client_model/go"
+
+	"code.cloudfoundry.org/go-loggregator/v9"
+)
+
+// Strategies for counters with values that do not fit a loggregator counter,
+// like the fractional process_cpu_seconds_total.
+const (
+	// FloatCounterDiscard drops the counters.
+	FloatCounterDiscard = "discard"
+	// FloatCounterGauge emits the counters as gauges.
+	FloatCounterGauge = "gauge"
+	// FloatCounterScale multiplies the counters by the scale factor and
+	// emits the rounded value as counter with a counter_scale_factor tag.
+	FloatCounterScale = "scale"
+)
+
+// scaleFactorTag is the tag that holds the factor of scaled counters.
+const scaleFactorTag = "counter_scale_factor"
+
+// secondsCounterSuffix marks counters that measure durations in seconds.
+// They usually have fractional values, so they always use a float counter
+// strategy that keeps the series.
+const secondsCounterSuffix = "_seconds_total"
+
+// WithFloatCounters sets the strategy for counters with fractional,
+// negative or non-finite values. It defaults to FloatCounterDiscard. With
+// FloatCounterGauge or FloatCounterScale all counters whose name ends in
+// _seconds_total use the strategy as well, and once another series had such
+// a value its later values use the strategy so that the series does not
+// switch between representations again. The scale factor is only used by
+// FloatCounterScale.
+func WithFloatCounters(strategy string, scaleFactor float64) ScrapeOption {
+	return func(s *Scraper) {
+		s.floatCounterStrategy = strategy
+		s.floatCounterScale = scaleFactor
+	}
+}
+
+func (s *Scraper) emitCounter(e MetricsEmitter, t Target, fc floatCounterSeries, sourceID, name string, tags map[string]string, metric *io_prometheus_client.Metric) {
+	val := metric.GetCounter().GetValue()
+	exemplar := exemplarTags("exemplar_", metric.GetCounter().GetExemplar())
+
+	if !s.isFloatCounter(fc, seriesKey(sourceID, t.InstanceID, name, tags), name, val) {
+		e.EmitCounter(
+			name,
+			loggregator.WithTotal(uint64(val)),
+			loggregator.WithCounterSourceInfo(sourceID, t.InstanceID),
+			loggregator.WithEnvelopeTags(tags),
+			loggregator.WithEnvelopeTags(exemplar),
+		)
+		return
+	}
+
+	switch s.floatCounterStrategy {
+	case FloatCounterGauge:
+		for k, v := range exemplar {
+			tags[k] = v
+		}
+		s.emitValueAsGauge(e, sourceID, t.InstanceID, name, tags, val)
+	case FloatCounterScale:
+		scaled := math.Round(val * s.floatCounterScale)
+		if scaled < 0 || scaled >= math.MaxUint64 || math.IsNaN(scaled) {
+			s.countDroppedSeries(t, "float_counter", 1)
+			return
+		}
+		e.EmitCounter(
+			name,
+			loggregator.WithTotal(uint64(scaled)),
+			loggregator.WithCounterSourceInfo(sourceID, t.InstanceID),
+			loggregator.WithEnvelopeTags(tags),
+			loggregator.WithEnvelopeTags(exemplar),
+			loggregator.WithEnvelopeTag(scaleFactorTag, strconv.FormatFloat(s.floatCounterScale, 'g', -1, 64)),
+		)
+	default:
+		s.countDroppedSeries(t, "float_counter", 1)
+	}
+}
+
+// floatCounterSeries holds the keys of the series of a target that use the
+// float counter strategy. The series of the previous scrape are read and the
+// series of the current scrape are written, so that series that are gone are
+// forgotten.
+type floatCounterSeries struct {
+	prev map[string]bool
+	next map[string]bool
+}
+
+// isFloatCounter returns true if the value does not fit a loggregator
+// counter, if the series had such a value before or if the counter measures
+// seconds and the strategy keeps the series.
+func (s *Scraper) isFloatCounter(fc floatCounterSeries, key, name string, val float64) bool {
+	if fc.prev[key] || fc.next[key] {
+		fc.next[key] = true
+		return true
+	}
+
+	keep := s.floatCounterStrategy == FloatCounterGauge || s.floatCounterStrategy == FloatCounterScale
+	integer := val >= 0 && val < math.MaxUint64 && val == math.Trunc(val)
+	if integer && !(keep && strings.HasSuffix(name, secondsCounterSuffix)) {
+		return false
+	}
+	if keep {
+		fc.next[key] = true
+	}
+	return true
+}
+
+// startFloatCounters returns the float counter series of the target for a
+// scrape.
+func (s *Scraper) startFloatCounters(t Target) floatCounterSeries {
+	s.floatCountersMu.Lock()
+	defer s.floatCountersMu.Unlock()
+	return floatCounterSeries{
+		prev: s.floatCounters[targetKey(t)],
+		next: make(map[string]bool),
+	}
+}
+
+// finishFloatCounters keeps the float counter series of a successful scrape
+// of the target.
+func (s *Scraper) finishFloatCounters(t Target, fc floatCounterSeries) {
+	s.floatCountersMu.Lock()
+	defer s.floatCountersMu.Unlock()
+	if len(fc.next) == 0 {
+		delete(s.floatCounters, targetKey(t))
+		return
+	}
+	s.floatCounters[targetKey(t)] = fc.next
+}
+
+// pruneFloatCounters forgets the float counter series of targets that are
+// no longer scraped.
+func (s *Scraper) pruneFloatCounters(targets []Target) {
+	keys := make(map[string]bool, len(targets))
+	for _, t := range targets {
+		keys[targetKey(t)] = true
+	}
+
+	s.floatCountersMu.Lock()
+	defer s.floatCountersMu.Unlock()
+	for k := range s.floatCounters {
+		if !keys[k] {
+			delete(s.floatCounters, k)
+		}
+	}
+}
+
+func targetKey(t Target) string {
+	return t.ID + "\x00" + t.InstanceID + "\x00" + t.MetricURL
+}
+
+// seriesKey identifies a series by its source, instance, name and tags.
+func seriesKey(sourceID, instanceID, name string, tags map[string]string) string {
+	names := make([]string, 0, len(tags))
+	for k := range tags {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+
+	var b strings.Builder
+	b.WriteString(sourceID)
+	b.WriteByte(0)
+	b.WriteString(instanceID)
+	b.WriteByte(0)
+	b.WriteString(name)
+	for _, k := range names {
+		b.WriteByte(0)
+		b.WriteString(k)
+		b.WriteByte('=')
+		b.WriteString(tags[k])
+	}
+	return b.String()
+}
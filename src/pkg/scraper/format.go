@@ -0,0 +1,69 @@
+package scraper
+
+import (
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+
+	io_prometheus_client "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+)
+
+// acceptHeader is sent to targets that do not configure their own Accept
+// header. It prefers the delimited protobuf format, then OpenMetrics and
+// then the Prometheus text format.
+const acceptHeader = "application/vnd.google.protobuf;proto=io.prometheus.client.MetricFamily;encoding=delimited;q=0.7," +
+	"application/openmetrics-text;version=1.0.0;q=0.5," +
+	"text/plain;version=0.0.4;q=0.3," +
+	"*/*;q=0.1"
+
+// requestHeaders returns the headers of the target with the Accept header
+// used for content negotiation.
+func requestHeaders(target Target) map[string]string {
+	headers := make(map[string]string, len(target.Headers)+1)
+	for k, v := range target.Headers {
+		headers[k] = v
+	}
+	for k := range headers {
+		if strings.EqualFold(k, "Accept") {
+			return headers
+		}
+	}
+	headers["Accept"] = acceptHeader
+	return headers
+}
+
+// parseMetrics parses the metric families of the response in the format
+// given by its Content-Type. Responses without a known format are parsed as
+// the Prometheus text format.
+func parseMetrics(resp *http.Response) (map[string]*io_prometheus_client.MetricFamily, error) {
+	mediaType, _, err := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if err == nil && mediaType == expfmt.OpenMetricsType {
+		return parseOpenMetrics(resp.Body)
+	}
+
+	if expfmt.ResponseFormat(resp.Header) == expfmt.FmtProtoDelim {
+		return parseProtobuf(resp.Body)
+	}
+
+	p := &expfmt.TextParser{}
+	return p.TextToMetricFamilies(resp.Body)
+}
+
+func parseProtobuf(r io.Reader) (map[string]*io_prometheus_client.MetricFamily, error) {
+	families := make(map[string]*io_prometheus_client.MetricFamily)
+	decoder := expfmt.NewDecoder(r, expfmt.FmtProtoDelim)
+	for {
+		family := &io_prometheus_client.MetricFamily{}
+		err := decoder.Decode(family)
+		if errors.Is(err, io.EOF) {
+			return families, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		families[family.GetName()] = family
+	}
+}
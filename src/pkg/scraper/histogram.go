@@ -0,0 +1,165 @@
+package scraper
+
+import (
+	"strconv"
+
+	io_prometheus_client "github.com/prometheus/client_model/go"
+
+	"code.cloudfoundry.org/go-loggregator/v9"
+)
+
+// metricTypeTag is the tag that holds the type of a batched histogram or
+// summary.
+const metricTypeTag = "metric_type"
+
+// emitBatchedHistogram emits a classic or native histogram as a single gauge
+// envelope. The gauge holds the sum and count of the histogram and
+//
+//	<name>_bucket_le_<upper bound>   the cumulative count of each classic bucket
+//	<name>_schema                    the schema of a native histogram
+//	<name>_zero_threshold            the width of the native zero bucket
+//	<name>_zero_count                the count of the native zero bucket
+//	<name>_positive_bucket_<index>   the count of each positive native bucket
+//	<name>_negative_bucket_<index>   the count of each negative native bucket
+//
+// The labels of bucket exemplars are added as <bucket>_exemplar_<label> tags.
+func (s *Scraper) emitBatchedHistogram(e MetricsEmitter, sourceID, instanceID, name string, gauge bool, tags map[string]string, metric *io_prometheus_client.Metric) {
+	histogram := metric.GetHistogram()
+
+	metricType, sumName, countName := "histogram", name+"_sum", name+"_count"
+	if gauge {
+		metricType, sumName, countName = "gaugehistogram", name+"_gsum", name+"_gcount"
+	}
+
+	opts := []loggregator.EmitGaugeOption{
+		loggregator.WithGaugeSourceInfo(sourceID, instanceID),
+		loggregator.WithEnvelopeTags(tags),
+		loggregator.WithEnvelopeTag(metricTypeTag, metricType),
+		loggregator.WithGaugeValue(sumName, histogram.GetSampleSum(), ""),
+		loggregator.WithGaugeValue(countName, sampleCount(histogram), ""),
+	}
+
+	for _, bucket := range histogram.GetBucket() {
+		bucketName := name + "_bucket_le_" + strconv.FormatFloat(bucket.GetUpperBound(), 'g', -1, 64)
+		opts = append(opts,
+			loggregator.WithGaugeValue(bucketName, float64(bucket.GetCumulativeCount()), ""),
+			loggregator.WithEnvelopeTags(exemplarTags(bucketName+"_exemplar_", bucket.GetExemplar())),
+		)
+	}
+
+	if isNativeHistogram(histogram) {
+		zeroCount := histogram.GetZeroCountFloat()
+		if zeroCount <= 0 {
+			zeroCount = float64(histogram.GetZeroCount())
+		}
+		opts = append(opts,
+			loggregator.WithGaugeValue(name+"_schema", float64(histogram.GetSchema()), ""),
+			loggregator.WithGaugeValue(name+"_zero_threshold", histogram.GetZeroThreshold(), ""),
+			loggregator.WithGaugeValue(name+"_zero_count", zeroCount, ""),
+		)
+
+		positive := nativeBuckets(histogram.GetPositiveSpan(), histogram.GetPositiveDelta(), histogram.GetPositiveCount())
+		for _, b := range positive {
+			opts = append(opts, loggregator.WithGaugeValue(name+"_positive_bucket_"+strconv.Itoa(int(b.index)), b.count, ""))
+		}
+		negative := nativeBuckets(histogram.GetNegativeSpan(), histogram.GetNegativeDelta(), histogram.GetNegativeCount())
+		for _, b := range negative {
+			opts = append(opts, loggregator.WithGaugeValue(name+"_negative_bucket_"+strconv.Itoa(int(b.index)), b.count, ""))
+		}
+	}
+
+	e.EmitGauge(opts...)
+}
+
+// emitBatchedSummary emits a summary as a single gauge envelope. The gauge
+// holds the sum and count of the summary and a <name>_quantile_<quantile>
+// value for each quantile.
+func (s *Scraper) emitBatchedSummary(e MetricsEmitter, sourceID, instanceID, name string, tags map[string]string, metric *io_prometheus_client.Metric) {
+	summary := metric.GetSummary()
+
+	opts := []loggregator.EmitGaugeOption{
+		loggregator.WithGaugeSourceInfo(sourceID, instanceID),
+		loggregator.WithEnvelopeTags(tags),
+		loggregator.WithEnvelopeTag(metricTypeTag, "summary"),
+		loggregator.WithGaugeValue(name+"_sum", summary.GetSampleSum(), ""),
+		loggregator.WithGaugeValue(name+"_count", float64(summary.GetSampleCount()), ""),
+	}
+	for _, quantile := range summary.GetQuantile() {
+		quantileName := name + "_quantile_" + strconv.FormatFloat(quantile.GetQuantile(), 'g', -1, 64)
+		opts = append(opts, loggregator.WithGaugeValue(quantileName, quantile.GetValue(), ""))
+	}
+
+	e.EmitGauge(opts...)
+}
+
+// exemplarTags returns the labels of the exemplar as tags with the given
+// prefix.
+func exemplarTags(prefix string, exemplar *io_prometheus_client.Exemplar) map[string]string {
+	if exemplar == nil {
+		return nil
+	}
+
+	tags := make(map[string]string, len(exemplar.GetLabel()))
+	for _, l := range exemplar.GetLabel() {
+		tags[prefix+l.GetName()] = l.GetValue()
+	}
+	return tags
+}
+
+func sampleCount(histogram *io_prometheus_client.Histogram) float64 {
+	if histogram.GetSampleCountFloat() > 0 {
+		return histogram.GetSampleCountFloat()
+	}
+	return float64(histogram.GetSampleCount())
+}
+
+func isNativeHistogram(histogram *io_prometheus_client.Histogram) bool {
+	return histogram.Schema != nil ||
+		histogram.ZeroThreshold != nil ||
+		len(histogram.GetPositiveSpan()) > 0 ||
+		len(histogram.GetNegativeSpan()) > 0
+}
+
+type nativeBucket struct {
+	index int32
+	count float64
+}
+
+// nativeBuckets returns the buckets of a native histogram span list with
+// their absolute counts. Integer histograms encode the counts as deltas to
+// the previous bucket and float histograms as absolute counts.
+func nativeBuckets(spans []*io_prometheus_client.BucketSpan, deltas []int64, counts []float64) []nativeBucket {
+	var (
+		buckets []nativeBucket
+		index   int32
+		count   int64
+		i       int
+	)
+	for n, span := range spans {
+		if n == 0 {
+			index = span.GetOffset()
+		} else {
+			index += span.GetOffset()
+		}
+
+		for j := uint32(0); j < span.GetLength(); j++ {
+			b := nativeBucket{index: index}
+			if len(counts) > 0 {
+				if i >= len(counts) {
+					return buckets
+				}
+				b.count = counts[i]
+			} else {
+				if i >= len(deltas) {
+					return buckets
+				}
+				count += deltas[i]
+				b.count = float64(count)
+			}
+			buckets = append(buckets, b)
+			index++
+			i++
+		}
+	}
+	return buckets
+}
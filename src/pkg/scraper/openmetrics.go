@@ -0,0 +1,471 @@
+package scraper
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	io_prometheus_client "github.com/prometheus/client_model/go"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// openMetricsSuffixes are the sample name suffixes of each OpenMetrics
+// metric type.
+var openMetricsSuffixes = map[string][]string{
+	"counter":        {"_total", "_created"},
+	"gauge":          {""},
+	"unknown":        {""},
+	"info":           {"_info"},
+	"stateset":       {""},
+	"histogram":      {"_bucket", "_count", "_sum", "_created"},
+	"gaugehistogram": {"_bucket", "_gcount", "_gsum"},
+	"summary":        {"", "_count", "_sum", "_created"},
+}
+
+// openMetricsParser parses the OpenMetrics text format into metric families
+// so that they are handled like the Prometheus formats. Counters are named
+// with their _total suffix and info metrics with their _info suffix as they
+// are in the Prometheus text format. Stateset and info metrics become
+// gauges and unknown metrics become untyped metrics.
+type openMetricsParser struct {
+	families map[string]*io_prometheus_client.MetricFamily
+
+	family     *io_prometheus_client.MetricFamily
+	familyName string
+	familyType string
+	metrics    map[string]*io_prometheus_client.Metric
+}
+
+type openMetricsSample struct {
+	name      string
+	labels    []*io_prometheus_client.LabelPair
+	value     float64
+	timestamp *float64
+	exemplar  *io_prometheus_client.Exemplar
+}
+
+func parseOpenMetrics(r io.Reader) (map[string]*io_prometheus_client.MetricFamily, error) {
+	p := &openMetricsParser{families: make(map[string]*io_prometheus_client.MetricFamily)}
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	var lineNum int
+	for scanner.Scan() {
+		lineNum++
+		line := scanner.Text()
+		if line == "# EOF" {
+			return p.families, nil
+		}
+
+		var err error
+		if strings.HasPrefix(line, "#") {
+			err = p.parseComment(line)
+		} else if line != "" {
+			err = p.parseSample(line)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("openmetrics line %d: %w", lineNum, err)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return nil, errors.New("openmetrics: missing # EOF")
+}
+
+func (p *openMetricsParser) parseComment(line string) error {
+	fields := strings.SplitN(line, " ", 4)
+	if len(fields) < 3 {
+		return nil
+	}
+
+	switch fields[1] {
+	case "TYPE":
+		if len(fields) != 4 {
+			return errors.New("invalid TYPE line")
+		}
+		if _, ok := openMetricsSuffixes[fields[3]]; !ok {
+			return fmt.Errorf("unknown metric type %q", fields[3])
+		}
+		p.startFamily(fields[2], fields[3])
+	case "HELP":
+		if len(fields) < 4 {
+			return nil
+		}
+		if fields[2] != p.familyName {
+			p.startFamily(fields[2], "unknown")
+		}
+		p.family.Help = proto.String(unescapeOpenMetrics(fields[3]))
+	}
+	return nil
+}
+
+func (p *openMetricsParser) startFamily(name, typ string) {
+	var help *string
+	if p.family != nil && p.familyName == name {
+		// The HELP line came before the TYPE line.
+		help = p.family.Help
+		delete(p.families, p.family.GetName())
+	}
+
+	p.familyName = name
+	p.familyType = typ
+	p.metrics = make(map[string]*io_prometheus_client.Metric)
+
+	metricName := name
+	var metricType io_prometheus_client.MetricType
+	switch typ {
+	case "counter":
+		metricName = name + "_total"
+		metricType = io_prometheus_client.MetricType_COUNTER
+	case "gauge", "stateset":
+		metricType = io_prometheus_client.MetricType_GAUGE
+	case "info":
+		metricName = name + "_info"
+		metricType = io_prometheus_client.MetricType_GAUGE
+	case "histogram":
+		metricType = io_prometheus_client.MetricType_HISTOGRAM
+	case "gaugehistogram":
+		metricType = io_prometheus_client.MetricType_GAUGE_HISTOGRAM
+	case "summary":
+		metricType = io_prometheus_client.MetricType_SUMMARY
+	default:
+		metricType = io_prometheus_client.MetricType_UNTYPED
+	}
+
+	p.family = &io_prometheus_client.MetricFamily{
+		Name: proto.String(metricName),
+		Help: help,
+		Type: metricType.Enum(),
+	}
+	p.families[metricName] = p.family
+}
+
+func (p *openMetricsParser) parseSample(line string) error {
+	s, err := parseOpenMetricsSample(line)
+	if err != nil {
+		return err
+	}
+
+	suffix, ok := p.suffix(s.name)
+	if !ok {
+		// Samples without a TYPE line are unknown metrics.
+		p.startFamily(s.name, "unknown")
+		suffix = ""
+	}
+
+	key, labels := p.metricLabels(s.labels)
+	m, ok := p.metrics[key]
+	if !ok {
+		m = &io_prometheus_client.Metric{Label: labels}
+		p.metrics[key] = m
+		p.family.Metric = append(p.family.Metric, m)
+	}
+	if s.timestamp != nil {
+		m.TimestampMs = proto.Int64(int64(*s.timestamp * 1000))
+	}
+
+	return p.setValue(m, suffix, s)
+}
+
+// suffix returns the suffix of the sample name if it belongs to the current
+// family.
+func (p *openMetricsParser) suffix(name string) (string, bool) {
+	if p.family == nil || !strings.HasPrefix(name, p.familyName) {
+		return "", false
+	}
+	suffix := name[len(p.familyName):]
+	for _, s := range openMetricsSuffixes[p.familyType] {
+		if s == suffix {
+			return suffix, true
+		}
+	}
+	return "", false
+}
+
+// metricLabels returns the labels that identify the metric of a sample
+// along with a key for them. The le and quantile labels identify buckets
+// and quantiles of a metric.
+func (p *openMetricsParser) metricLabels(labels []*io_prometheus_client.LabelPair) (string, []*io_prometheus_client.LabelPair) {
+	var metricLabels []*io_prometheus_client.LabelPair
+	for _, l := range labels {
+		if (l.GetName() == "le" && (p.familyType == "histogram" || p.familyType == "gaugehistogram")) ||
+			(l.GetName() == "quantile" && p.familyType == "summary") {
+			continue
+		}
+		metricLabels = append(metricLabels, l)
+	}
+	sort.Slice(metricLabels, func(i, j int) bool {
+		return metricLabels[i].GetName() < metricLabels[j].GetName()
+	})
+
+	var key strings.Builder
+	for _, l := range metricLabels {
+		key.WriteString(l.GetName())
+		key.WriteByte(0)
+		key.WriteString(l.GetValue())
+		key.WriteByte(0)
+	}
+	return key.String(), metricLabels
+}
+
+func (p *openMetricsParser) setValue(m *io_prometheus_client.Metric, suffix string, s openMetricsSample) error {
+	switch p.familyType {
+	case "counter":
+		if m.Counter == nil {
+			m.Counter = &io_prometheus_client.Counter{}
+		}
+		if suffix == "_created" {
+			m.Counter.CreatedTimestamp = toTimestamp(s.value)
+			return nil
+		}
+		m.Counter.Value = proto.Float64(s.value)
+		m.Counter.Exemplar = s.exemplar
+	case "gauge", "info", "stateset":
+		m.Gauge = &io_prometheus_client.Gauge{Value: proto.Float64(s.value)}
+	case "unknown":
+		m.Untyped = &io_prometheus_client.Untyped{Value: proto.Float64(s.value)}
+	case "histogram", "gaugehistogram":
+		if m.Histogram == nil {
+			m.Histogram = &io_prometheus_client.Histogram{}
+		}
+		h := m.Histogram
+		switch suffix {
+		case "_bucket":
+			le, err := labelValue(s.labels, "le")
+			if err != nil {
+				return err
+			}
+			h.Bucket = append(h.Bucket, &io_prometheus_client.Bucket{
+				UpperBound:      proto.Float64(le),
+				CumulativeCount: proto.Uint64(uint64(s.value)),
+				Exemplar:        s.exemplar,
+			})
+		case "_count", "_gcount":
+			h.SampleCount = proto.Uint64(uint64(s.value))
+		case "_sum", "_gsum":
+			h.SampleSum = proto.Float64(s.value)
+		case "_created":
+			h.CreatedTimestamp = toTimestamp(s.value)
+		}
+	case "summary":
+		if m.Summary == nil {
+			m.Summary = &io_prometheus_client.Summary{}
+		}
+		sm := m.Summary
+		switch suffix {
+		case "":
+			q, err := labelValue(s.labels, "quantile")
+			if err != nil {
+				return err
+			}
+			sm.Quantile = append(sm.Quantile, &io_prometheus_client.Quantile{
+				Quantile: proto.Float64(q),
+				Value:    proto.Float64(s.value),
+			})
+		case "_count":
+			sm.SampleCount = proto.Uint64(uint64(s.value))
+		case "_sum":
+			sm.SampleSum = proto.Float64(s.value)
+		case "_created":
+			sm.CreatedTimestamp = toTimestamp(s.value)
+		}
+	}
+	return nil
+}
+
+func labelValue(labels []*io_prometheus_client.LabelPair, name string) (float64, error) {
+	for _, l := range labels {
+		if l.GetName() == name {
+			return parseOpenMetricsFloat(l.GetValue())
+		}
+	}
+	return 0, fmt.Errorf("missing %s label", name)
+}
+
+func toTimestamp(seconds float64) *timestamppb.Timestamp {
+	sec, frac := math.Modf(seconds)
+	return timestamppb.New(time.Unix(int64(sec), int64(frac*1e9)))
+}
+
+// parseOpenMetricsSample parses a sample line of the form
+//
+//	name{label="value",...} value [timestamp] [# {label="value",...} value [timestamp]]
+func parseOpenMetricsSample(line string) (openMetricsSample, error) {
+	var s openMetricsSample
+
+	end := strings.IndexAny(line, "{ ")
+	if end <= 0 {
+		return s, errors.New("invalid sample")
+	}
+	s.name = line[:end]
+	rest := line[end:]
+
+	if rest[0] == '{' {
+		labels, n, err := parseOpenMetricsLabels(rest)
+		if err != nil {
+			return s, err
+		}
+		s.labels = labels
+		rest = rest[n:]
+	}
+
+	var exemplar string
+	if i := strings.Index(rest, " # "); i >= 0 {
+		exemplar = rest[i+3:]
+		rest = rest[:i]
+	}
+
+	fields := strings.Fields(rest)
+	if len(fields) < 1 || len(fields) > 2 {
+		return s, errors.New("invalid sample value")
+	}
+	v, err := parseOpenMetricsFloat(fields[0])
+	if err != nil {
+		return s, err
+	}
+	s.value = v
+	if len(fields) == 2 {
+		ts, err := parseOpenMetricsFloat(fields[1])
+		if err != nil {
+			return s, fmt.Errorf("invalid timestamp: %w", err)
+		}
+		s.timestamp = &ts
+	}
+
+	if exemplar != "" {
+		e, err := parseOpenMetricsExemplar(exemplar)
+		if err != nil {
+			return s, err
+		}
+		s.exemplar = e
+	}
+
+	return s, nil
+}
+
+func parseOpenMetricsExemplar(text string) (*io_prometheus_client.Exemplar, error) {
+	if text == "" || text[0] != '{' {
+		return nil, errors.New("invalid exemplar")
+	}
+	labels, n, err := parseOpenMetricsLabels(text)
+	if err != nil {
+		return nil, err
+	}
+
+	fields := strings.Fields(text[n:])
+	if len(fields) < 1 || len(fields) > 2 {
+		return nil, errors.New("invalid exemplar value")
+	}
+	v, err := parseOpenMetricsFloat(fields[0])
+	if err != nil {
+		return nil, err
+	}
+
+	e := &io_prometheus_client.Exemplar{Label: labels, Value: proto.Float64(v)}
+	if len(fields) == 2 {
+		ts, err := parseOpenMetricsFloat(fields[1])
+		if err != nil {
+			return nil, fmt.Errorf("invalid exemplar timestamp: %w", err)
+		}
+		e.Timestamp = toTimestamp(ts)
+	}
+	return e, nil
+}
+
+// parseOpenMetricsLabels parses the labels at the start of text, which
+// starts with an opening brace. It returns the labels and the number of
+// bytes up to and including the closing brace.
+func parseOpenMetricsLabels(text string) ([]*io_prometheus_client.LabelPair, int, error) {
+	var labels []*io_prometheus_client.LabelPair
+	i := 1
+	for {
+		if i >= len(text) {
+			return nil, 0, errors.New("unterminated labels")
+		}
+		if text[i] == '}' {
+			return labels, i + 1, nil
+		}
+
+		eq := strings.IndexByte(text[i:], '=')
+		if eq <= 0 || i+eq+1 >= len(text) || text[i+eq+1] != '"' {
+			return nil, 0, errors.New("invalid label")
+		}
+		name := text[i : i+eq]
+		i += eq + 2
+
+		var value strings.Builder
+		for {
+			if i >= len(text) {
+				return nil, 0, errors.New("unterminated label value")
+			}
+			c := text[i]
+			if c == '"' {
+				i++
+				break
+			}
+			if c == '\\' && i+1 < len(text) {
+				i++
+				switch text[i] {
+				case 'n':
+					value.WriteByte('\n')
+				default:
+					value.WriteByte(text[i])
+				}
+				i++
+				continue
+			}
+			value.WriteByte(c)
+			i++
+		}
+		labels = append(labels, &io_prometheus_client.LabelPair{
+			Name:  proto.String(name),
+			Value: proto.String(value.String()),
+		})
+
+		if i < len(text) && text[i] == ',' {
+			i++
+		}
+	}
+}
+
+func parseOpenMetricsFloat(s string) (float64, error) {
+	switch s {
+	case "+Inf", "Inf":
+		return math.Inf(1), nil
+	case "-Inf":
+		return math.Inf(-1), nil
+	case "NaN":
+		return math.NaN(), nil
+	}
+	return strconv.ParseFloat(s, 64)
+}
+
+// unescapeOpenMetrics unescapes the HELP text of a metric family.
+func unescapeOpenMetrics(s string) string {
+	if !strings.Contains(s, `\`) {
+		return s
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' || i+1 == len(s) {
+			b.WriteByte(s[i])
+			continue
+		}
+		i++
+		if s[i] == 'n' {
+			b.WriteByte('\n')
+		} else {
+			b.WriteByte(s[i])
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,165 @@
+package scraper
+
+import (
+	"crypto/md5" //nolint:gosec
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Relabel actions.
+const (
+	RelabelReplace   = "replace"
+	RelabelKeep      = "keep"
+	RelabelDrop      = "drop"
+	RelabelHashMod   = "hashmod"
+	RelabelLabelMap  = "labelmap"
+	RelabelLabelDrop = "labeldrop"
+	RelabelLabelKeep = "labelkeep"
+)
+
+// metricNameLabel holds the name of a metric during relabeling.
+const metricNameLabel = "__name__"
+
+// RelabelConfig is a Prometheus style relabel rule that is applied to the
+// labels of scraped metrics. The name of the metric is available as the
+// __name__ label.
+type RelabelConfig struct {
+	SourceLabels []string `yaml:"source_labels"`
+	Separator    string   `yaml:"separator"`
+	TargetLabel  string   `yaml:"target_label"`
+	Regex        string   `yaml:"regex"`
+	Modulus      uint64   `yaml:"modulus"`
+	Replacement  string   `yaml:"replacement"`
+	Action       string   `yaml:"action"`
+}
+
+// UnmarshalYAML sets the Prometheus defaults of the fields that are not
+// given.
+func (c *RelabelConfig) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	type plain RelabelConfig
+	*c = RelabelConfig{
+		Separator:   ";",
+		Regex:       "(.*)",
+		Replacement: "$1",
+		Action:      RelabelReplace,
+	}
+	return unmarshal((*plain)(c))
+}
+
+type relabelRule struct {
+	RelabelConfig
+	regex *regexp.Regexp
+}
+
+// Relabeler applies relabel rules in order.
+type Relabeler struct {
+	rules []relabelRule
+}
+
+// NewRelabeler validates the relabel configs and compiles their regular
+// expressions. Like Prometheus, the regular expressions are anchored.
+func NewRelabeler(configs []RelabelConfig) (*Relabeler, error) {
+	r := &Relabeler{}
+	for i, c := range configs {
+		regex, err := regexp.Compile("^(?:" + c.Regex + ")$")
+		if err != nil {
+			return nil, fmt.Errorf("relabel config %d: invalid regex: %w", i, err)
+		}
+
+		switch c.Action {
+		case RelabelReplace:
+			if c.TargetLabel == "" {
+				return nil, fmt.Errorf("relabel config %d: target_label is required for %s", i, c.Action)
+			}
+		case RelabelHashMod:
+			if c.TargetLabel == "" || c.Modulus == 0 {
+				return nil, fmt.Errorf("relabel config %d: target_label and modulus are required for %s", i, c.Action)
+			}
+		case RelabelKeep, RelabelDrop, RelabelLabelMap, RelabelLabelDrop, RelabelLabelKeep:
+		default:
+			return nil, fmt.Errorf("relabel config %d: unknown action %q", i, c.Action)
+		}
+
+		r.rules = append(r.rules, relabelRule{RelabelConfig: c, regex: regex})
+	}
+	return r, nil
+}
+
+// Relabel applies the rules to the labels. It returns false if the metric
+// is dropped.
+func (r *Relabeler) Relabel(labels map[string]string) bool {
+	for _, rule := range r.rules {
+		if !rule.apply(labels) {
+			return false
+		}
+	}
+	return true
+}
+
+func (r relabelRule) apply(labels map[string]string) bool {
+	values := make([]string, 0, len(r.SourceLabels))
+	for _, l := range r.SourceLabels {
+		values = append(values, labels[l])
+	}
+	value := strings.Join(values, r.Separator)
+
+	switch r.Action {
+	case RelabelKeep:
+		return r.regex.MatchString(value)
+	case RelabelDrop:
+		return !r.regex.MatchString(value)
+	case RelabelReplace:
+		indexes := r.regex.FindStringSubmatchIndex(value)
+		if indexes == nil {
+			return true
+		}
+		target := string(r.regex.ExpandString(nil, r.TargetLabel, value, indexes))
+		if target == "" {
+			return true
+		}
+		res := string(r.regex.ExpandString(nil, r.Replacement, value, indexes))
+		if res == "" {
+			delete(labels, target)
+			return true
+		}
+		labels[target] = res
+	case RelabelHashMod:
+		labels[r.TargetLabel] = strconv.FormatUint(sum64(md5.Sum([]byte(value)))%r.Modulus, 10) //nolint:gosec
+	case RelabelLabelMap:
+		mapped := make(map[string]string)
+		for name, v := range labels {
+			if r.regex.MatchString(name) {
+				mapped[r.regex.ReplaceAllString(name, r.Replacement)] = v
+			}
+		}
+		for name, v := range mapped {
+			labels[name] = v
+		}
+	case RelabelLabelDrop:
+		for name := range labels {
+			if name != metricNameLabel && r.regex.MatchString(name) {
+				delete(labels, name)
+			}
+		}
+	case RelabelLabelKeep:
+		for name := range labels {
+			if name != metricNameLabel && !r.regex.MatchString(name) {
+				delete(labels, name)
+			}
+		}
+	}
+	return true
+}
+
+// sum64 returns the last 8 bytes of the hash as an integer like Prometheus
+// does for hashmod.
+func sum64(hash [md5.Size]byte) uint64 {
+	var s uint64
+	for i, b := range hash {
+		shift := uint64((md5.Size - 1 - i) * 8)
+		s |= uint64(b) << shift
+	}
+	return s
+}
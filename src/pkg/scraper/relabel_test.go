@@ -0,0 +1,111 @@
+package scraper_test
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"code.cloudfoundry.org/loggregator-agent-release/src/pkg/scraper"
+)
+
+var _ = Describe("Relabeler", func() {
+	relabel := func(configs []scraper.RelabelConfig, labels map[string]string) (map[string]string, bool) {
+		r, err := scraper.NewRelabeler(configs)
+		Expect(err).ToNot(HaveOccurred())
+		keep := r.Relabel(labels)
+		return labels, keep
+	}
+
+	config := func(c scraper.RelabelConfig) scraper.RelabelConfig {
+		if c.Separator == "" {
+			c.Separator = ";"
+		}
+		if c.Regex == "" {
+			c.Regex = "(.*)"
+		}
+		if c.Replacement == "" {
+			c.Replacement = "$1"
+		}
+		if c.Action == "" {
+			c.Action = scraper.RelabelReplace
+		}
+		return c
+	}
+
+	It("keeps metrics that match", func() {
+		_, keep := relabel([]scraper.RelabelConfig{
+			config(scraper.RelabelConfig{SourceLabels: []string{"__name__"}, Regex: "http_.*", Action: scraper.RelabelKeep}),
+		}, map[string]string{"__name__": "http_requests_total"})
+		Expect(keep).To(BeTrue())
+
+		_, keep = relabel([]scraper.RelabelConfig{
+			config(scraper.RelabelConfig{SourceLabels: []string{"__name__"}, Regex: "http_.*", Action: scraper.RelabelKeep}),
+		}, map[string]string{"__name__": "go_goroutines"})
+		Expect(keep).To(BeFalse())
+	})
+
+	It("drops metrics that match", func() {
+		_, keep := relabel([]scraper.RelabelConfig{
+			config(scraper.RelabelConfig{SourceLabels: []string{"__name__"}, Regex: "go_.*", Action: scraper.RelabelDrop}),
+		}, map[string]string{"__name__": "go_goroutines"})
+		Expect(keep).To(BeFalse())
+	})
+
+	It("anchors the regex", func() {
+		_, keep := relabel([]scraper.RelabelConfig{
+			config(scraper.RelabelConfig{SourceLabels: []string{"__name__"}, Regex: "go", Action: scraper.RelabelDrop}),
+		}, map[string]string{"__name__": "go_goroutines"})
+		Expect(keep).To(BeTrue())
+	})
+
+	It("replaces labels", func() {
+		labels, keep := relabel([]scraper.RelabelConfig{
+			config(scraper.RelabelConfig{
+				SourceLabels: []string{"method", "code"},
+				Separator:    "_",
+				Regex:        "(.*)_(5..)",
+				TargetLabel:  "error",
+				Replacement:  "${1}_failed",
+			}),
+			config(scraper.RelabelConfig{SourceLabels: []string{"missing"}, TargetLabel: "method"}),
+		}, map[string]string{"__name__": "requests", "method": "GET", "code": "503"})
+
+		Expect(keep).To(BeTrue())
+		Expect(labels).To(Equal(map[string]string{
+			"__name__": "requests",
+			"code":     "503",
+			"error":    "GET_failed",
+		}))
+	})
+
+	It("hashes labels into shards", func() {
+		labels, _ := relabel([]scraper.RelabelConfig{
+			config(scraper.RelabelConfig{SourceLabels: []string{"path"}, TargetLabel: "shard", Modulus: 8, Action: scraper.RelabelHashMod}),
+		}, map[string]string{"path": "/v2/apps"})
+
+		Expect(labels).To(HaveKeyWithValue("shard", "6"))
+	})
+
+	It("maps, drops and keeps labels by name", func() {
+		labels, _ := relabel([]scraper.RelabelConfig{
+			config(scraper.RelabelConfig{Regex: "meta_(.*)", Action: scraper.RelabelLabelMap}),
+			config(scraper.RelabelConfig{Regex: "meta_.*|id", Action: scraper.RelabelLabelDrop}),
+			config(scraper.RelabelConfig{Regex: "zone|path", Action: scraper.RelabelLabelKeep}),
+		}, map[string]string{"__name__": "requests", "meta_zone": "z1", "id": "42", "path": "/", "code": "200"})
+
+		Expect(labels).To(Equal(map[string]string{
+			"__name__": "requests",
+			"zone":     "z1",
+			"path":     "/",
+		}))
+	})
+
+	DescribeTable("rejects invalid configs", func(c scraper.RelabelConfig) {
+		_, err := scraper.NewRelabeler([]scraper.RelabelConfig{config(c)})
+		Expect(err).To(HaveOccurred())
+	},
+		Entry("invalid regex", scraper.RelabelConfig{Regex: "(", Action: scraper.RelabelDrop}),
+		Entry("unknown action", scraper.RelabelConfig{Action: "rename"}),
+		Entry("replace without target label", scraper.RelabelConfig{}),
+		Entry("hashmod without modulus", scraper.RelabelConfig{TargetLabel: "shard", Action: scraper.RelabelHashMod}),
+	)
+})
@@ -6,12 +6,13 @@ import (
 	"log"
 	"net/http"
 	"strconv"
+	"strings"
 	"sync"
 	"time"
 
 	metrics "code.cloudfoundry.org/go-metric-registry"
 	io_prometheus_client "github.com/prometheus/client_model/go"
-	"github.com/prometheus/common/expfmt"
+	"google.golang.org/protobuf/proto"
 
 	"code.cloudfoundry.org/go-loggregator/v9"
 )
@@ -24,6 +25,20 @@ type Scraper struct {
 	failedScrapes  metrics.Gauge
 	scrapeDuration metrics.Gauge
 	defaultID      string
+
+	batchHistograms bool
+	batchGauges     bool
+	honorTimestamps bool
+	scrapeSeries    bool
+
+	droppedSeriesMetrics counterClient
+	droppedSeriesMu      sync.Mutex
+	droppedSeries        map[string]metrics.Counter
+
+	floatCounterStrategy string
+	floatCounterScale    float64
+	floatCountersMu      sync.Mutex
+	floatCounters        map[string]map[string]bool
 }
 
 type TargetProvider func() []Target
@@ -36,6 +51,12 @@ type Target struct {
 	MetricURL   string
 	Headers     map[string]string
 	DefaultTags map[string]string
+
+	// Relabeler is applied to each scraped metric before it is emitted.
+	Relabeler *Relabeler
+	// SampleLimit fails scrapes with more metrics after relabeling. Zero
+	// means no limit.
+	SampleLimit int
 }
 
 type MetricsEmitter interface {
@@ -47,6 +68,10 @@ type metricsClient interface {
 	NewGauge(name, helpText string, opts ...metrics.MetricOption) metrics.Gauge
 }
 
+type counterClient interface {
+	NewCounter(name, helpText string, opts ...metrics.MetricOption) metrics.Counter
+}
+
 type MetricsGetter func(addr string, headers map[string]string) (*http.Response, error)
 
 func New(
@@ -64,6 +89,11 @@ func New(
 		urlsScraped:    &defaultGauge{},
 		scrapeDuration: &defaultGauge{},
 		failedScrapes:  &defaultGauge{},
+		droppedSeries:  make(map[string]metrics.Counter),
+
+		floatCounterStrategy: FloatCounterDiscard,
+		floatCounterScale:    1,
+		floatCounters:        make(map[string]map[string]bool),
 	}
 
 	for _, o := range opts {
@@ -95,6 +125,31 @@ func WithMetricsClient(m metricsClient) ScrapeOption {
 	}
 }
 
+// WithBatchedHistograms emits each histogram and summary as a single gauge
+// envelope holding all of its values instead of one envelope per value.
+func WithBatchedHistograms(batched bool) ScrapeOption {
+	return func(s *Scraper) {
+		s.batchHistograms = batched
+	}
+}
+
+// WithScrapeSeries emits the up, scrape_duration_seconds,
+// scrape_samples_scraped and scrape_samples_post_metric_relabeling series of
+// each target after every scrape, like Prometheus does.
+func WithScrapeSeries(enabled bool) ScrapeOption {
+	return func(s *Scraper) {
+		s.scrapeSeries = enabled
+	}
+}
+
+// WithDroppedSeriesMetrics counts the series of each target that are
+// dropped by relabeling, the sample limit or the float counter strategy.
+func WithDroppedSeriesMetrics(m counterClient) ScrapeOption {
+	return func(s *Scraper) {
+		s.droppedSeriesMetrics = m
+	}
+}
+
 func (s *Scraper) Scrape() error {
 	start := time.Now()
 	defer func() {
@@ -111,7 +166,17 @@ func (s *Scraper) Scrape() error {
 		wg.Add(1)
 
 		go func(target Target) {
+			start := time.Now()
 			scrapeResult, err := s.scrape(target)
+			duration := time.Since(start)
+
+			var samples scrapeSamples
+			if err == nil {
+				samples, err = s.emitMetrics(scrapeResult, target)
+			}
+			if s.scrapeSeries {
+				s.emitScrapeSeries(target, err == nil, duration, samples)
+			}
 			if err != nil {
 				errs <- &ScrapeError{
 					ID:         target.ID,
@@ -121,13 +186,13 @@ func (s *Scraper) Scrape() error {
 				}
 			}
 
-			s.emitMetrics(scrapeResult, target)
 			wg.Done()
 		}(a)
 	}
 
 	wg.Wait()
 	close(errs)
+	s.pruneFloatCounters(targetList)
 
 	s.failedScrapes.Set(float64(len(errs)))
 	if len(errs) > 0 {
@@ -142,7 +207,7 @@ func (s *Scraper) Scrape() error {
 }
 
 func (s *Scraper) scrape(target Target) (map[string]*io_prometheus_client.MetricFamily, error) {
-	resp, err := s.metricsGetter(target.MetricURL, target.Headers)
+	resp, err := s.metricsGetter(target.MetricURL, requestHeaders(target))
 	if err != nil {
 		return nil, err
 	}
@@ -157,42 +222,201 @@ func (s *Scraper) scrape(target Target) (map[string]*io_prometheus_client.Metric
 		return nil, fmt.Errorf("unexpected status code %d: %s", resp.StatusCode, body)
 	}
 
-	p := &expfmt.TextParser{}
-	res, err := p.TextToMetricFamilies(resp.Body)
-	if err != nil {
-		return nil, err
-	}
+	return parseMetrics(resp)
+}
 
-	return res, err
+// scrapeSamples counts the series of a scrape before and after relabeling.
+type scrapeSamples struct {
+	scraped        int
+	postRelabeling int
 }
 
-func (s *Scraper) emitMetrics(res map[string]*io_prometheus_client.MetricFamily, t Target) {
+func (s *Scraper) emitMetrics(res map[string]*io_prometheus_client.MetricFamily, t Target) (scrapeSamples, error) {
+	var samples scrapeSamples
 	for _, family := range res {
-		name := family.GetName()
+		samples.scraped += len(family.GetMetric())
+	}
+
+	series := s.relabel(res, t)
+	samples.postRelabeling = len(series)
+	if t.SampleLimit > 0 && len(series) > t.SampleLimit {
+		s.countDroppedSeries(t, "sample_limit", len(series))
+		return samples, fmt.Errorf("sample limit of %d exceeded with %d series", t.SampleLimit, len(series))
+	}
+
+	fc := s.startFloatCounters(t)
+	var batcher *gaugeBatcher
+	if s.batchGauges {
+		batcher = newGaugeBatcher(s.metricsEmitter)
+	}
+
+	for _, se := range series {
+		name, metric := se.name, se.metric
+		sourceID, tags := s.parseTags(metric, t)
+
+		var e MetricsEmitter = s.metricsEmitter
+		if batcher != nil {
+			e = batcher
+		}
+		if s.honorTimestamps && metric.TimestampMs != nil {
+			e = timestampEmitter{MetricsEmitter: e, timestamp: metric.GetTimestampMs() * int64(time.Millisecond)}
+		}
+
+		switch se.metricType {
+		case io_prometheus_client.MetricType_GAUGE:
+			s.emitGauge(e, sourceID, t.InstanceID, name, tags, metric)
+		case io_prometheus_client.MetricType_COUNTER:
+			s.emitCounter(e, t, fc, sourceID, name, tags, metric)
+		case io_prometheus_client.MetricType_HISTOGRAM:
+			if s.batchHistograms {
+				s.emitBatchedHistogram(e, sourceID, t.InstanceID, name, false, tags, metric)
+				continue
+			}
+			s.emitHistogram(e, sourceID, t.InstanceID, name, tags, metric)
+		case io_prometheus_client.MetricType_GAUGE_HISTOGRAM:
+			if s.batchHistograms {
+				s.emitBatchedHistogram(e, sourceID, t.InstanceID, name, true, tags, metric)
+				continue
+			}
+			s.emitGaugeHistogram(e, sourceID, t.InstanceID, name, tags, metric)
+		case io_prometheus_client.MetricType_SUMMARY:
+			if s.batchHistograms {
+				s.emitBatchedSummary(e, sourceID, t.InstanceID, name, tags, metric)
+				continue
+			}
+			s.emitSummary(e, sourceID, t.InstanceID, name, tags, metric)
+		case io_prometheus_client.MetricType_UNTYPED:
+			s.emitUntyped(e, sourceID, t.InstanceID, name, tags, metric)
+		default:
+			log.Printf("unexpected metric type %v for metric: %s\n", se.metricType, name)
+			continue
+		}
+	}
+
+	if batcher != nil {
+		batcher.flush()
+	}
+	s.finishFloatCounters(t, fc)
+
+	return samples, nil
+}
+
+// emitScrapeSeries emits the synthetic series of a scrape of the target as
+// a single gauge envelope with the source ID, instance ID and default tags
+// of the target.
+func (s *Scraper) emitScrapeSeries(t Target, up bool, duration time.Duration, samples scrapeSamples) {
+	sourceID := t.ID
+	if sourceID == "" {
+		sourceID = s.defaultID
+	}
+
+	var upValue float64
+	if up {
+		upValue = 1
+	}
+
+	s.metricsEmitter.EmitGauge(
+		loggregator.WithGaugeValue("up", upValue, ""),
+		loggregator.WithGaugeValue("scrape_duration_seconds", duration.Seconds(), "seconds"),
+		loggregator.WithGaugeValue("scrape_samples_scraped", float64(samples.scraped), ""),
+		loggregator.WithGaugeValue("scrape_samples_post_metric_relabeling", float64(samples.postRelabeling), ""),
+		loggregator.WithGaugeSourceInfo(sourceID, t.InstanceID),
+		loggregator.WithEnvelopeTags(t.DefaultTags),
+	)
+}
+
+type series struct {
+	name       string
+	metricType io_prometheus_client.MetricType
+	metric     *io_prometheus_client.Metric
+}
 
+// relabel applies the relabeler of the target to each metric. The buckets
+// and quantiles of histograms and summaries are relabeled together with
+// the name of the metric family.
+func (s *Scraper) relabel(res map[string]*io_prometheus_client.MetricFamily, t Target) []series {
+	var result []series
+	var dropped int
+	for _, family := range res {
 		for _, metric := range family.GetMetric() {
-			sourceID, tags := s.parseTags(metric, t)
-
-			switch family.GetType() {
-			case io_prometheus_client.MetricType_GAUGE:
-				s.emitGauge(sourceID, t.InstanceID, name, tags, metric)
-			case io_prometheus_client.MetricType_COUNTER:
-				s.emitCounter(sourceID, t.InstanceID, name, tags, metric)
-			case io_prometheus_client.MetricType_HISTOGRAM:
-				s.emitHistogram(sourceID, t.InstanceID, name, tags, metric)
-			case io_prometheus_client.MetricType_SUMMARY:
-				s.emitSummary(sourceID, t.InstanceID, name, tags, metric)
-			case io_prometheus_client.MetricType_UNTYPED:
-				s.emitUntyped(sourceID, t.InstanceID, name, tags, metric)
-			default:
-				log.Printf("unexpected metric type %v for metric: %s\n", family.GetType(), name)
+			se := series{
+				name:       family.GetName(),
+				metricType: family.GetType(),
+				metric:     metric,
+			}
+			if t.Relabeler == nil {
+				result = append(result, se)
 				continue
 			}
+
+			labels := map[string]string{metricNameLabel: se.name}
+			for _, l := range metric.GetLabel() {
+				labels[l.GetName()] = l.GetValue()
+			}
+			if !t.Relabeler.Relabel(labels) || labels[metricNameLabel] == "" {
+				dropped++
+				continue
+			}
+
+			se.name = labels[metricNameLabel]
+			se.metric = relabeledMetric(metric, labels)
+			result = append(result, se)
 		}
 	}
+
+	s.countDroppedSeries(t, "relabel", dropped)
+	return result
+}
+
+// relabeledMetric returns a copy of the metric with the labels. Labels
+// starting with __ are removed.
+func relabeledMetric(m *io_prometheus_client.Metric, labels map[string]string) *io_prometheus_client.Metric {
+	var pairs []*io_prometheus_client.LabelPair
+	for name, value := range labels {
+		if strings.HasPrefix(name, "__") {
+			continue
+		}
+		pairs = append(pairs, &io_prometheus_client.LabelPair{
+			Name:  proto.String(name),
+			Value: proto.String(value),
+		})
+	}
+
+	return &io_prometheus_client.Metric{
+		Label:       pairs,
+		Gauge:       m.Gauge,
+		Counter:     m.Counter,
+		Summary:     m.Summary,
+		Untyped:     m.Untyped,
+		Histogram:   m.Histogram,
+		TimestampMs: m.TimestampMs,
+	}
+}
+
+func (s *Scraper) countDroppedSeries(t Target, reason string, n int) {
+	if s.droppedSeriesMetrics == nil || n == 0 {
+		return
+	}
+
+	s.droppedSeriesMu.Lock()
+	defer s.droppedSeriesMu.Unlock()
+	key := t.ID + "/" + reason
+	c, ok := s.droppedSeries[key]
+	if !ok {
+		c = s.droppedSeriesMetrics.NewCounter(
+			"dropped_series_total",
+			"Total number of scraped series of the target dropped by relabeling, the sample limit or the float counter strategy.",
+			metrics.WithMetricLabels(map[string]string{
+				"scrape_target_source_id": t.ID,
+				"reason":                  reason,
+			}),
+		)
+		s.droppedSeries[key] = c
+	}
+	c.Add(float64(n))
 }
 
-func (s *Scraper) emitValueAsGauge(sourceID, instanceID, name string, tags map[string]string, val float64) {
+func (s *Scraper) emitValueAsGauge(e MetricsEmitter, sourceID, instanceID, name string, tags map[string]string, val float64) {
 	var unit string
 	tagUnit, ok := tags["unit"]
 	if ok {
@@ -200,79 +424,91 @@ func (s *Scraper) emitValueAsGauge(sourceID, instanceID, name string, tags map[s
 		delete(tags, "unit")
 	}
 
-	s.metricsEmitter.EmitGauge(
+	e.EmitGauge(
 		loggregator.WithGaugeValue(name, val, unit),
 		loggregator.WithGaugeSourceInfo(sourceID, instanceID),
 		loggregator.WithEnvelopeTags(tags),
 	)
 }
 
-func (s *Scraper) emitGauge(sourceID, instanceID, name string, tags map[string]string, metric *io_prometheus_client.Metric) {
+func (s *Scraper) emitGauge(e MetricsEmitter, sourceID, instanceID, name string, tags map[string]string, metric *io_prometheus_client.Metric) {
 	val := metric.GetGauge().GetValue()
-	s.emitValueAsGauge(sourceID, instanceID, name, tags, val)
+	s.emitValueAsGauge(e, sourceID, instanceID, name, tags, val)
 }
 
-func (s *Scraper) emitUntyped(sourceID, instanceID, name string, tags map[string]string, metric *io_prometheus_client.Metric) {
+func (s *Scraper) emitUntyped(e MetricsEmitter, sourceID, instanceID, name string, tags map[string]string, metric *io_prometheus_client.Metric) {
 	// Loggregator API doesn't have Untyped type, emit as
 	// most permissive type
 	val := metric.GetUntyped().GetValue()
-	s.emitValueAsGauge(sourceID, instanceID, name, tags, val)
-}
-
-func (s *Scraper) emitCounter(sourceID, instanceID, name string, tags map[string]string, metric *io_prometheus_client.Metric) {
-	val := metric.GetCounter().GetValue()
-	if val != float64(uint64(val)) {
-		return //the counter contains a fractional value
-	}
-
-	s.metricsEmitter.EmitCounter(
-		name,
-		loggregator.WithTotal(uint64(val)),
-		loggregator.WithCounterSourceInfo(sourceID, instanceID),
-		loggregator.WithEnvelopeTags(tags),
-	)
+	s.emitValueAsGauge(e, sourceID, instanceID, name, tags, val)
 }
 
-func (s *Scraper) emitHistogram(sourceID, instanceID, name string, tags map[string]string, metric *io_prometheus_client.Metric) {
+func (s *Scraper) emitHistogram(e MetricsEmitter, sourceID, instanceID, name string, tags map[string]string, metric *io_prometheus_client.Metric) {
 	histogram := metric.GetHistogram()
 
-	s.metricsEmitter.EmitGauge(
+	e.EmitGauge(
 		loggregator.WithGaugeValue(name+"_sum", histogram.GetSampleSum(), ""),
 		loggregator.WithGaugeSourceInfo(sourceID, instanceID),
 		loggregator.WithEnvelopeTags(tags),
 	)
-	s.metricsEmitter.EmitCounter(
+	e.EmitCounter(
 		name+"_count",
 		loggregator.WithTotal(histogram.GetSampleCount()),
 		loggregator.WithCounterSourceInfo(sourceID, instanceID),
 		loggregator.WithEnvelopeTags(tags),
 	)
 	for _, bucket := range histogram.GetBucket() {
-		s.metricsEmitter.EmitCounter(
+		e.EmitCounter(
 			name+"_bucket",
 			loggregator.WithTotal(bucket.GetCumulativeCount()),
 			loggregator.WithCounterSourceInfo(sourceID, instanceID),
 			loggregator.WithEnvelopeTags(tags),
 			loggregator.WithEnvelopeTag("le", strconv.FormatFloat(bucket.GetUpperBound(), 'g', -1, 64)),
+			loggregator.WithEnvelopeTags(exemplarTags("exemplar_", bucket.GetExemplar())),
 		)
 	}
 }
 
-func (s *Scraper) emitSummary(sourceID, instanceID, name string, tags map[string]string, metric *io_prometheus_client.Metric) {
+func (s *Scraper) emitGaugeHistogram(e MetricsEmitter, sourceID, instanceID, name string, tags map[string]string, metric *io_prometheus_client.Metric) {
+	// Gauge histograms can go down, so all of their series are emitted as
+	// gauges.
+	histogram := metric.GetHistogram()
+
+	e.EmitGauge(
+		loggregator.WithGaugeValue(name+"_gsum", histogram.GetSampleSum(), ""),
+		loggregator.WithGaugeSourceInfo(sourceID, instanceID),
+		loggregator.WithEnvelopeTags(tags),
+	)
+	e.EmitGauge(
+		loggregator.WithGaugeValue(name+"_gcount", float64(histogram.GetSampleCount()), ""),
+		loggregator.WithGaugeSourceInfo(sourceID, instanceID),
+		loggregator.WithEnvelopeTags(tags),
+	)
+	for _, bucket := range histogram.GetBucket() {
+		e.EmitGauge(
+			loggregator.WithGaugeValue(name+"_bucket", float64(bucket.GetCumulativeCount()), ""),
+			loggregator.WithGaugeSourceInfo(sourceID, instanceID),
+			loggregator.WithEnvelopeTags(tags),
+			loggregator.WithEnvelopeTag("le", strconv.FormatFloat(bucket.GetUpperBound(), 'g', -1, 64)),
+		)
+	}
+}
+
+func (s *Scraper) emitSummary(e MetricsEmitter, sourceID, instanceID, name string, tags map[string]string, metric *io_prometheus_client.Metric) {
 	summary := metric.GetSummary()
-	s.metricsEmitter.EmitGauge(
+	e.EmitGauge(
 		loggregator.WithGaugeValue(name+"_sum", summary.GetSampleSum(), ""),
 		loggregator.WithGaugeSourceInfo(sourceID, instanceID),
 		loggregator.WithEnvelopeTags(tags),
 	)
-	s.metricsEmitter.EmitCounter(
+	e.EmitCounter(
 		name+"_count",
 		loggregator.WithTotal(summary.GetSampleCount()),
 		loggregator.WithCounterSourceInfo(sourceID, instanceID),
 		loggregator.WithEnvelopeTags(tags),
 	)
 	for _, quantile := range summary.GetQuantile() {
-		s.metricsEmitter.EmitGauge(
+		e.EmitGauge(
 			loggregator.WithGaugeValue(name, float64(quantile.GetValue()), ""),
 			loggregator.WithGaugeSourceInfo(sourceID, instanceID),
 			loggregator.WithEnvelopeTags(tags),
@@ -1,6 +1,7 @@
 package scraper_test
 
 import (
+	"bytes"
 	"errors"
 	"io"
 	"net/http"
@@ -10,6 +11,9 @@ import (
 
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
+	io_prometheus_client "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+	"google.golang.org/protobuf/proto"
 
 	"code.cloudfoundry.org/go-loggregator/v9"
 	"code.cloudfoundry.org/go-loggregator/v9/rpc/loggregator_v2"
@@ -25,7 +29,7 @@ var _ = Describe("Scraper", func() {
 		scraper       *scraper.Scraper
 	}
 
-	var setup = func(targets ...scraper.Target) *testContext {
+	var setupWithOptions = func(targets []scraper.Target, opts ...scraper.ScrapeOption) *testContext {
 		spyMetricGetter := newSpyMetricGetter()
 		spyMetricEmitter := newSpyMetricEmitter()
 		spyMetricClient := metricsHelpers.NewMetricsRegistry()
@@ -37,7 +41,10 @@ var _ = Describe("Scraper", func() {
 			spyMetricEmitter,
 			spyMetricGetter.Get,
 			"default-id",
-			scraper.WithMetricsClient(spyMetricClient),
+			append([]scraper.ScrapeOption{
+				scraper.WithMetricsClient(spyMetricClient),
+				scraper.WithDroppedSeriesMetrics(spyMetricClient),
+			}, opts...)...,
 		)
 
 		return &testContext{
@@ -48,6 +55,10 @@ var _ = Describe("Scraper", func() {
 		}
 	}
 
+	var setup = func(targets ...scraper.Target) *testContext {
+		return setupWithOptions(targets)
+	}
+
 	var addResponse = func(tc *testContext, statusCode int, body string) {
 		tc.metricGetter.resp <- &http.Response{
 			StatusCode: statusCode,
@@ -55,6 +66,14 @@ var _ = Describe("Scraper", func() {
 		}
 	}
 
+	var addFormattedResponse = func(tc *testContext, contentType string, body string) {
+		tc.metricGetter.resp <- &http.Response{
+			StatusCode: http.StatusOK,
+			Header:     http.Header{"Content-Type": []string{contentType}},
+			Body:       io.NopCloser(strings.NewReader(body)),
+		}
+	}
+
 	Context("gauges", func() {
 		It("emits a gauge metric with the target source ID", func() {
 			tc := setup(scraper.Target{
@@ -169,6 +188,203 @@ var _ = Describe("Scraper", func() {
 			Expect(tc.metricEmitter.envelopes).To(ConsistOf(
 				buildCounter("source-1", "some-instance-id", "counter_int", 1, nil),
 			))
+			Expect(tc.metricClient.GetMetricValue("dropped_series_total", map[string]string{
+				"scrape_target_source_id": "some-id",
+				"reason":                  "float_counter",
+			})).To(Equal(1.0))
+		})
+	})
+
+	Context("float counters", func() {
+		var target = scraper.Target{
+			ID:         "some-id",
+			InstanceID: "some-instance-id",
+			MetricURL:  "http://some.url/metrics",
+		}
+
+		It("emits float counters as gauges", func() {
+			tc := setupWithOptions([]scraper.Target{target}, scraper.WithFloatCounters(scraper.FloatCounterGauge, 1))
+			addResponse(tc, 200, floatCounterOutput)
+
+			Expect(tc.scraper.Scrape()).To(Succeed())
+
+			Expect(tc.metricEmitter.envelopes).To(ConsistOf(
+				buildCounter("source-1", "some-instance-id", "counter_int", 1, nil),
+				buildGauge("source-2", "some-instance-id", "counter_float", 2.2, nil),
+			))
+		})
+
+		It("emits scaled float counters", func() {
+			tc := setupWithOptions([]scraper.Target{target}, scraper.WithFloatCounters(scraper.FloatCounterScale, 1000))
+			addResponse(tc, 200, floatCounterOutput)
+
+			Expect(tc.scraper.Scrape()).To(Succeed())
+
+			Expect(tc.metricEmitter.envelopes).To(ConsistOf(
+				buildCounter("source-1", "some-instance-id", "counter_int", 1, nil),
+				buildCounter("source-2", "some-instance-id", "counter_float", 2200, map[string]string{"counter_scale_factor": "1000"}),
+			))
+		})
+
+		It("keeps the strategy for later integer values of a float counter", func() {
+			tc := setupWithOptions([]scraper.Target{target}, scraper.WithFloatCounters(scraper.FloatCounterScale, 1000))
+			addResponse(tc, 200, floatCounterOutput)
+			Expect(tc.scraper.Scrape()).To(Succeed())
+
+			tc.metricEmitter.envelopes = nil
+			addResponse(tc, 200, strings.Replace(floatCounterOutput, "2.2", "3", 1))
+			Expect(tc.scraper.Scrape()).To(Succeed())
+
+			Expect(tc.metricEmitter.envelopes).To(ContainElement(
+				buildCounter("source-2", "some-instance-id", "counter_float", 3000, map[string]string{"counter_scale_factor": "1000"}),
+			))
+		})
+
+		It("scales seconds counters from their first integer value", func() {
+			tc := setupWithOptions([]scraper.Target{target}, scraper.WithFloatCounters(scraper.FloatCounterScale, 1000))
+			addResponse(tc, 200, secondsCounterOutput("3"))
+			Expect(tc.scraper.Scrape()).To(Succeed())
+
+			Expect(tc.metricEmitter.envelopes).To(ConsistOf(
+				buildCounter("some-id", "some-instance-id", "process_cpu_seconds_total", 3000, map[string]string{"counter_scale_factor": "1000"}),
+			))
+
+			tc.metricEmitter.envelopes = nil
+			addResponse(tc, 200, secondsCounterOutput("3.5"))
+			Expect(tc.scraper.Scrape()).To(Succeed())
+
+			Expect(tc.metricEmitter.envelopes).To(ConsistOf(
+				buildCounter("some-id", "some-instance-id", "process_cpu_seconds_total", 3500, map[string]string{"counter_scale_factor": "1000"}),
+			))
+		})
+
+		It("switches other counters to the strategy at their first fractional value", func() {
+			tc := setupWithOptions([]scraper.Target{target}, scraper.WithFloatCounters(scraper.FloatCounterScale, 1000))
+			addResponse(tc, 200, strings.Replace(floatCounterOutput, "2.2", "3", 1))
+			Expect(tc.scraper.Scrape()).To(Succeed())
+
+			Expect(tc.metricEmitter.envelopes).To(ContainElement(
+				buildCounter("source-2", "some-instance-id", "counter_float", 3, nil),
+			))
+
+			tc.metricEmitter.envelopes = nil
+			addResponse(tc, 200, strings.Replace(floatCounterOutput, "2.2", "3.5", 1))
+			Expect(tc.scraper.Scrape()).To(Succeed())
+
+			Expect(tc.metricEmitter.envelopes).To(ContainElement(
+				buildCounter("source-2", "some-instance-id", "counter_float", 3500, map[string]string{"counter_scale_factor": "1000"}),
+			))
+		})
+
+		It("only switches the series that had a fractional value", func() {
+			tc := setupWithOptions([]scraper.Target{target}, scraper.WithFloatCounters(scraper.FloatCounterScale, 1000))
+			addResponse(tc, 200, `
+# TYPE requests counter
+requests{az="z1"} 1.5
+requests{az="z2"} 2
+`)
+			Expect(tc.scraper.Scrape()).To(Succeed())
+
+			tc.metricEmitter.envelopes = nil
+			addResponse(tc, 200, `
+# TYPE requests counter
+requests{az="z1"} 3
+requests{az="z2"} 4
+`)
+			Expect(tc.scraper.Scrape()).To(Succeed())
+
+			Expect(tc.metricEmitter.envelopes).To(ConsistOf(
+				buildCounter("some-id", "some-instance-id", "requests", 3000, map[string]string{"az": "z1", "counter_scale_factor": "1000"}),
+				buildCounter("some-id", "some-instance-id", "requests", 4, map[string]string{"az": "z2"}),
+			))
+		})
+
+		It("emits integer seconds counters as counters if float counters are discarded", func() {
+			tc := setup(target)
+			addResponse(tc, 200, secondsCounterOutput("3"))
+			Expect(tc.scraper.Scrape()).To(Succeed())
+
+			Expect(tc.metricEmitter.envelopes).To(ConsistOf(
+				buildCounter("some-id", "some-instance-id", "process_cpu_seconds_total", 3, nil),
+			))
+		})
+
+		It("forgets float counter series that are no longer scraped", func() {
+			tc := setupWithOptions([]scraper.Target{target}, scraper.WithFloatCounters(scraper.FloatCounterScale, 1000))
+			addResponse(tc, 200, floatCounterOutput)
+			Expect(tc.scraper.Scrape()).To(Succeed())
+
+			addResponse(tc, 200, "# TYPE counter_int counter\ncounter_int{source_id=\"source-1\"} 1\n")
+			Expect(tc.scraper.Scrape()).To(Succeed())
+
+			tc.metricEmitter.envelopes = nil
+			addResponse(tc, 200, strings.Replace(floatCounterOutput, "2.2", "3", 1))
+			Expect(tc.scraper.Scrape()).To(Succeed())
+
+			Expect(tc.metricEmitter.envelopes).To(ContainElement(
+				buildCounter("source-2", "some-instance-id", "counter_float", 3, nil),
+			))
+		})
+
+		It("keeps float counter series when a scrape fails", func() {
+			tc := setupWithOptions([]scraper.Target{target}, scraper.WithFloatCounters(scraper.FloatCounterScale, 1000))
+			addResponse(tc, 200, floatCounterOutput)
+			Expect(tc.scraper.Scrape()).To(Succeed())
+
+			addResponse(tc, 500, "")
+			Expect(tc.scraper.Scrape()).ToNot(Succeed())
+
+			tc.metricEmitter.envelopes = nil
+			addResponse(tc, 200, strings.Replace(floatCounterOutput, "2.2", "3", 1))
+			Expect(tc.scraper.Scrape()).To(Succeed())
+
+			Expect(tc.metricEmitter.envelopes).To(ContainElement(
+				buildCounter("source-2", "some-instance-id", "counter_float", 3000, map[string]string{"counter_scale_factor": "1000"}),
+			))
+		})
+
+		It("forgets float counter series of removed targets", func() {
+			targets := []scraper.Target{target}
+			spyMetricGetter := newSpyMetricGetter()
+			spyMetricEmitter := newSpyMetricEmitter()
+			s := scraper.New(
+				func() []scraper.Target { return targets },
+				spyMetricEmitter,
+				spyMetricGetter.Get,
+				"default-id",
+				scraper.WithFloatCounters(scraper.FloatCounterScale, 1000),
+			)
+			tc := &testContext{metricGetter: spyMetricGetter, metricEmitter: spyMetricEmitter, scraper: s}
+
+			addResponse(tc, 200, floatCounterOutput)
+			Expect(s.Scrape()).To(Succeed())
+
+			targets = nil
+			Expect(s.Scrape()).To(Succeed())
+
+			targets = []scraper.Target{target}
+			spyMetricEmitter.envelopes = nil
+			addResponse(tc, 200, strings.Replace(floatCounterOutput, "2.2", "3", 1))
+			Expect(s.Scrape()).To(Succeed())
+
+			Expect(spyMetricEmitter.envelopes).To(ContainElement(
+				buildCounter("source-2", "some-instance-id", "counter_float", 3, nil),
+			))
+		})
+
+		It("drops negative scaled counters", func() {
+			tc := setupWithOptions([]scraper.Target{target}, scraper.WithFloatCounters(scraper.FloatCounterScale, 1000))
+			addResponse(tc, 200, strings.Replace(floatCounterOutput, "2.2", "-2.2", 1))
+
+			Expect(tc.scraper.Scrape()).To(Succeed())
+
+			Expect(tc.metricEmitter.envelopes).To(ConsistOf(
+				buildCounter("source-1", "some-instance-id", "counter_int", 1, nil),
+			))
+			Expect(tc.metricClient.GetMetricValue("dropped_series_total", map[string]string{
+				"scrape_target_source_id": "some-id",
+				"reason":                  "float_counter",
+			})).To(Equal(1.0))
 		})
 	})
 
@@ -253,6 +469,451 @@ var _ = Describe("Scraper", func() {
 		})
 	})
 
+	Context("exposition formats", func() {
+		var target = scraper.Target{
+			ID:         "some-id",
+			InstanceID: "some-instance-id",
+			MetricURL:  "http://some.url/metrics",
+		}
+
+		It("negotiates the exposition format", func() {
+			tc := setup(target)
+			addResponse(tc, 200, promOutput)
+
+			Expect(tc.scraper.Scrape()).To(Succeed())
+
+			var headers map[string]string
+			Eventually(tc.metricGetter.headers).Should(Receive(&headers))
+			Expect(strings.Split(headers["Accept"], ",")).To(Equal([]string{
+				"application/vnd.google.protobuf;proto=io.prometheus.client.MetricFamily;encoding=delimited;q=0.7",
+				"application/openmetrics-text;version=1.0.0;q=0.5",
+				"text/plain;version=0.0.4;q=0.3",
+				"*/*;q=0.1",
+			}))
+		})
+
+		It("does not override the Accept header of the target", func() {
+			t := target
+			t.Headers = map[string]string{"accept": "text/plain"}
+			tc := setup(t)
+			addResponse(tc, 200, promOutput)
+
+			Expect(tc.scraper.Scrape()).To(Succeed())
+
+			Eventually(tc.metricGetter.headers).Should(Receive(Equal(map[string]string{"accept": "text/plain"})))
+		})
+
+		It("parses the protobuf format", func() {
+			tc := setup(target)
+			addFormattedResponse(tc, string(expfmt.FmtProtoDelim), encodeProtobuf(
+				&io_prometheus_client.MetricFamily{
+					Name: proto.String("requests_total"),
+					Type: io_prometheus_client.MetricType_COUNTER.Enum(),
+					Metric: []*io_prometheus_client.Metric{{
+						Label:   []*io_prometheus_client.LabelPair{{Name: proto.String("code"), Value: proto.String("200")}},
+						Counter: &io_prometheus_client.Counter{Value: proto.Float64(6)},
+					}},
+				},
+				&io_prometheus_client.MetricFamily{
+					Name: proto.String("temperature"),
+					Type: io_prometheus_client.MetricType_GAUGE.Enum(),
+					Metric: []*io_prometheus_client.Metric{{
+						Gauge: &io_prometheus_client.Gauge{Value: proto.Float64(21.5)},
+					}},
+				},
+			))
+
+			Expect(tc.scraper.Scrape()).To(Succeed())
+
+			Expect(tc.metricEmitter.envelopes).To(ConsistOf(
+				buildCounter("some-id", "some-instance-id", "requests_total", 6, map[string]string{"code": "200"}),
+				buildGauge("some-id", "some-instance-id", "temperature", 21.5, nil),
+			))
+		})
+
+		It("parses the OpenMetrics format", func() {
+			tc := setup(target)
+			addFormattedResponse(tc, string(expfmt.FmtOpenMetrics_1_0_0), openMetricsOutput)
+
+			Expect(tc.scraper.Scrape()).To(Succeed())
+
+			Expect(tc.metricEmitter.envelopes).To(ConsistOf(
+				buildCounter("some-id", "some-instance-id", "requests_total", 6, map[string]string{"code": "200", "exemplar_trace_id": "abc"}),
+				buildGauge("source-1", "some-instance-id", "temperature", 21.5, nil),
+				buildGauge("some-id", "some-instance-id", "build_info", 1, map[string]string{"version": "1.2.3"}),
+				buildGauge("some-id", "some-instance-id", "state", 1, map[string]string{"state": "ready"}),
+				buildGauge("some-id", "some-instance-id", "state", 0, map[string]string{"state": "failed"}),
+				buildCounter("some-id", "some-instance-id", "latency_bucket", 3, map[string]string{"le": "0.1", "exemplar_trace_id": "def"}),
+				buildCounter("some-id", "some-instance-id", "latency_bucket", 5, map[string]string{"le": "+Inf"}),
+				buildCounter("some-id", "some-instance-id", "latency_count", 5, nil),
+				buildGauge("some-id", "some-instance-id", "latency_sum", 1.5, nil),
+				buildGauge("some-id", "some-instance-id", "queue_bucket", 2, map[string]string{"le": "10"}),
+				buildGauge("some-id", "some-instance-id", "queue_bucket", 4, map[string]string{"le": "+Inf"}),
+				buildGauge("some-id", "some-instance-id", "queue_gcount", 4, nil),
+				buildGauge("some-id", "some-instance-id", "queue_gsum", 30, nil),
+				buildGauge("some-id", "some-instance-id", "rpc", 0.2, map[string]string{"quantile": "0.5"}),
+				buildCounter("some-id", "some-instance-id", "rpc_count", 7, nil),
+				buildGauge("some-id", "some-instance-id", "rpc_sum", 2.1, nil),
+				buildGauge("some-id", "some-instance-id", "other", 9.5, nil),
+			))
+		})
+
+		DescribeTable("returns an error for invalid OpenMetrics", func(body string) {
+			tc := setup(target)
+			addFormattedResponse(tc, string(expfmt.FmtOpenMetrics_1_0_0), body)
+
+			Expect(tc.scraper.Scrape()).To(HaveOccurred())
+		},
+			Entry("missing EOF", "# TYPE temperature gauge\ntemperature 21.5\n"),
+			Entry("invalid value", "temperature warm\n# EOF\n"),
+			Entry("unterminated labels", "temperature{unit=\"c\" 21.5\n# EOF\n"),
+			Entry("unknown type", "# TYPE temperature thermometer\n# EOF\n"),
+			Entry("bucket without le", "# TYPE latency histogram\nlatency_bucket 1\n# EOF\n"),
+		)
+	})
+
+	Context("batched histograms", func() {
+		var setupBatched = func() *testContext {
+			return setupWithOptions([]scraper.Target{{
+				ID:         "some-id",
+				InstanceID: "some-instance-id",
+				MetricURL:  "http://some.url/metrics",
+			}}, scraper.WithBatchedHistograms(true))
+		}
+
+		It("emits a histogram as a single envelope", func() {
+			tc := setupBatched()
+			addResponse(tc, 200, histogramOutput)
+
+			Expect(tc.scraper.Scrape()).To(Succeed())
+
+			Expect(tc.metricEmitter.envelopes).To(ConsistOf(
+				buildBatchedGauge("some-id", "some-instance-id", map[string]float64{
+					"http_request_duration_seconds_sum":            53423,
+					"http_request_duration_seconds_count":          144320,
+					"http_request_duration_seconds_bucket_le_1":    133988,
+					"http_request_duration_seconds_bucket_le_+Inf": 144320,
+				}, map[string]string{"metric_type": "histogram"}),
+			))
+		})
+
+		It("emits a summary as a single envelope", func() {
+			tc := setupBatched()
+			addResponse(tc, 200, promSummary)
+
+			Expect(tc.scraper.Scrape()).To(Succeed())
+
+			Expect(tc.metricEmitter.envelopes).To(ConsistOf(
+				buildBatchedGauge("some-id", "some-instance-id", map[string]float64{
+					"go_gc_duration_seconds_sum":           0.346341323,
+					"go_gc_duration_seconds_count":         331,
+					"go_gc_duration_seconds_quantile_0":    9.5e-08,
+					"go_gc_duration_seconds_quantile_0.25": 0.000157366,
+					"go_gc_duration_seconds_quantile_0.5":  0.000300143,
+					"go_gc_duration_seconds_quantile_0.75": 0.001091972,
+					"go_gc_duration_seconds_quantile_1":    0.011609012,
+				}, map[string]string{"metric_type": "summary"}),
+			))
+		})
+
+		It("forwards bucket exemplars as tags", func() {
+			tc := setupBatched()
+			addFormattedResponse(tc, string(expfmt.FmtOpenMetrics_1_0_0), `# TYPE latency histogram
+latency_bucket{le="0.1"} 3 # {trace_id="def",span_id="123"} 0.05
+latency_bucket{le="+Inf"} 5
+latency_count 5
+latency_sum 1.5
+# TYPE queue gaugehistogram
+queue_bucket{le="+Inf"} 4
+queue_gcount 4
+queue_gsum 30
+# EOF
+`)
+
+			Expect(tc.scraper.Scrape()).To(Succeed())
+
+			Expect(tc.metricEmitter.envelopes).To(ConsistOf(
+				buildBatchedGauge("some-id", "some-instance-id", map[string]float64{
+					"latency_sum":            1.5,
+					"latency_count":          5,
+					"latency_bucket_le_0.1":  3,
+					"latency_bucket_le_+Inf": 5,
+				}, map[string]string{
+					"metric_type": "histogram",
+					"latency_bucket_le_0.1_exemplar_trace_id": "def",
+					"latency_bucket_le_0.1_exemplar_span_id":  "123",
+				}),
+				buildBatchedGauge("some-id", "some-instance-id", map[string]float64{
+					"queue_gsum":           30,
+					"queue_gcount":         4,
+					"queue_bucket_le_+Inf": 4,
+				}, map[string]string{"metric_type": "gaugehistogram"}),
+			))
+		})
+
+		It("emits the buckets of native histograms", func() {
+			tc := setupBatched()
+			addFormattedResponse(tc, string(expfmt.FmtProtoDelim), encodeProtobuf(
+				&io_prometheus_client.MetricFamily{
+					Name: proto.String("latency"),
+					Type: io_prometheus_client.MetricType_HISTOGRAM.Enum(),
+					Metric: []*io_prometheus_client.Metric{{
+						Histogram: &io_prometheus_client.Histogram{
+							SampleCount:   proto.Uint64(9),
+							SampleSum:     proto.Float64(10),
+							Schema:        proto.Int32(0),
+							ZeroThreshold: proto.Float64(0.001),
+							ZeroCount:     proto.Uint64(1),
+							PositiveSpan: []*io_prometheus_client.BucketSpan{
+								{Offset: proto.Int32(0), Length: proto.Uint32(2)},
+								{Offset: proto.Int32(1), Length: proto.Uint32(1)},
+							},
+							PositiveDelta: []int64{2, -1, 3},
+							NegativeSpan: []*io_prometheus_client.BucketSpan{
+								{Offset: proto.Int32(-1), Length: proto.Uint32(1)},
+							},
+							NegativeDelta: []int64{1},
+						},
+					}},
+				},
+			))
+
+			Expect(tc.scraper.Scrape()).To(Succeed())
+
+			Expect(tc.metricEmitter.envelopes).To(ConsistOf(
+				buildBatchedGauge("some-id", "some-instance-id", map[string]float64{
+					"latency_sum":                10,
+					"latency_count":              9,
+					"latency_schema":             0,
+					"latency_zero_threshold":     0.001,
+					"latency_zero_count":         1,
+					"latency_positive_bucket_0":  2,
+					"latency_positive_bucket_1":  1,
+					"latency_positive_bucket_3":  4,
+					"latency_negative_bucket_-1": 1,
+				}, map[string]string{"metric_type": "histogram"}),
+			))
+		})
+	})
+
+	Context("relabeling", func() {
+		var setupRelabeled = func(sampleLimit int, configs ...scraper.RelabelConfig) *testContext {
+			relabeler, err := scraper.NewRelabeler(configs)
+			Expect(err).ToNot(HaveOccurred())
+
+			return setupWithOptions([]scraper.Target{{
+				ID:          "some-id",
+				InstanceID:  "some-instance-id",
+				MetricURL:   "http://some.url/metrics",
+				Relabeler:   relabeler,
+				SampleLimit: sampleLimit,
+			}})
+		}
+
+		It("drops, renames and relabels metrics", func() {
+			tc := setupRelabeled(0,
+				scraper.RelabelConfig{SourceLabels: []string{"__name__"}, Separator: ";", Regex: "node_.*", Action: scraper.RelabelDrop},
+				scraper.RelabelConfig{SourceLabels: []string{"code"}, Separator: ";", Regex: "5..", Replacement: "5xx", TargetLabel: "code", Action: scraper.RelabelReplace},
+				scraper.RelabelConfig{SourceLabels: []string{"__name__"}, Separator: ";", Regex: "promhttp_(.*)", Replacement: "$1", TargetLabel: "__name__", Action: scraper.RelabelReplace},
+			)
+			addResponse(tc, 200, promOutput)
+
+			Expect(tc.scraper.Scrape()).To(Succeed())
+
+			Expect(tc.metricEmitter.envelopes).To(ConsistOf(
+				buildCounter("some-id", "some-instance-id", "metric_handler_requests_total", 6, map[string]string{"code": "200"}),
+				buildCounter("some-id", "some-instance-id", "metric_handler_requests_total", 7, map[string]string{"code": "5xx"}),
+				buildCounter("some-id", "some-instance-id", "metric_handler_requests_total", 8, map[string]string{"code": "5xx"}),
+			))
+			Expect(tc.metricClient.GetMetricValue("dropped_series_total", map[string]string{
+				"scrape_target_source_id": "some-id",
+				"reason":                  "relabel",
+			})).To(Equal(5.0))
+		})
+
+		It("fails scrapes that exceed the sample limit", func() {
+			tc := setupRelabeled(2,
+				scraper.RelabelConfig{Regex: "code", Action: scraper.RelabelLabelDrop},
+			)
+			addResponse(tc, 200, promOutput)
+
+			Expect(tc.scraper.Scrape()).To(MatchError(ContainSubstring("sample limit of 2 exceeded with 8 series")))
+
+			Expect(tc.metricEmitter.envelopes).To(BeEmpty())
+			Expect(tc.metricClient.GetMetricValue("dropped_series_total", map[string]string{
+				"scrape_target_source_id": "some-id",
+				"reason":                  "sample_limit",
+			})).To(Equal(8.0))
+		})
+	})
+
+	Context("batched gauges", func() {
+		var target = scraper.Target{
+			ID:          "some-id",
+			InstanceID:  "some-instance-id",
+			MetricURL:   "http://some.url/metrics",
+			DefaultTags: map[string]string{"job": "node"},
+		}
+
+		It("emits gauges with the same tags as a single envelope", func() {
+			tc := setupWithOptions([]scraper.Target{target}, scraper.WithBatchedGauges(true))
+			addResponse(tc, 200, batchedGaugesOutput)
+
+			Expect(tc.scraper.Scrape()).To(Succeed())
+
+			Expect(tc.metricEmitter.envelopes).To(ConsistOf(
+				buildBatchedGauge("some-id", "some-instance-id", map[string]float64{
+					"node_memory_free_bytes":  10,
+					"node_memory_total_bytes": 20,
+					"node_up":                 1,
+				}, map[string]string{"job": "node", "az": "z1"}),
+				buildBatchedGauge("some-id", "some-instance-id", map[string]float64{
+					"node_memory_free_bytes": 30,
+				}, map[string]string{"job": "node", "az": "z2"}),
+				buildCounter("some-id", "some-instance-id", "node_requests_total", 4, map[string]string{"job": "node", "az": "z1"}),
+			))
+		})
+
+		It("keeps gauges with different timestamps in separate envelopes", func() {
+			tc := setupWithOptions([]scraper.Target{target}, scraper.WithBatchedGauges(true), scraper.WithHonorTimestamps(true))
+			addResponse(tc, 200, timestampedOutput)
+
+			Expect(tc.scraper.Scrape()).To(Succeed())
+
+			timestamped := buildBatchedGauge("some-id", "some-instance-id", map[string]float64{
+				"node_load1": 1.5,
+				"node_load5": 2.5,
+			}, map[string]string{"job": "node"})
+			timestamped.Timestamp = 1700000000000 * int64(time.Millisecond)
+
+			Expect(tc.metricEmitter.envelopes).To(ConsistOf(
+				timestamped,
+				buildBatchedGauge("some-id", "some-instance-id", map[string]float64{
+					"node_load15": 3.5,
+				}, map[string]string{"job": "node"}),
+			))
+		})
+	})
+
+	Context("timestamps", func() {
+		var target = scraper.Target{
+			ID:         "some-id",
+			InstanceID: "some-instance-id",
+			MetricURL:  "http://some.url/metrics",
+		}
+
+		It("sets the timestamps of the exposition on the envelopes", func() {
+			tc := setupWithOptions([]scraper.Target{target}, scraper.WithHonorTimestamps(true))
+			addResponse(tc, 200, timestampedOutput+`# TYPE node_forks_total counter
+node_forks_total 7 1700000001000
+`)
+
+			Expect(tc.scraper.Scrape()).To(Succeed())
+
+			timestamps := map[string]int64{}
+			for _, e := range tc.metricEmitter.envelopes {
+				for name := range e.GetGauge().GetMetrics() {
+					timestamps[name] = e.GetTimestamp()
+				}
+				if e.GetCounter() != nil {
+					timestamps[e.GetCounter().GetName()] = e.GetTimestamp()
+				}
+			}
+			Expect(timestamps).To(Equal(map[string]int64{
+				"node_load1":       1700000000000 * int64(time.Millisecond),
+				"node_load5":       1700000000000 * int64(time.Millisecond),
+				"node_load15":      0,
+				"node_forks_total": 1700000001000 * int64(time.Millisecond),
+			}))
+		})
+
+		It("ignores the timestamps of the exposition if not honored", func() {
+			tc := setup(target)
+			addResponse(tc, 200, timestampedOutput)
+
+			Expect(tc.scraper.Scrape()).To(Succeed())
+
+			Expect(tc.metricEmitter.envelopes).To(HaveLen(3))
+			for _, e := range tc.metricEmitter.envelopes {
+				Expect(e.GetTimestamp()).To(BeZero())
+			}
+		})
+	})
+
+	Context("scrape series", func() {
+		var scrapeSeries = func(tc *testContext) *loggregator_v2.Envelope {
+			for _, e := range tc.metricEmitter.envelopes {
+				if _, ok := e.GetGauge().GetMetrics()["up"]; ok {
+					return e
+				}
+			}
+			return nil
+		}
+
+		It("emits the series of a successful scrape", func() {
+			relabeler, err := scraper.NewRelabeler([]scraper.RelabelConfig{
+				{SourceLabels: []string{"__name__"}, Separator: ";", Regex: "node_.*", Action: scraper.RelabelDrop},
+			})
+			Expect(err).ToNot(HaveOccurred())
+			tc := setupWithOptions([]scraper.Target{{
+				ID:          "some-id",
+				InstanceID:  "some-instance-id",
+				MetricURL:   "http://some.url/metrics",
+				DefaultTags: map[string]string{"bosh_job": "some-job"},
+				Relabeler:   relabeler,
+			}}, scraper.WithScrapeSeries(true))
+			addResponse(tc, 200, promOutput)
+
+			Expect(tc.scraper.Scrape()).To(Succeed())
+
+			e := scrapeSeries(tc)
+			Expect(e).ToNot(BeNil())
+			Expect(e.GetSourceId()).To(Equal("some-id"))
+			Expect(e.GetInstanceId()).To(Equal("some-instance-id"))
+			Expect(e.GetTags()).To(Equal(map[string]string{"bosh_job": "some-job"}))
+
+			metrics := e.GetGauge().GetMetrics()
+			Expect(metrics).To(HaveLen(4))
+			Expect(metrics["up"].GetValue()).To(Equal(1.0))
+			Expect(metrics["scrape_duration_seconds"].GetValue()).To(BeNumerically(">", 0))
+			Expect(metrics["scrape_duration_seconds"].GetUnit()).To(Equal("seconds"))
+			Expect(metrics["scrape_samples_scraped"].GetValue()).To(Equal(8.0))
+			Expect(metrics["scrape_samples_post_metric_relabeling"].GetValue()).To(Equal(3.0))
+		})
+
+		It("emits the series of a failed scrape", func() {
+			tc := setupWithOptions([]scraper.Target{{
+				InstanceID: "some-instance-id",
+				MetricURL:  "http://some.url/metrics",
+			}}, scraper.WithScrapeSeries(true))
+			addResponse(tc, 500, "")
+
+			Expect(tc.scraper.Scrape()).ToNot(Succeed())
+
+			e := scrapeSeries(tc)
+			Expect(e).ToNot(BeNil())
+			Expect(e.GetSourceId()).To(Equal("default-id"))
+
+			metrics := e.GetGauge().GetMetrics()
+			Expect(metrics["up"].GetValue()).To(Equal(0.0))
+			Expect(metrics["scrape_samples_scraped"].GetValue()).To(Equal(0.0))
+			Expect(metrics["scrape_samples_post_metric_relabeling"].GetValue()).To(Equal(0.0))
+		})
+
+		It("is not emitted by default", func() {
+			tc := setup(scraper.Target{
+				ID:         "some-id",
+				InstanceID: "some-instance-id",
+				MetricURL:  "http://some.url/metrics",
+			})
+			addResponse(tc, 200, promOutput)
+
+			Expect(tc.scraper.Scrape()).To(Succeed())
+
+			Expect(scrapeSeries(tc)).To(BeNil())
+		})
+	})
+
 	Context("default tags", func() {
 		It("adds default tags to emitted metrics", func() {
 			tc := setup(scraper.Target{
@@ -360,7 +1021,11 @@ var _ = Describe("Scraper", func() {
 		addResponse(tc, 200, promSummary)
 
 		Expect(tc.scraper.Scrape()).To(Succeed())
-		Eventually(tc.metricGetter.headers).Should(Receive(Equal(headers)))
+		Eventually(tc.metricGetter.headers).Should(Receive(And(
+			HaveKeyWithValue("header1", "value1"),
+			HaveKeyWithValue("header2", "value2"),
+			HaveKey("Accept"),
+		)))
 	})
 
 	It("scrapes all endpoints even when one fails", func() {
@@ -647,6 +1312,58 @@ counter_int{source_id="source-1"} 1
 # HELP counter_float Example metric
 # TYPE counter_float counter
 counter_float{source_id="source-2"} 2.2
+`
+
+	batchedGaugesOutput = `
+# TYPE node_memory_free_bytes gauge
+node_memory_free_bytes{az="z1"} 10
+node_memory_free_bytes{az="z2"} 30
+# TYPE node_memory_total_bytes gauge
+node_memory_total_bytes{az="z1"} 20
+# TYPE node_up untyped
+node_up{az="z1"} 1
+# TYPE node_requests_total counter
+node_requests_total{az="z1"} 4
+`
+
+	timestampedOutput = `
+# TYPE node_load1 gauge
+node_load1 1.5 1700000000000
+# TYPE node_load5 gauge
+node_load5 2.5 1700000000000
+# TYPE node_load15 gauge
+node_load15 3.5
+`
+
+	openMetricsOutput = `# TYPE requests counter
+# HELP requests Requests handled.
+requests_total{code="200"} 6 # {trace_id="abc"} 1 1700000000.5
+requests_created{code="200"} 1700000000
+# TYPE temperature gauge
+# UNIT temperature celsius
+temperature{source_id="source-1"} 21.5 1700000000
+# TYPE build info
+build_info{version="1.2.3"} 1
+# TYPE state stateset
+state{state="ready"} 1
+state{state="failed"} 0
+# TYPE latency histogram
+latency_bucket{le="0.1"} 3 # {trace_id="def"} 0.05
+latency_bucket{le="+Inf"} 5
+latency_count 5
+latency_sum 1.5
+latency_created 1700000000
+# TYPE queue gaugehistogram
+queue_bucket{le="10"} 2
+queue_bucket{le="+Inf"} 4
+queue_gcount 4
+queue_gsum 30
+# TYPE rpc summary
+rpc{quantile="0.5"} 0.2
+rpc_count 7
+rpc_sum 2.1
+other 9.5
+# EOF
 `
 
 	promInvalid = `
@@ -680,6 +1397,10 @@ node_timex_pps_jitter_seconds 4
 `
 )
 
+func secondsCounterOutput(value string) string {
+	return "# TYPE process_cpu_seconds_total counter\nprocess_cpu_seconds_total " + value + "\n"
+}
+
 func buildGauge(sourceID, instanceID, name string, value float64, tags map[string]string) *loggregator_v2.Envelope {
 	if tags == nil {
 		tags = map[string]string{}
@@ -699,6 +1420,24 @@ func buildGauge(sourceID, instanceID, name string, value float64, tags map[strin
 	}
 }
 
+func buildBatchedGauge(sourceID, instanceID string, values map[string]float64, tags map[string]string) *loggregator_v2.Envelope {
+	metrics := make(map[string]*loggregator_v2.GaugeValue, len(values))
+	for name, value := range values {
+		metrics[name] = &loggregator_v2.GaugeValue{Value: value}
+	}
+
+	return &loggregator_v2.Envelope{
+		SourceId:   sourceID,
+		InstanceId: instanceID,
+		Message: &loggregator_v2.Envelope_Gauge{
+			Gauge: &loggregator_v2.Gauge{
+				Metrics: metrics,
+			},
+		},
+		Tags: tags,
+	}
+}
+
 func buildCounter(sourceID, instanceID, name string, value float64, tags map[string]string) *loggregator_v2.Envelope {
 	if tags == nil {
 		tags = map[string]string{}
@@ -717,6 +1456,15 @@ func buildCounter(sourceID, instanceID, name string, value float64, tags map[str
 	}
 }
 
+func encodeProtobuf(families ...*io_prometheus_client.MetricFamily) string {
+	var buf bytes.Buffer
+	encoder := expfmt.NewEncoder(&buf, expfmt.FmtProtoDelim)
+	for _, f := range families {
+		Expect(encoder.Encode(f)).To(Succeed())
+	}
+	return buf.String()
+}
+
 type spyMetricEmitter struct {
 	envelopes []*loggregator_v2.Envelope
 	mu        sync.Mutex
@@ -0,0 +1,112 @@
+package scraper
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net"
+	"strings"
+	"time"
+)
+
+// ProviderOption configures the targets returned by the service discovery
+// target providers.
+type ProviderOption func(*providerConfig)
+
+type providerConfig struct {
+	scheme          string
+	path            string
+	refreshInterval time.Duration
+	log             *log.Logger
+	resolver        srvResolver
+	defaults        Target
+}
+
+type srvResolver interface {
+	LookupSRV(ctx context.Context, service, proto, name string) (string, []*net.SRV, error)
+}
+
+func newProviderConfig(opts []ProviderOption) providerConfig {
+	c := providerConfig{
+		scheme:          "https",
+		path:            "/metrics",
+		refreshInterval: 5 * time.Minute,
+		log:             log.Default(),
+		resolver:        net.DefaultResolver,
+	}
+	for _, o := range opts {
+		o(&c)
+	}
+	return c
+}
+
+// WithScheme sets the scheme of the metric URLs. It defaults to https.
+func WithScheme(scheme string) ProviderOption {
+	return func(c *providerConfig) {
+		c.scheme = scheme
+	}
+}
+
+// WithMetricsPath sets the path of the metric URLs. It defaults to
+// /metrics.
+func WithMetricsPath(path string) ProviderOption {
+	return func(c *providerConfig) {
+		c.path = path
+	}
+}
+
+// WithRefreshInterval sets how often the file based provider rereads its
+// files. It defaults to 5 minutes.
+func WithRefreshInterval(d time.Duration) ProviderOption {
+	return func(c *providerConfig) {
+		c.refreshInterval = d
+	}
+}
+
+// WithProviderLogger sets the logger for discovery errors.
+func WithProviderLogger(l *log.Logger) ProviderOption {
+	return func(c *providerConfig) {
+		c.log = l
+	}
+}
+
+// WithSRVResolver sets the resolver for SRV lookups.
+func WithSRVResolver(r srvResolver) ProviderOption {
+	return func(c *providerConfig) {
+		c.resolver = r
+	}
+}
+
+// WithTargetDefaults sets the source ID, instance ID, headers, default tags,
+// relabeler and sample limit of the discovered targets. Its metric URL is
+// ignored and the file based provider uses the target address as instance
+// ID.
+func WithTargetDefaults(t Target) ProviderOption {
+	return func(c *providerConfig) {
+		c.defaults = t
+	}
+}
+
+// target returns a target for the host and port with the defaults of the
+// provider.
+func (c providerConfig) target(hostPort string) Target {
+	t := c.defaults
+	t.MetricURL = c.metricURL(c.scheme, hostPort, c.path)
+	t.DefaultTags = copyTags(c.defaults.DefaultTags)
+	return t
+}
+
+func (c providerConfig) metricURL(scheme, hostPort, path string) string {
+	return fmt.Sprintf("%s://%s/%s", scheme, hostPort, strings.TrimPrefix(path, "/"))
+}
+
+func copyTags(tags map[string]string) map[string]string {
+	if tags == nil {
+		return nil
+	}
+	c := make(map[string]string, len(tags))
+	for k, v := range tags {
+		c[k] = v
+	}
+	return c
+}